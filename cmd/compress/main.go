@@ -0,0 +1,162 @@
+// This is a command line interface for all compression algorithms in the
+// project.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/codec"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/container"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+)
+
+var algorithm string
+var decompress bool
+var testOnly bool
+var listCodecs bool
+var raw bool
+var showHelp bool
+
+func init() {
+	flag.StringVar(&algorithm, "a", "lz77",
+		"compress using `algorithm`; when decompressing, the algorithm is detected if not specified")
+	flag.BoolVar(&decompress, "d", false, "decompress instead of compressing")
+	flag.BoolVar(&testOnly, "t", false, "test that <input file> decompresses without errors")
+	flag.BoolVar(&listCodecs, "l", false, "list available algorithms")
+	flag.BoolVar(&raw, "raw", false,
+		"write the format of the algorithm without the container header")
+	flag.BoolVar(&showHelp, "help", false, "print help message")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr,
+			"usage:", os.Args[0], "[flags] <input file> <output file>")
+		fmt.Fprintln(os.Stderr,
+			"      ", os.Args[0], "-t [flags] <input file>")
+		fmt.Fprintln(os.Stderr,
+			"      ", os.Args[0], "-l")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr,
+			"compress <input file> and write the output to <output file>")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+}
+
+// algorithmSet reports whether the algorithm was specified on the command
+// line.
+func algorithmSet() bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "a" {
+			set = true
+		}
+	})
+	return set
+}
+
+// printCodecs prints the names and descriptions of the available codecs.
+func printCodecs() {
+	for _, c := range codec.List() {
+		detected := ""
+		if c.Match == nil {
+			detected = " (not detected automatically)"
+		}
+		fmt.Printf("%-10s %s%s\n", c.Name, c.Description, detected)
+	}
+}
+
+// decode decompresses input to output. Containers are decompressed using the
+// codec in their header, which must match c if c isn't nil. Other input is
+// decompressed using c or the codec detected from the header of input if c is
+// nil.
+func decode(c *codec.Codec, input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	header, err := src.Peek(codec.MaxHeaderSize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(header) >= len(container.Magic) &&
+		string(header[:len(container.Magic)]) == container.Magic {
+		if c != nil {
+			_, err = container.DecodeWith(src, output, c)
+		} else {
+			_, err = container.Decode(src, output)
+		}
+		return err
+	}
+	if c != nil {
+		return c.Decode(src, output)
+	}
+	c, err = codec.Detect(header)
+	if err != nil {
+		return fmt.Errorf("%w, specify the algorithm using -a", err)
+	}
+	return c.Decode(src, output)
+}
+
+// encode compresses inputFile to output using c.
+func encode(c *codec.Codec, inputFile *os.File, output io.Writer) error {
+	if raw {
+		return c.Encode(inputFile, output)
+	}
+	return container.EncodeFile(inputFile, output, c)
+}
+
+func run() error {
+	if showHelp {
+		flag.Usage()
+		return nil
+	}
+	if listCodecs {
+		printCodecs()
+		return nil
+	}
+	// When decompressing, the codec is detected unless it is specified.
+	var c *codec.Codec
+	if algorithmSet() || !decompress && !testOnly {
+		var err error
+		c, err = codec.Lookup(algorithm)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, algorithm)
+		}
+	}
+	if testOnly {
+		if flag.NArg() != 1 {
+			return fmt.Errorf("expected 1 argument, got %d", flag.NArg())
+		}
+		inputFile, err := os.Open(flag.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer inputFile.Close()
+		return decode(c, inputFile, ioutil.Discard)
+	}
+	if flag.NArg() != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", flag.NArg())
+	}
+	inputFile, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	outputFile, err := os.Create(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+	if decompress {
+		return decode(c, inputFile, outputFile)
+	}
+	return encode(c, inputFile, outputFile)
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,79 @@
+// This is a command line interface for compressing and decompressing files in
+// gzip and zlib formats.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/gzip"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/zlib"
+)
+
+var decompress bool
+var useZlib bool
+var showHelp bool
+
+func init() {
+	flag.BoolVar(&decompress, "d", false, "decompress instead of compressing")
+	flag.BoolVar(&useZlib, "zlib", false, "use zlib format instead of gzip")
+	flag.BoolVar(&showHelp, "help", false, "print help message")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr,
+			"usage:", os.Args[0], "[flags] <input file> <output file>")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr,
+			"compress <input file> and write the output to <output file>")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+}
+
+func run() error {
+	if showHelp {
+		flag.Usage()
+		return nil
+	}
+	if flag.NArg() != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", flag.NArg())
+	}
+	inputFile, err := os.Open(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	outputFile, err := os.Create(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+	if useZlib {
+		if decompress {
+			return zlib.Decode(inputFile, outputFile)
+		}
+		return zlib.Encode(inputFile, outputFile)
+	}
+	if decompress {
+		_, err := gzip.Decode(inputFile, outputFile)
+		return err
+	}
+	stat, err := inputFile.Stat()
+	if err != nil {
+		return err
+	}
+	return gzip.Encode(inputFile, outputFile, &gzip.Header{
+		Name:    filepath.Base(flag.Arg(0)),
+		ModTime: stat.ModTime(),
+		OS:      gzip.OSUnknown,
+	})
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err.Error())
+		os.Exit(1)
+	}
+}
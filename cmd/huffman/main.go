@@ -7,7 +7,8 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/lassilaiho/compression-algorithms-tiralabra/huffman"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/codec"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/container"
 )
 
 var decompress bool
@@ -50,10 +51,15 @@ func run() error {
 		return err
 	}
 	defer outputFile.Close()
+	c, err := codec.Lookup("huffman")
+	if err != nil {
+		return err
+	}
 	if decompress {
-		return huffman.Decode(inputFile, outputFile)
+		_, err := container.DecodeWith(inputFile, outputFile, c)
+		return err
 	}
-	return huffman.Encode(inputFile, outputFile)
+	return container.EncodeFile(inputFile, outputFile, c)
 }
 
 func main() {
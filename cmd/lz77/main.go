@@ -5,17 +5,32 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 
+	"github.com/lassilaiho/compression-algorithms-tiralabra/codec"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/container"
 	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
 )
 
 var decompress bool
 var showHelp bool
+var workers int
+var blockSize int
+var prime bool
+var windowBits int
 
 func init() {
 	flag.BoolVar(&decompress, "d", false, "decompress instead of compressing")
 	flag.BoolVar(&showHelp, "help", false, "print help message")
+	flag.IntVar(&workers, "w", 0,
+		"process blocks using `n` worker goroutines (0 compresses without blocks)")
+	flag.IntVar(&blockSize, "block-size", lz77.DefaultBlockSize,
+		"block size in bytes when compressing with workers")
+	flag.BoolVar(&prime, "prime", false,
+		"let blocks refer to the previous block when compressing with workers")
+	flag.IntVar(&windowBits, "window-bits", 0,
+		"compress using variable-length references and a window of 2^`n` bytes")
 	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr,
 			"usage:", os.Args[0], "[flags] <input file> <output file>")
@@ -40,6 +55,9 @@ func run() error {
 	if flag.NArg() != 2 {
 		return fmt.Errorf("expected 2 arguments, got %d", flag.NArg())
 	}
+	if workers > 0 && windowBits > 0 {
+		return fmt.Errorf("-w and -window-bits can't be used together")
+	}
 	inputFile, err := os.Open(flag.Arg(0))
 	if err != nil {
 		return err
@@ -50,10 +68,42 @@ func run() error {
 		return err
 	}
 	defer outputFile.Close()
+	registered, err := codec.Lookup("lz77")
+	if err != nil {
+		return err
+	}
+	// The options change how the data is coded but not the codec recorded in
+	// the container.
+	c := *registered
+	c.Encode = encode
+	if workers > 0 {
+		c.Decode = func(input io.Reader, output io.Writer) error {
+			return lz77.DecodeParallel(input, output, workers)
+		}
+	}
 	if decompress {
-		return lz77.Decode(inputFile, outputFile)
+		_, err := container.DecodeWith(inputFile, outputFile, &c)
+		return err
+	}
+	return container.EncodeFile(inputFile, outputFile, &c)
+}
+
+// encode compresses input to output using the options given on the command
+// line.
+func encode(input io.ReadSeeker, output io.Writer) error {
+	if windowBits > 0 {
+		return lz77.EncodeLargeWindow(input, output, lz77.LargeWindowOptions{
+			WindowBits: windowBits,
+		})
+	}
+	if workers > 0 {
+		return lz77.EncodeParallel(input, output, lz77.ParallelOptions{
+			Workers:   workers,
+			BlockSize: blockSize,
+			Prime:     prime,
+		})
 	}
-	return lz77.Encode(inputFile, outputFile)
+	return lz77.Encode(input, output)
 }
 
 func main() {
@@ -0,0 +1,71 @@
+// This is a command line interface for creating and applying patches using
+// LZ77 delta compression.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+)
+
+var showHelp bool
+
+func init() {
+	flag.BoolVar(&showHelp, "help", false, "print help message")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr,
+			"usage:", os.Args[0], "[flags] diff <old file> <new file> <patch file>")
+		fmt.Fprintln(os.Stderr,
+			"      ", os.Args[0], "[flags] apply <old file> <patch file> <new file>")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr,
+			"diff writes a patch turning <old file> into <new file> to <patch file>")
+		fmt.Fprintln(os.Stderr,
+			"apply applies <patch file> to <old file> and writes the result to <new file>")
+		fmt.Fprintln(os.Stderr)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+}
+
+func run() error {
+	if showHelp {
+		flag.Usage()
+		return nil
+	}
+	if flag.NArg() != 4 {
+		return fmt.Errorf("expected 4 arguments, got %d", flag.NArg())
+	}
+	command := flag.Arg(0)
+	if command != "diff" && command != "apply" {
+		return fmt.Errorf("unknown command %q", command)
+	}
+	old, err := ioutil.ReadFile(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	inputFile, err := os.Open(flag.Arg(2))
+	if err != nil {
+		return err
+	}
+	defer inputFile.Close()
+	outputFile, err := os.Create(flag.Arg(3))
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+	if command == "apply" {
+		return lz77.DecodeDelta(old, inputFile, outputFile)
+	}
+	return lz77.EncodeDelta(old, inputFile, outputFile)
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Package codec provides a registry of the compression algorithms implemented in
+this project behind a common interface.
+
+Each Codec has a unique name used for selecting it. Codecs whose formats start
+with a recognizable header can also be detected from the beginning of encoded
+data using Detect. Formats without a header, such as raw DEFLATE and the
+formats of packages huffman and lz78, can't be detected.
+
+New codecs are made available by adding them to the registry using Register.
+*/
+package codec
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/deflate"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/gzip"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/huffman"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz4"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz78"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lzw"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/snappy"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/zlib"
+)
+
+// Errors returned by the functions of this package.
+var (
+	ErrUnknown    = errors.New("codec: unknown algorithm")
+	ErrUndetected = errors.New("codec: unrecognized format")
+)
+
+// MaxHeaderSize is the number of bytes of encoded data needed by Detect to
+// recognize all formats.
+const MaxHeaderSize = 16
+
+// Codec describes a compression algorithm.
+type Codec struct {
+	// Name identifies the codec. It consists of lowercase letters and
+	// digits.
+	Name string
+	// ID identifies the codec in binary formats. It is never 0.
+	ID byte
+	// Description is a short human-readable description of the codec.
+	Description string
+	// Encode compresses data from input and writes it to output.
+	Encode func(input io.ReadSeeker, output io.Writer) error
+	// Decode decompresses data from input and writes it to output.
+	Decode func(input io.Reader, output io.Writer) error
+	// Match reports whether header, the first at most MaxHeaderSize bytes of
+	// a stream, starts with the header of the format. nil if the format
+	// can't be detected.
+	Match func(header []byte) bool
+}
+
+// registry holds the registered codecs by name.
+var registry = map[string]*Codec{}
+
+// registryByID holds the registered codecs by ID.
+var registryByID [256]*Codec
+
+// Register adds c to the registry. It panics if c.ID is 0 or a codec with the
+// same name or ID is already registered.
+func Register(c *Codec) {
+	if c.ID == 0 {
+		panic("codec: invalid ID for codec " + c.Name)
+	}
+	if _, ok := registry[c.Name]; ok || registryByID[c.ID] != nil {
+		panic("codec: duplicate codec " + c.Name)
+	}
+	registry[c.Name] = c
+	registryByID[c.ID] = c
+}
+
+// Lookup returns the codec named name. ErrUnknown is returned if there is no
+// such codec.
+func Lookup(name string) (*Codec, error) {
+	if c, ok := registry[name]; ok {
+		return c, nil
+	}
+	return nil, ErrUnknown
+}
+
+// LookupID returns the codec with the specified ID. ErrUnknown is returned if
+// there is no such codec.
+func LookupID(id byte) (*Codec, error) {
+	if c := registryByID[id]; c != nil {
+		return c, nil
+	}
+	return nil, ErrUnknown
+}
+
+// List returns all registered codecs in the order of their IDs.
+func List() []*Codec {
+	codecs := make([]*Codec, 0, len(registry))
+	for _, c := range registryByID {
+		if c != nil {
+			codecs = codecs[:len(codecs)+1]
+			codecs[len(codecs)-1] = c
+		}
+	}
+	return codecs
+}
+
+// Detect returns the codec whose format header matches header, the first at
+// most MaxHeaderSize bytes of encoded data. ErrUndetected is returned if no
+// codec matches. Codecs are tried in the order of their IDs.
+func Detect(header []byte) (*Codec, error) {
+	for _, c := range registryByID {
+		if c != nil && c.Match != nil && c.Match(header) {
+			return c, nil
+		}
+	}
+	return nil, ErrUndetected
+}
+
+// hasPrefix returns a Match function matching headers starting with magic.
+func hasPrefix(magic string) func([]byte) bool {
+	return func(header []byte) bool {
+		return len(header) >= len(magic) && string(header[:len(magic)]) == magic
+	}
+}
+
+// isZlibHeader reports whether header starts with a zlib header using the
+// DEFLATE method. The two header bytes form a multiple of 31.
+func isZlibHeader(header []byte) bool {
+	return len(header) >= 2 &&
+		header[0]&0x0f == 8 &&
+		header[0]>>4 <= 7 &&
+		(uint(header[0])<<8|uint(header[1]))%31 == 0
+}
+
+func init() {
+	Register(&Codec{
+		Name:        "deflate",
+		ID:          1,
+		Description: "raw DEFLATE (RFC 1951)",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return deflate.Encode(input, output)
+		},
+		Decode: deflate.Decode,
+	})
+	Register(&Codec{
+		Name:        "gzip",
+		ID:          2,
+		Description: "gzip file format (RFC 1952)",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return gzip.Encode(input, output, nil)
+		},
+		Decode: func(input io.Reader, output io.Writer) error {
+			_, err := gzip.Decode(input, output)
+			return err
+		},
+		Match: hasPrefix("\x1f\x8b"),
+	})
+	Register(&Codec{
+		Name:        "huffman",
+		ID:          3,
+		Description: "Huffman coding",
+		Encode:      huffman.Encode,
+		Decode:      huffman.Decode,
+	})
+	Register(&Codec{
+		Name:        "lz4",
+		ID:          4,
+		Description: "LZ4 frame format",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return lz4.Encode(input, output)
+		},
+		Decode: lz4.Decode,
+		Match:  hasPrefix("\x04\x22\x4d\x18"),
+	})
+	Register(&Codec{
+		Name:        "lz77",
+		ID:          5,
+		Description: "LZ77",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return lz77.Encode(input, output)
+		},
+		Decode: lz77.Decode,
+		Match:  hasPrefix("LZ77"),
+	})
+	Register(&Codec{
+		Name:        "lz78",
+		ID:          6,
+		Description: "LZ78",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return lz78.Encode(input, output)
+		},
+		Decode: lz78.Decode,
+	})
+	Register(&Codec{
+		Name:        "lzw",
+		ID:          7,
+		Description: "LZW in the .Z format",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return lzw.EncodeZ(input, output, lzw.MaxWidth)
+		},
+		Decode: lzw.DecodeZ,
+		Match:  hasPrefix("\x1f\x9d"),
+	})
+	Register(&Codec{
+		Name:        "snappy",
+		ID:          8,
+		Description: "Snappy framing format",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return snappy.Encode(input, output)
+		},
+		Decode: snappy.Decode,
+		Match:  hasPrefix("\xff\x06\x00\x00sNaPpY"),
+	})
+	Register(&Codec{
+		Name:        "zlib",
+		ID:          9,
+		Description: "zlib data format (RFC 1950)",
+		Encode: func(input io.ReadSeeker, output io.Writer) error {
+			return zlib.Encode(input, output)
+		},
+		Decode: zlib.Decode,
+		Match:  isZlibHeader,
+	})
+}
@@ -0,0 +1,91 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data := tu.ReadFile(testKalevala)[:20000]
+	for _, c := range List() {
+		t.Run(c.Name, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			tu.ExpectNil(t, c.Encode(bytes.NewReader(data), &encoded))
+			header := encoded.Bytes()
+			if len(header) > MaxHeaderSize {
+				header = header[:MaxHeaderSize]
+			}
+			detected, err := Detect(header)
+			if c.Match == nil {
+				if err == nil && detected.Name == c.Name {
+					t.Fatal("detected a codec without a header")
+				}
+			} else {
+				tu.ExpectNil(t, err)
+				tu.Check(t, c.Name, detected.Name)
+			}
+			tu.ExpectNil(t, c.Decode(&encoded, &decoded))
+			if !bytes.Equal(data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the input")
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, c := range List() {
+		found, err := Lookup(c.Name)
+		tu.ExpectNil(t, err)
+		tu.Check(t, c, found)
+		found, err = LookupID(c.ID)
+		tu.ExpectNil(t, err)
+		tu.Check(t, c, found)
+	}
+	_, err := LookupID(0)
+	tu.Check(t, ErrUnknown, err)
+	_, err = Lookup("unknown")
+	tu.Check(t, ErrUnknown, err)
+	_, err = Detect([]byte("plain text"))
+	tu.Check(t, ErrUndetected, err)
+	_, err = Detect(nil)
+	tu.Check(t, ErrUndetected, err)
+}
+
+func TestListOrder(t *testing.T) {
+	codecs := List()
+	tu.Check(t, len(registry), len(codecs))
+	for i := 1; i < len(codecs); i++ {
+		if codecs[i-1].ID >= codecs[i].ID {
+			t.Fatalf("%s is listed before %s", codecs[i-1].Name, codecs[i].Name)
+		}
+	}
+	header := []byte("LZ77\x01")
+	allocs := testing.AllocsPerRun(10, func() {
+		Detect(header)
+	})
+	tu.Check(t, 0.0, allocs)
+}
+
+func TestRegisterDuplicateID(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	Register(&Codec{Name: "new", ID: 1})
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	Register(&Codec{Name: "lz77", ID: 200})
+}
@@ -0,0 +1,256 @@
+/*
+Package container implements a self-describing file format wrapping the output
+of any codec in package codec.
+
+A container is formatted as follows:
+
+	magic "\x89CMP"
+	format version as a byte
+	codec ID as a byte
+	flags as a byte
+	size of the original data as a little endian uint64 value
+	CRC-32 checksum of the original data as a little endian uint32 value
+	original file name if flagName is set
+	modification time if flagModTime is set
+	data compressed using the codec
+
+The file name is stored as its length in bytes as a little endian uint16 value
+followed by the name. The modification time is stored as seconds since the Unix
+epoch as a little endian int64 value. Flags not listed above must be zero.
+
+The size and checksum of the original data are computed before compressing, so
+they are stored in the header and the end of the compressed data needs no
+trailer. If the original data is empty, the container has no compressed data,
+because not all codecs support empty input.
+*/
+package container
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/codec"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// Errors returned by the functions of this package.
+var (
+	ErrMagic    = errors.New("container: not a container file")
+	ErrVersion  = errors.New("container: unsupported format version")
+	ErrCodec    = errors.New("container: unknown compression algorithm")
+	ErrFlags    = errors.New("container: unknown flags")
+	ErrName     = errors.New("container: file name too long")
+	ErrSize     = errors.New("container: decompressed size doesn't match the original size")
+	ErrChecksum = errors.New("container: checksum doesn't match the original data")
+)
+
+// CodecError is returned by DecodeWith if a container was created using
+// another codec than the expected one.
+type CodecError struct {
+	Expected, Found *codec.Codec
+}
+
+func (e *CodecError) Error() string {
+	return "container: data was compressed using " + e.Found.Name + ", not " + e.Expected.Name
+}
+
+// Magic is the magic number at the start of each container.
+const Magic = "\x89CMP"
+
+// These constants describe the layout of the header.
+const (
+	formatVersion = 1
+	// fixedHeaderSize is the size of the header without the optional
+	// fields.
+	fixedHeaderSize = len(Magic) + 3 + 8 + 4
+	flagName        = 1 << 0
+	flagModTime     = 1 << 1
+	knownFlags      = flagName | flagModTime
+	// maxNameLen is the length of the longest file name that can be stored.
+	maxNameLen = 1<<16 - 1
+)
+
+// Header describes the contents of a container.
+type Header struct {
+	Codec   *codec.Codec // Codec used for compressing the data.
+	Size    int64        // Size of the original data.
+	CRC     uint32       // CRC-32 checksum of the original data.
+	Name    string       // Original file name. Empty if not present.
+	ModTime time.Time    // Modification time. Zero if not present.
+}
+
+// Encode compresses data from input using c and writes it to output as a
+// container. The name and modification time in header are stored if they are
+// set. header may be nil. input is read twice: first to compute the size and
+// checksum of the data and then to compress it.
+func Encode(input io.ReadSeeker, output io.Writer, c *codec.Codec, header *Header) error {
+	h := Header{Codec: c}
+	if header != nil {
+		h.Name = header.Name
+		h.ModTime = header.ModTime
+	}
+	if len(h.Name) > maxNameLen {
+		return ErrName
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var crc checksum.CRC32
+	size, err := bufio.NewReader(input).WriteTo(&crc)
+	if err != nil {
+		return err
+	}
+	h.Size = size
+	h.CRC = crc.Sum32()
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := writeHeader(output, &h); err != nil {
+		return err
+	}
+	if h.Size == 0 {
+		return nil
+	}
+	return c.Encode(input, output)
+}
+
+// EncodeFile is like Encode but stores the base name and modification time of
+// file in the header.
+func EncodeFile(file *os.File, output io.Writer, c *codec.Codec) error {
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return Encode(file, output, c, &Header{
+		Name:    filepath.Base(file.Name()),
+		ModTime: stat.ModTime(),
+	})
+}
+
+// writeHeader writes the container header h to w.
+func writeHeader(w io.Writer, h *Header) error {
+	buf := make([]byte, fixedHeaderSize, fixedHeaderSize+2+len(h.Name)+8)
+	slices.CopyBytes(buf, []byte(Magic))
+	buf[len(Magic)] = formatVersion
+	buf[len(Magic)+1] = h.Codec.ID
+	binary.PutUint64LE(buf[len(Magic)+3:], uint64(h.Size))
+	binary.PutUint32LE(buf[len(Magic)+11:], h.CRC)
+	var flags byte
+	if h.Name != "" {
+		flags |= flagName
+		buf = buf[:len(buf)+2+len(h.Name)]
+		binary.PutUint16LE(buf[fixedHeaderSize:], uint16(len(h.Name)))
+		slices.CopyBytes(buf[fixedHeaderSize+2:], []byte(h.Name))
+	}
+	if !h.ModTime.IsZero() {
+		flags |= flagModTime
+		buf = buf[:len(buf)+8]
+		binary.PutUint64LE(buf[len(buf)-8:], uint64(h.ModTime.Unix()))
+	}
+	buf[len(Magic)+2] = flags
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadHeader reads a container header from r. No data past the header is read.
+// The codec of the returned header is looked up in the registry of package
+// codec. io.ErrUnexpectedEOF is returned if the header is truncated.
+func ReadHeader(r io.Reader) (*Header, error) {
+	var fixed [fixedHeaderSize]byte
+	if _, err := bufio.ReadFull(r, fixed[:len(Magic)]); err != nil {
+		return nil, err
+	}
+	if string(fixed[:len(Magic)]) != Magic {
+		return nil, ErrMagic
+	}
+	if _, err := bufio.ReadFull(r, fixed[len(Magic):]); err != nil {
+		return nil, err
+	}
+	if fixed[len(Magic)] != formatVersion {
+		return nil, ErrVersion
+	}
+	c, err := codec.LookupID(fixed[len(Magic)+1])
+	if err != nil {
+		return nil, ErrCodec
+	}
+	flags := fixed[len(Magic)+2]
+	if flags&^knownFlags != 0 {
+		return nil, ErrFlags
+	}
+	h := &Header{
+		Codec: c,
+		Size:  int64(binary.GetUint64LE(fixed[len(Magic)+3:])),
+		CRC:   binary.GetUint32LE(fixed[len(Magic)+11:]),
+	}
+	if flags&flagName != 0 {
+		var length [2]byte
+		if _, err := bufio.ReadFull(r, length[:]); err != nil {
+			return nil, err
+		}
+		name := make([]byte, binary.GetUint16LE(length[:]))
+		if _, err := bufio.ReadFull(r, name); err != nil {
+			return nil, err
+		}
+		h.Name = string(name)
+	}
+	if flags&flagModTime != 0 {
+		var modTime [8]byte
+		if _, err := bufio.ReadFull(r, modTime[:]); err != nil {
+			return nil, err
+		}
+		h.ModTime = time.Unix(int64(binary.GetUint64LE(modTime[:])), 0)
+	}
+	return h, nil
+}
+
+// DecodeBody decompresses the data following a container header h from input
+// using h.Codec and writes it to output. ErrSize or ErrChecksum is returned if
+// the decompressed data doesn't match the header.
+func DecodeBody(input io.Reader, output io.Writer, h *Header) error {
+	if h.Size == 0 {
+		return nil
+	}
+	dst := &checksum.Writer{W: output, Hash: &checksum.CRC32{}}
+	if err := h.Codec.Decode(input, dst); err != nil {
+		return err
+	}
+	if dst.N != h.Size {
+		return ErrSize
+	}
+	if dst.Hash.Sum32() != h.CRC {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// Decode reads a container from input, decompresses its contents and writes
+// them to output. The header of the container is returned.
+func Decode(input io.Reader, output io.Writer) (*Header, error) {
+	h, err := ReadHeader(input)
+	if err != nil {
+		return nil, err
+	}
+	return h, DecodeBody(input, output, h)
+}
+
+// DecodeWith is like Decode but decompresses the data using c. A *CodecError is
+// returned if the container was created using a codec with another ID than c.
+// c may differ from the registered codec with its ID, for example to decode
+// using different options.
+func DecodeWith(input io.Reader, output io.Writer, c *codec.Codec) (*Header, error) {
+	h, err := ReadHeader(input)
+	if err != nil {
+		return nil, err
+	}
+	if h.Codec.ID != c.ID {
+		return h, &CodecError{Expected: c, Found: h.Codec}
+	}
+	h.Codec = c
+	return h, DecodeBody(input, output, h)
+}
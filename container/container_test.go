@@ -0,0 +1,167 @@
+package container
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/codec"
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+)
+
+func encode(t *testing.T, data []byte, name string, header *Header) []byte {
+	t.Helper()
+	c, err := codec.Lookup(name)
+	tu.ExpectNil(t, err)
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded, c, header))
+	return encoded.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := tu.ReadFile(testKalevala)[:20000]
+	modTime := time.Unix(1600000000, 0)
+	for _, c := range codec.List() {
+		for _, input := range [][]byte{data, nil} {
+			encoded := encode(t, input, c.Name, &Header{Name: "kalevala.txt", ModTime: modTime})
+			var decoded bytes.Buffer
+			h, err := Decode(bytes.NewReader(encoded), &decoded)
+			tu.ExpectNil(t, err)
+			if !bytes.Equal(input, decoded.Bytes()) {
+				t.Fatalf("%s: decoded data differs from the input", c.Name)
+			}
+			tu.Check(t, c, h.Codec)
+			tu.Check(t, int64(len(input)), h.Size)
+			tu.Check(t, "kalevala.txt", h.Name)
+			tu.Check(t, true, modTime.Equal(h.ModTime))
+		}
+	}
+}
+
+func TestHeaderLayout(t *testing.T) {
+	encoded := encode(t, []byte("abc"), "lz77", nil)
+	expected := "\x89CMP\x01\x05\x00" +
+		"\x03\x00\x00\x00\x00\x00\x00\x00" +
+		"\xc2\x41\x24\x35" +
+		"LZ77"
+	tu.Check(t, expected, string(encoded[:len(expected)]))
+
+	h, err := ReadHeader(bytes.NewReader(encoded))
+	tu.ExpectNil(t, err)
+	tu.Check(t, "", h.Name)
+	tu.Check(t, true, h.ModTime.IsZero())
+
+	encoded = encode(t, []byte("abc"), "lz77", &Header{Name: "a.txt", ModTime: time.Unix(258, 0)})
+	expected = "\x89CMP\x01\x05\x03" +
+		"\x03\x00\x00\x00\x00\x00\x00\x00" +
+		"\xc2\x41\x24\x35" +
+		"\x05\x00a.txt" +
+		"\x02\x01\x00\x00\x00\x00\x00\x00" +
+		"LZ77"
+	tu.Check(t, expected, string(encoded[:len(expected)]))
+}
+
+func TestInvalidHeaders(t *testing.T) {
+	valid := encode(t, []byte("abc"), "lz77", &Header{Name: "a.txt", ModTime: time.Unix(1, 0)})
+	modified := func(i int, b byte) []byte {
+		data := append([]byte(nil), valid...)
+		data[i] = b
+		return data
+	}
+	cases := []struct {
+		desc     string
+		input    []byte
+		expected error
+	}{
+		{desc: "Empty", input: nil, expected: io.ErrUnexpectedEOF},
+		{desc: "Magic", input: []byte("LZ77\x01\x00"), expected: ErrMagic},
+		{desc: "Version", input: modified(4, 2), expected: ErrVersion},
+		{desc: "Codec", input: modified(5, 0), expected: ErrCodec},
+		{desc: "Flags", input: modified(6, 0x83), expected: ErrFlags},
+		{desc: "TruncatedFixed", input: valid[:10], expected: io.ErrUnexpectedEOF},
+		{desc: "TruncatedName", input: valid[:22], expected: io.ErrUnexpectedEOF},
+		{desc: "TruncatedModTime", input: valid[:28], expected: io.ErrUnexpectedEOF},
+		{desc: "Size", input: modified(7, 4), expected: ErrSize},
+		{desc: "Checksum", input: modified(15, 0), expected: ErrChecksum},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(c.input), ioutil.Discard)
+			tu.Check(t, c.expected, err)
+		})
+	}
+}
+
+func TestWrongCodec(t *testing.T) {
+	// A container claiming to hold data of another codec fails to decode.
+	encoded := encode(t, []byte("abcabcabc"), "lz77", nil)
+	encoded[5] = mustLookup(t, "lz4").ID
+	_, err := Decode(bytes.NewReader(encoded), ioutil.Discard)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestDecodeWith(t *testing.T) {
+	data := []byte("abcabcabc")
+	lz77, lz4 := mustLookup(t, "lz77"), mustLookup(t, "lz4")
+	encoded := encode(t, data, "lz77", nil)
+	// A copy of the codec with the same ID is accepted and used for
+	// decoding.
+	used := false
+	custom := *lz77
+	custom.Decode = func(input io.Reader, output io.Writer) error {
+		used = true
+		return lz77.Decode(input, output)
+	}
+	var decoded bytes.Buffer
+	h, err := DecodeWith(bytes.NewReader(encoded), &decoded, &custom)
+	tu.ExpectNil(t, err)
+	tu.Check(t, true, used)
+	tu.Check(t, string(data), decoded.String())
+	tu.Check(t, &custom, h.Codec)
+
+	_, err = DecodeWith(bytes.NewReader(encoded), ioutil.Discard, lz4)
+	codecErr, ok := err.(*CodecError)
+	if !ok {
+		t.Fatalf("expected *CodecError, found %v", err)
+	}
+	tu.Check(t, lz4, codecErr.Expected)
+	tu.Check(t, lz77, codecErr.Found)
+}
+
+func TestEncodeFile(t *testing.T) {
+	file, err := os.Open(testKalevala)
+	tu.ExpectNil(t, err)
+	defer file.Close()
+	stat, err := file.Stat()
+	tu.ExpectNil(t, err)
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, EncodeFile(file, &encoded, mustLookup(t, "huffman")))
+	h, err := Decode(&encoded, ioutil.Discard)
+	tu.ExpectNil(t, err)
+	tu.Check(t, "kalevala.txt", h.Name)
+	tu.Check(t, stat.ModTime().Unix(), h.ModTime.Unix())
+	tu.Check(t, stat.Size(), h.Size)
+}
+
+func TestNameTooLong(t *testing.T) {
+	c := mustLookup(t, "lz77")
+	name := strings.Repeat("a", maxNameLen+1)
+	tu.Check(t, ErrName, Encode(bytes.NewReader(nil), ioutil.Discard, c, &Header{Name: name}))
+}
+
+func mustLookup(t *testing.T, name string) *codec.Codec {
+	t.Helper()
+	c, err := codec.Lookup(name)
+	tu.ExpectNil(t, err)
+	return c
+}
@@ -0,0 +1,390 @@
+package deflate
+
+import (
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// Decode reads DEFLATE compressed data from input, decompresses it and writes
+// the result to output.
+//
+// If input implements io.ByteReader, Decode doesn't read any bytes past the
+// end of the compressed data. This allows reading data following the
+// compressed data from input after Decode returns.
+func Decode(input io.Reader, output io.Writer) error {
+	var src *bufio.Reader
+	switch r := input.(type) {
+	case *bufio.Reader:
+		src = r
+	case io.ByteReader:
+		src = bufio.NewReader(byteReader{r})
+	default:
+		src = bufio.NewReader(input)
+	}
+	d := &decoder{
+		src: bits.NewReaderBuffered(src, bits.LSB),
+		out: newOutputWindow(output),
+	}
+	for {
+		header, err := d.src.ReadBits(3)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		switch header >> 1 {
+		case blockStored:
+			err = d.decodeStoredBlock()
+		case blockFixed:
+			err = d.decodeBlock(fixedLitLenDecoder, fixedDistDecoder)
+		case blockDynamic:
+			err = d.decodeDynamicBlock()
+		default:
+			err = ErrCorrupt
+		}
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if header&1 != 0 {
+			return d.out.flush()
+		}
+	}
+}
+
+// byteReader reads one byte at a time from an io.ByteReader, so a bufio.Reader
+// reading from it never consumes more bytes than requested.
+type byteReader struct {
+	r io.ByteReader
+}
+
+func (r byteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	p[0] = b
+	return 1, nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF, because the compressed
+// data ends only after the final block.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Decoders for blocks compressed with fixed Huffman codes.
+var (
+	fixedLitLenDecoder, _ = newHuffmanDecoder(fixedLitLenLengths())
+	fixedDistDecoder, _   = newHuffmanDecoder(fixedDistLengths())
+)
+
+// decoder holds the state of an ongoing Decode call.
+type decoder struct {
+	src *bits.Reader
+	out *outputWindow
+}
+
+// decodeStoredBlock decodes a block stored without compression.
+func (d *decoder) decodeStoredBlock() error {
+	d.src.AlignToByte()
+	length, err := d.src.ReadBits(16)
+	if err != nil {
+		return err
+	}
+	nlength, err := d.src.ReadBits(16)
+	if err != nil {
+		return err
+	}
+	if length != ^nlength&0xffff {
+		return ErrCorrupt
+	}
+	for ; length > 0; length-- {
+		b, err := d.src.ReadBits(8)
+		if err != nil {
+			return err
+		}
+		if err := d.out.writeByte(byte(b)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeDynamicBlock reads the code tables of a block compressed with dynamic
+// Huffman codes and decodes the block.
+func (d *decoder) decodeDynamicBlock() error {
+	litLenCount, err := d.src.ReadBits(5)
+	if err != nil {
+		return err
+	}
+	distCount, err := d.src.ReadBits(5)
+	if err != nil {
+		return err
+	}
+	clCount, err := d.src.ReadBits(4)
+	if err != nil {
+		return err
+	}
+	litLenCount += endOfBlock + 1
+	distCount++
+	clCount += 4
+	if litLenCount > litLenCodeCount || distCount > distCodeCount {
+		return ErrCorrupt
+	}
+
+	var clLengths [clCodeCount]uint8
+	for i := 0; i < int(clCount); i++ {
+		length, err := d.src.ReadBits(3)
+		if err != nil {
+			return err
+		}
+		clLengths[clOrder[i]] = uint8(length)
+	}
+	clDecoder, err := newHuffmanDecoder(clLengths[:])
+	if err != nil {
+		return err
+	}
+
+	lengths := make([]uint8, litLenCount+distCount)
+	for i := 0; i < len(lengths); {
+		sym, err := clDecoder.decodeSymbol(d.src)
+		if err != nil {
+			return err
+		}
+		if sym < 16 {
+			lengths[i] = uint8(sym)
+			i++
+			continue
+		}
+		repeated := uint8(0)
+		var count uint64
+		switch sym {
+		case 16:
+			if i == 0 {
+				return ErrCorrupt
+			}
+			repeated = lengths[i-1]
+			count, err = d.src.ReadBits(2)
+			count += 3
+		case 17:
+			count, err = d.src.ReadBits(3)
+			count += 3
+		default:
+			count, err = d.src.ReadBits(7)
+			count += 11
+		}
+		if err != nil {
+			return err
+		}
+		if i+int(count) > len(lengths) {
+			return ErrCorrupt
+		}
+		for ; count > 0; count-- {
+			lengths[i] = repeated
+			i++
+		}
+	}
+	if lengths[endOfBlock] == 0 {
+		return ErrCorrupt
+	}
+
+	litLenDecoder, err := newHuffmanDecoder(lengths[:litLenCount])
+	if err != nil {
+		return err
+	}
+	distDecoder, err := newHuffmanDecoder(lengths[litLenCount:])
+	if err != nil {
+		return err
+	}
+	return d.decodeBlock(litLenDecoder, distDecoder)
+}
+
+// decodeBlock decodes the data of a block compressed with Huffman codes using
+// the given decoders.
+func (d *decoder) decodeBlock(litLen, dist *huffmanDecoder) error {
+	for {
+		sym, err := litLen.decodeSymbol(d.src)
+		if err != nil {
+			return err
+		}
+		if sym < literalCount {
+			if err := d.out.writeByte(byte(sym)); err != nil {
+				return err
+			}
+			continue
+		}
+		if sym == endOfBlock {
+			return nil
+		}
+		sym -= endOfBlock + 1
+		if sym >= len(lengthBase) {
+			return ErrCorrupt
+		}
+		extra, err := d.src.ReadBits(uint(lengthExtra[sym]))
+		if err != nil {
+			return err
+		}
+		length := int(lengthBase[sym]) + int(extra)
+
+		sym, err = dist.decodeSymbol(d.src)
+		if err != nil {
+			return err
+		}
+		if sym >= len(distBase) {
+			return ErrCorrupt
+		}
+		extra, err = d.src.ReadBits(uint(distExtra[sym]))
+		if err != nil {
+			return err
+		}
+		distance := int(distBase[sym]) + int(extra)
+
+		if err := d.out.copyMatch(length, distance); err != nil {
+			return err
+		}
+	}
+}
+
+// huffmanDecoder decodes symbols encoded using a canonical Huffman code.
+type huffmanDecoder struct {
+	// counts[i] is the number of codes of length i.
+	counts [maxCodeLength + 1]int
+	// symbols contains the symbols ordered by their codes.
+	symbols []int
+}
+
+// newHuffmanDecoder returns a decoder for the canonical Huffman code with
+// code lengths in lengths. ErrCorrupt is returned if the lengths don't
+// describe a valid prefix code. Incomplete codes are allowed.
+func newHuffmanDecoder(lengths []uint8) (*huffmanDecoder, error) {
+	h := &huffmanDecoder{}
+	symbolCount := 0
+	for i := 0; i < len(lengths); i++ {
+		if lengths[i] > maxCodeLength {
+			return nil, ErrCorrupt
+		}
+		h.counts[lengths[i]]++
+		if lengths[i] > 0 {
+			symbolCount++
+		}
+	}
+	left := 1
+	for length := 1; length <= maxCodeLength; length++ {
+		left <<= 1
+		left -= h.counts[length]
+		if left < 0 {
+			return nil, ErrCorrupt
+		}
+	}
+	var offsets [maxCodeLength + 1]int
+	for length := 1; length < maxCodeLength; length++ {
+		offsets[length+1] = offsets[length] + h.counts[length]
+	}
+	h.symbols = make([]int, symbolCount)
+	for symbol := 0; symbol < len(lengths); symbol++ {
+		if lengths[symbol] > 0 {
+			h.symbols[offsets[lengths[symbol]]] = symbol
+			offsets[lengths[symbol]]++
+		}
+	}
+	return h, nil
+}
+
+// decodeSymbol reads a code from r and returns the corresponding symbol.
+func (h *huffmanDecoder) decodeSymbol(r *bits.Reader) (int, error) {
+	code := 0  // the code read so far
+	first := 0 // the first code of the current length
+	index := 0 // the index of the first code of the current length in symbols
+	for length := 1; length <= maxCodeLength; length++ {
+		bit, err := r.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit {
+			code |= 1
+		}
+		count := h.counts[length]
+		if code-first < count {
+			return h.symbols[index+code-first], nil
+		}
+		index += count
+		first = (first + count) << 1
+		code <<= 1
+	}
+	return 0, ErrCorrupt
+}
+
+// outputWindow buffers decompressed data before writing it to the output and
+// keeps track of the most recent windowSize bytes to allow expanding matches.
+type outputWindow struct {
+	w io.Writer
+	// buf stores the data. It has space for two windows so that sliding the
+	// window needs to be done less often.
+	buf []byte
+	// pos is the end of data in buf. Bytes in range [flushed, pos) haven't
+	// been written to w yet.
+	pos, flushed int
+	// total is the total number of bytes written to the window.
+	total int64
+}
+
+// newOutputWindow returns an outputWindow writing to w.
+func newOutputWindow(w io.Writer) *outputWindow {
+	return &outputWindow{
+		w:   w,
+		buf: make([]byte, 2*windowSize),
+	}
+}
+
+// writeByte writes b to the window.
+func (w *outputWindow) writeByte(b byte) error {
+	if w.pos == len(w.buf) {
+		if err := w.slide(); err != nil {
+			return err
+		}
+	}
+	w.buf[w.pos] = b
+	w.pos++
+	w.total++
+	return nil
+}
+
+// copyMatch copies length bytes starting distance bytes back from the end of
+// the window to the end of the window.
+func (w *outputWindow) copyMatch(length, distance int) error {
+	if int64(distance) > w.total || distance > windowSize {
+		return ErrCorrupt
+	}
+	for i := 0; i < length; i++ {
+		if err := w.writeByte(w.buf[w.pos-distance]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slide flushes the window and moves the most recent windowSize bytes to the
+// beginning of the buffer.
+func (w *outputWindow) slide() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	slices.CopyBytes(w.buf, w.buf[w.pos-windowSize:w.pos])
+	w.pos = windowSize
+	w.flushed = windowSize
+	return nil
+}
+
+// flush writes all unwritten data to the underlying writer.
+func (w *outputWindow) flush() error {
+	_, err := w.w.Write(w.buf[w.flushed:w.pos])
+	w.flushed = w.pos
+	return err
+}
@@ -0,0 +1,106 @@
+/*
+Package deflate implements the DEFLATE compressed data format described in RFC
+1951. Data can be encoded and decoded using Encode and Decode, respectively.
+
+Encode splits the data into blocks and writes each block using whichever of the
+three block types (stored, fixed Huffman codes or dynamic Huffman codes)
+produces the smallest output. Repeated byte sequences are found using the match
+finding of package lz77 and dynamic Huffman codes are constructed using package
+huffman.
+
+The output of Encode is raw DEFLATE data without any container format and is
+compatible with other DEFLATE implementations.
+*/
+package deflate
+
+import "errors"
+
+// ErrCorrupt is returned by Decode if the input is not valid DEFLATE data.
+var ErrCorrupt = errors.New("deflate: corrupt input")
+
+// These constants specify the limits of the format.
+const (
+	windowSize      = 1 << 15
+	minMatchLength  = 3
+	maxMatchLength  = 258
+	maxCodeLength   = 15
+	maxCLCodeLength = 7
+	maxStoredLength = 1<<16 - 1
+)
+
+// These constants specify the sizes of the alphabets.
+const (
+	literalCount    = 256
+	endOfBlock      = 256
+	litLenCodeCount = 286
+	distCodeCount   = 30
+	clCodeCount     = 19
+)
+
+// These constants are the values of the BTYPE field of a block header.
+const (
+	blockStored  = 0
+	blockFixed   = 1
+	blockDynamic = 2
+)
+
+// lengthBase and lengthExtra specify the smallest match length and the number
+// of extra bits for each length code starting from code 257.
+var (
+	lengthBase = [...]uint16{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51,
+		59, 67, 83, 99, 115, 131, 163, 195, 227, 258,
+	}
+	lengthExtra = [...]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4,
+		5, 5, 5, 5, 0,
+	}
+)
+
+// distBase and distExtra specify the smallest distance and the number of extra
+// bits for each distance code.
+var (
+	distBase = [...]uint16{
+		1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385,
+		513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385,
+		24577,
+	}
+	distExtra = [...]uint8{
+		0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10,
+		10, 11, 11, 12, 12, 13, 13,
+	}
+)
+
+// clOrder is the order in which code length code lengths are stored in a
+// dynamic block header.
+var clOrder = [clCodeCount]uint8{
+	16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15,
+}
+
+// fixedLitLenLengths returns the code lengths of the fixed literal/length
+// code.
+func fixedLitLenLengths() []uint8 {
+	lengths := make([]uint8, 288)
+	for i := 0; i < len(lengths); i++ {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	return lengths
+}
+
+// fixedDistLengths returns the code lengths of the fixed distance code.
+func fixedDistLengths() []uint8 {
+	lengths := make([]uint8, 32)
+	for i := 0; i < len(lengths); i++ {
+		lengths[i] = 5
+	}
+	return lengths
+}
@@ -0,0 +1,185 @@
+package deflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+	testSum      = "../test/files/sum"
+)
+
+func TestEncodingAndDecoding(t *testing.T) {
+	// Encoded data must decode using both compress/flate and Decode.
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		// Short inputs are cheapest to encode using fixed Huffman codes.
+		{desc: "Fixed", data: []byte("abcabcabcabc")},
+		// Random data doesn't compress, so it is written as stored blocks.
+		// A stored block holds at most 65535 bytes.
+		{desc: "Stored", data: random},
+		{desc: "Dynamic", data: tu.ReadFile(testKalevala)},
+		// A run is encoded as matches of the maximum length 258.
+		{desc: "LongMatches", data: bytes.Repeat([]byte{'x'}, 100000)},
+		// The second copy refers to the first one near the end of the
+		// 32 KiB window.
+		{desc: "FarMatches", data: bytes.Repeat(random[:windowSize-100], 2)},
+		{desc: "Binary", data: tu.ReadFile(testSum)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded bytes.Buffer
+			tu.ExpectNil(t, Encode(bytes.NewReader(c.data), &encoded))
+			decoded, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(encoded.Bytes())))
+			tu.ExpectNil(t, err)
+			if !bytes.Equal(c.data, decoded) {
+				t.Fatal("decoded data differs from the original")
+			}
+			var ours bytes.Buffer
+			tu.ExpectNil(t, Decode(&encoded, &ours))
+			if !bytes.Equal(c.data, ours.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecodeStdlibOutput(t *testing.T) {
+	levels := []struct {
+		desc  string
+		level int
+	}{
+		{desc: "Stored", level: flate.NoCompression},
+		{desc: "BestSpeed", level: flate.BestSpeed},
+		{desc: "Default", level: flate.DefaultCompression},
+		{desc: "BestCompression", level: flate.BestCompression},
+		{desc: "HuffmanOnly", level: flate.HuffmanOnly},
+	}
+	data := tu.ReadFile(testKalevala)
+	for _, l := range levels {
+		t.Run(l.desc, func(t *testing.T) {
+			var encoded bytes.Buffer
+			w, err := flate.NewWriter(&encoded, l.level)
+			tu.ExpectNil(t, err)
+			_, err = w.Write(data)
+			tu.ExpectNil(t, err)
+			tu.ExpectNil(t, w.Close())
+			var decoded bytes.Buffer
+			tu.ExpectNil(t, Decode(&encoded, &decoded))
+			if !bytes.Equal(data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecodeBlocks(t *testing.T) {
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected string
+	}{
+		// A fixed block containing only the end of block code.
+		{desc: "EmptyFixed", data: []byte{0x03, 0x00}, expected: ""},
+		{desc: "Stored", data: []byte("\x01\x03\x00\xfc\xffabc"), expected: "abc"},
+		// A non-final stored block followed by the final one.
+		{
+			desc:     "TwoStored",
+			data:     []byte("\x00\x02\x00\xfd\xffab\x01\x01\x00\xfe\xffc"),
+			expected: "abc",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var decoded bytes.Buffer
+			tu.ExpectNil(t, Decode(bytes.NewReader(c.data), &decoded))
+			tu.Check(t, c.expected, decoded.String())
+		})
+	}
+}
+
+func TestDecodeStopsAtEndOfData(t *testing.T) {
+	data := []byte("abcabcabcabc")
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded))
+	encoded.WriteString("trailer")
+	var decoded bytes.Buffer
+	tu.ExpectNil(t, Decode(&encoded, &decoded))
+	tu.Check(t, string(data), decoded.String())
+	tu.Check(t, "trailer", encoded.String())
+}
+
+func TestDecodeCorruptInput(t *testing.T) {
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{
+			desc:     "Empty",
+			data:     []byte{},
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			desc:     "InvalidBlockType",
+			data:     []byte{0x07},
+			expected: ErrCorrupt,
+		},
+		{
+			desc:     "StoredLengthMismatch",
+			data:     []byte{0x01, 0x01, 0x00, 0x00, 0x00, 'a'},
+			expected: ErrCorrupt,
+		},
+		{
+			// A fixed block with a match of length 3 at distance 1 before
+			// any data has been written.
+			desc:     "DistanceTooFar",
+			data:     []byte{0x03, 0x02, 0x00},
+			expected: ErrCorrupt,
+		},
+		{
+			desc:     "Truncated",
+			data:     []byte{0x4b, 0x4c},
+			expected: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := Decode(bytes.NewReader(c.data), ioutil.Discard)
+			tu.Check(t, c.expected, err)
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	input := tu.ReadFile(testKalevala)
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		Encode(r, ioutil.Discard)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	var encoded bytes.Buffer
+	Encode(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded)
+	r := bytes.NewReader(encoded.Bytes())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(encoded.Bytes())
+		Decode(r, ioutil.Discard)
+	}
+}
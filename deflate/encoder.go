@@ -0,0 +1,478 @@
+package deflate
+
+import (
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/huffman"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// maxBlockTokens is the maximum number of literals and matches in a block.
+const maxBlockTokens = 1 << 14
+
+// huffmanCode is a single code of a Huffman code table.
+type huffmanCode struct {
+	// reversed contains the bits of the code in reverse order, so that the
+	// first bit of the code is the least significant bit.
+	reversed uint16
+	length   uint8
+}
+
+// writeCode writes the Huffman code c to w. Huffman codes are written starting
+// from the most significant bit of the code.
+func writeCode(w *bits.Writer, c huffmanCode) error {
+	return w.WriteBits(uint64(c.reversed), uint(c.length))
+}
+
+// newHuffmanCodes returns the canonical Huffman codes corresponding to
+// lengths.
+func newHuffmanCodes(lengths []uint8) []huffmanCode {
+	lists := huffman.CanonicalCodes(lengths)
+	codes := make([]huffmanCode, len(lists))
+	for i := 0; i < len(lists); i++ {
+		codes[i] = newHuffmanCode(&lists[i])
+	}
+	return codes
+}
+
+// newHuffmanCode converts code to a huffmanCode.
+func newHuffmanCode(code *bits.List) huffmanCode {
+	c := huffmanCode{length: uint8(code.Len())}
+	for i := 0; i < code.Len(); i++ {
+		if code.Get(i) {
+			c.reversed |= 1 << uint(i)
+		}
+	}
+	return c
+}
+
+// Code tables of blocks compressed with fixed Huffman codes.
+var (
+	fixedLitLenCodes = newHuffmanCodes(fixedLitLenLengths())
+	fixedDistCodes   = newHuffmanCodes(fixedDistLengths())
+)
+
+// lengthCodes maps match lengths to length codes. The codes are relative to
+// code 257.
+var lengthCodes = newLengthCodes()
+
+// newLengthCodes returns the table used as lengthCodes.
+func newLengthCodes() []uint8 {
+	codes := make([]uint8, maxMatchLength+1)
+	for code := 0; code < len(lengthBase); code++ {
+		for l := int(lengthBase[code]); l <= maxMatchLength; l++ {
+			codes[l] = uint8(code)
+		}
+	}
+	return codes
+}
+
+// distCode returns the distance code corresponding to distance.
+func distCode(distance int) int {
+	code := len(distBase) - 1
+	for int(distBase[code]) > distance {
+		code--
+	}
+	return code
+}
+
+// token is either a literal byte or a match referring to earlier data.
+type token struct {
+	literal byte
+	// length is 0 for literals.
+	length, distance uint16
+}
+
+// encoder holds the state of an ongoing Encode call.
+type encoder struct {
+	src     *bufio.Reader
+	dst     *bits.Writer
+	matcher *lz77.Matcher
+	tokens  []token
+	// raw contains the uncompressed contents of the current block.
+	raw []byte
+}
+
+// Encode reads data from input, compresses it using DEFLATE and writes the
+// result to output.
+func Encode(input io.Reader, output io.Writer) error {
+	e := &encoder{
+		src:     bufio.NewReader(input),
+		dst:     bits.NewWriterOrder(output, bits.LSB),
+		matcher: lz77.NewMatcher(windowSize),
+		tokens:  make([]token, 0, maxBlockTokens),
+		raw:     make([]byte, 0, maxStoredLength),
+	}
+	for {
+		final, err := e.readBlock()
+		if err != nil {
+			return err
+		}
+		if err := e.writeBlock(final); err != nil {
+			return err
+		}
+		if final {
+			return e.dst.Flush()
+		}
+	}
+}
+
+// readBlock reads the contents of the next block from the input and splits it
+// into tokens. final is true if the end of the input was reached.
+func (e *encoder) readBlock() (final bool, err error) {
+	e.tokens = e.tokens[:0]
+	e.raw = e.raw[:0]
+	for len(e.tokens) < cap(e.tokens) &&
+		len(e.raw)+maxMatchLength <= cap(e.raw) {
+		lookahead, err := e.src.Peek(maxMatchLength)
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if len(lookahead) == 0 {
+			if err == io.EOF {
+				return true, nil
+			}
+			continue
+		}
+		if len(lookahead) > maxMatchLength {
+			lookahead = lookahead[:maxMatchLength]
+		}
+		length, distance := e.matcher.FindMatch(lookahead)
+		if length < minMatchLength {
+			length = 1
+			e.addToken(token{literal: lookahead[0]})
+		} else {
+			e.addToken(token{
+				length:   uint16(length),
+				distance: uint16(distance),
+			})
+		}
+		e.matcher.Append(lookahead[:length])
+		e.raw = e.raw[:len(e.raw)+length]
+		slices.CopyBytes(e.raw[len(e.raw)-length:], lookahead[:length])
+		if _, err := e.src.Discard(length); err != nil {
+			return false, err
+		}
+	}
+	for {
+		next, err := e.src.Peek(1)
+		if len(next) > 0 {
+			return false, nil
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// addToken appends tok to the tokens of the current block.
+func (e *encoder) addToken(tok token) {
+	e.tokens = e.tokens[:len(e.tokens)+1]
+	e.tokens[len(e.tokens)-1] = tok
+}
+
+// writeBlock writes the current block using the block type producing the
+// smallest output. final specifies whether the block is the last one.
+func (e *encoder) writeBlock(final bool) error {
+	var litLenFreqs [litLenCodeCount]int64
+	var distFreqs [distCodeCount]int64
+	for i := 0; i < len(e.tokens); i++ {
+		tok := e.tokens[i]
+		if tok.length == 0 {
+			litLenFreqs[tok.literal]++
+		} else {
+			litLenFreqs[endOfBlock+1+int(lengthCodes[tok.length])]++
+			distFreqs[distCode(int(tok.distance))]++
+		}
+	}
+	litLenFreqs[endOfBlock]++
+
+	// Some decoders reject code tables with a single code, so at least two
+	// codes are always defined.
+	ensureTwoCodes(litLenFreqs[:])
+	ensureTwoCodes(distFreqs[:])
+	header := newDynamicHeader(
+		huffman.CodeLengths(litLenFreqs[:], maxCodeLength),
+		huffman.CodeLengths(distFreqs[:], maxCodeLength),
+	)
+
+	dynamicSize := 3 + header.size() +
+		dataSize(&litLenFreqs, &distFreqs, header.litLenLengths, header.distLengths)
+	fixedSize := 3 + dataSize(
+		&litLenFreqs, &distFreqs, fixedLitLenLengths(), fixedDistLengths())
+	storedSize := 3 + 7 + 32 + 8*int64(len(e.raw))
+
+	if storedSize <= fixedSize && storedSize <= dynamicSize {
+		return e.writeStoredBlock(final)
+	}
+	if fixedSize <= dynamicSize {
+		if err := e.writeBlockHeader(final, blockFixed); err != nil {
+			return err
+		}
+		return e.writeTokens(fixedLitLenCodes, fixedDistCodes)
+	}
+	if err := e.writeBlockHeader(final, blockDynamic); err != nil {
+		return err
+	}
+	if err := header.writeTo(e.dst); err != nil {
+		return err
+	}
+	return e.writeTokens(
+		newHuffmanCodes(header.litLenLengths),
+		newHuffmanCodes(header.distLengths),
+	)
+}
+
+// ensureTwoCodes makes sure that at least two symbols have non-zero
+// frequencies in freqs.
+func ensureTwoCodes(freqs []int64) {
+	used := 0
+	for i := 0; i < len(freqs); i++ {
+		if freqs[i] > 0 {
+			used++
+		}
+	}
+	for i := 0; used < 2; i++ {
+		if freqs[i] == 0 {
+			freqs[i] = 1
+			used++
+		}
+	}
+}
+
+// dataSize returns the size in bits of the compressed data of a block with
+// the given symbol frequencies and code lengths, excluding the block header.
+func dataSize(
+	litLenFreqs *[litLenCodeCount]int64,
+	distFreqs *[distCodeCount]int64,
+	litLenLengths, distLengths []uint8,
+) int64 {
+	size := int64(0)
+	for i := 0; i < len(litLenFreqs); i++ {
+		size += litLenFreqs[i] * int64(litLenLengths[i])
+		if i > endOfBlock {
+			size += litLenFreqs[i] * int64(lengthExtra[i-endOfBlock-1])
+		}
+	}
+	for i := 0; i < len(distFreqs); i++ {
+		size += distFreqs[i] * int64(distLengths[i]+distExtra[i])
+	}
+	return size
+}
+
+// writeBlockHeader writes the 3-bit header of a block.
+func (e *encoder) writeBlockHeader(final bool, blockType uint64) error {
+	finalBit := uint64(0)
+	if final {
+		finalBit = 1
+	}
+	return e.dst.WriteBits(finalBit|blockType<<1, 3)
+}
+
+// writeStoredBlock writes the current block without compression.
+func (e *encoder) writeStoredBlock(final bool) error {
+	if err := e.writeBlockHeader(final, blockStored); err != nil {
+		return err
+	}
+	if err := e.dst.AlignToByte(); err != nil {
+		return err
+	}
+	length := uint64(len(e.raw))
+	if err := e.dst.WriteBits(length, 16); err != nil {
+		return err
+	}
+	if err := e.dst.WriteBits(^length&0xffff, 16); err != nil {
+		return err
+	}
+	return e.dst.WriteAlignedBytes(e.raw)
+}
+
+// writeTokens writes the tokens of the current block followed by the end of
+// block code using the given code tables.
+func (e *encoder) writeTokens(litLenCodes, distCodes []huffmanCode) error {
+	for i := 0; i < len(e.tokens); i++ {
+		tok := e.tokens[i]
+		if tok.length == 0 {
+			if err := writeCode(e.dst, litLenCodes[tok.literal]); err != nil {
+				return err
+			}
+			continue
+		}
+		code := lengthCodes[tok.length]
+		err := writeCode(e.dst, litLenCodes[endOfBlock+1+int(code)])
+		if err != nil {
+			return err
+		}
+		err = e.dst.WriteBits(
+			uint64(tok.length-lengthBase[code]), uint(lengthExtra[code]))
+		if err != nil {
+			return err
+		}
+		dcode := distCode(int(tok.distance))
+		if err := writeCode(e.dst, distCodes[dcode]); err != nil {
+			return err
+		}
+		err = e.dst.WriteBits(
+			uint64(tok.distance-distBase[dcode]), uint(distExtra[dcode]))
+		if err != nil {
+			return err
+		}
+	}
+	return writeCode(e.dst, litLenCodes[endOfBlock])
+}
+
+// clSymbol is a symbol of the code length alphabet used to encode the code
+// lengths in a dynamic block header.
+type clSymbol struct {
+	symbol uint8
+	// extra is the value of the extra bits of repeat symbols 16, 17 and 18.
+	extra uint8
+}
+
+// clExtraBits is the number of extra bits of each repeat symbol.
+var clExtraBits = [...]uint8{16: 2, 17: 3, 18: 7}
+
+// dynamicHeader is the code table definition of a block compressed with
+// dynamic Huffman codes.
+type dynamicHeader struct {
+	litLenLengths, distLengths []uint8
+	// litLenCount and distCount are the numbers of stored code lengths.
+	litLenCount, distCount int
+	symbols                []clSymbol
+	clLengths              []uint8
+	clCount                int
+}
+
+// newDynamicHeader returns the dynamic block header for the given code
+// lengths.
+func newDynamicHeader(litLenLengths, distLengths []uint8) *dynamicHeader {
+	h := &dynamicHeader{
+		litLenLengths: litLenLengths,
+		distLengths:   distLengths,
+		litLenCount:   trimmedLength(litLenLengths, endOfBlock+1),
+		distCount:     trimmedLength(distLengths, 1),
+	}
+	lengths := make([]uint8, h.litLenCount+h.distCount)
+	slices.CopyBytes(lengths, litLenLengths[:h.litLenCount])
+	slices.CopyBytes(lengths[h.litLenCount:], distLengths[:h.distCount])
+	h.symbols = encodeCodeLengths(lengths)
+
+	var clFreqs [clCodeCount]int64
+	for i := 0; i < len(h.symbols); i++ {
+		clFreqs[h.symbols[i].symbol]++
+	}
+	h.clLengths = huffman.CodeLengths(clFreqs[:], maxCLCodeLength)
+	h.clCount = clCodeCount
+	for h.clCount > 4 && h.clLengths[clOrder[h.clCount-1]] == 0 {
+		h.clCount--
+	}
+	return h
+}
+
+// trimmedLength returns the length of lengths without trailing zeros, but at
+// least min.
+func trimmedLength(lengths []uint8, min int) int {
+	n := len(lengths)
+	for n > min && lengths[n-1] == 0 {
+		n--
+	}
+	return n
+}
+
+// encodeCodeLengths run-length encodes lengths using the code length
+// alphabet.
+func encodeCodeLengths(lengths []uint8) []clSymbol {
+	symbols := make([]clSymbol, 0, len(lengths))
+	add := func(symbol, extra uint8) {
+		symbols = symbols[:len(symbols)+1]
+		symbols[len(symbols)-1] = clSymbol{symbol: symbol, extra: extra}
+	}
+	for i := 0; i < len(lengths); {
+		length := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == length {
+			run++
+		}
+		i += run
+		if length == 0 {
+			for run >= 11 {
+				n := run
+				if n > 138 {
+					n = 138
+				}
+				add(18, uint8(n-11))
+				run -= n
+			}
+			if run >= 3 {
+				add(17, uint8(run-3))
+				run = 0
+			}
+		} else {
+			add(length, 0)
+			run--
+			for run >= 3 {
+				n := run
+				if n > 6 {
+					n = 6
+				}
+				add(16, uint8(n-3))
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			add(length, 0)
+		}
+	}
+	return symbols
+}
+
+// size returns the size of h in bits.
+func (h *dynamicHeader) size() int64 {
+	size := int64(5 + 5 + 4 + 3*h.clCount)
+	for i := 0; i < len(h.symbols); i++ {
+		sym := h.symbols[i].symbol
+		size += int64(h.clLengths[sym])
+		if sym >= 16 {
+			size += int64(clExtraBits[sym])
+		}
+	}
+	return size
+}
+
+// writeTo writes h to w.
+func (h *dynamicHeader) writeTo(w *bits.Writer) error {
+	if err := w.WriteBits(uint64(h.litLenCount-endOfBlock-1), 5); err != nil {
+		return err
+	}
+	if err := w.WriteBits(uint64(h.distCount-1), 5); err != nil {
+		return err
+	}
+	if err := w.WriteBits(uint64(h.clCount-4), 4); err != nil {
+		return err
+	}
+	for i := 0; i < h.clCount; i++ {
+		if err := w.WriteBits(uint64(h.clLengths[clOrder[i]]), 3); err != nil {
+			return err
+		}
+	}
+	clCodes := newHuffmanCodes(h.clLengths)
+	for i := 0; i < len(h.symbols); i++ {
+		sym := h.symbols[i]
+		if err := writeCode(w, clCodes[sym.symbol]); err != nil {
+			return err
+		}
+		if sym.symbol >= 16 {
+			err := w.WriteBits(uint64(sym.extra), uint(clExtraBits[sym.symbol]))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+/*
+Package gzip implements the gzip file format described in RFC 1952.
+
+A gzip file consists of one or more members. Each member is formatted as
+follows:
+
+	header containing optional metadata, such as the original file name and
+	modification time
+	DEFLATE compressed data
+	CRC-32 of the uncompressed data as a little-endian uint32 value
+	size of the uncompressed data modulo 2^32 as a little-endian uint32 value
+
+Encode writes a single member. Decode decodes all members in the input and
+concatenates their contents.
+*/
+package gzip
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/deflate"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// Errors returned by Decode.
+var (
+	ErrHeader   = errors.New("gzip: invalid header")
+	ErrChecksum = errors.New("gzip: invalid checksum")
+)
+
+// These constants are the magic bytes at the start of each member.
+const (
+	magic1 = 0x1f
+	magic2 = 0x8b
+)
+
+// methodDeflate is the only compression method defined by the format.
+const methodDeflate = 8
+
+// These constants are the bits of the FLG field of a member header.
+const (
+	flagText    = 1 << 0
+	flagHCRC    = 1 << 1
+	flagExtra   = 1 << 2
+	flagName    = 1 << 3
+	flagComment = 1 << 4
+)
+
+// OSUnknown is the value of Header.OS used when the operating system is not
+// known.
+const OSUnknown = 255
+
+// Header contains the metadata stored in a member header.
+type Header struct {
+	Name    string    // Original file name. Empty if not present.
+	Comment string    // File comment. Empty if not present.
+	Extra   []byte    // Extra field. nil if not present.
+	ModTime time.Time // Modification time. Zero if not present.
+	OS      byte      // Operating system on which compression took place.
+}
+
+// Encode compresses data from input and writes it to output as a single gzip
+// member. The metadata in header is stored in the member header. header may
+// be nil, in which case no metadata is stored.
+func Encode(input io.Reader, output io.Writer, header *Header) error {
+	if header == nil {
+		header = &Header{OS: OSUnknown}
+	}
+	if err := writeHeader(output, header); err != nil {
+		return err
+	}
+	src := &checksum.Reader{R: input, Hash: &checksum.CRC32{}}
+	if err := deflate.Encode(src, output); err != nil {
+		return err
+	}
+	var trailer [8]byte
+	binary.PutUint32LE(trailer[:4], src.Hash.Sum32())
+	binary.PutUint32LE(trailer[4:], uint32(src.N))
+	_, err := output.Write(trailer[:])
+	return err
+}
+
+// writeHeader writes a member header containing h to w.
+func writeHeader(w io.Writer, h *Header) error {
+	for i := 0; i < len(h.Name); i++ {
+		if h.Name[i] == 0 {
+			return errors.New("gzip: file name contains a NUL byte")
+		}
+	}
+	for i := 0; i < len(h.Comment); i++ {
+		if h.Comment[i] == 0 {
+			return errors.New("gzip: comment contains a NUL byte")
+		}
+	}
+	if len(h.Extra) > 0xffff {
+		return errors.New("gzip: extra field is too long")
+	}
+	dst := bufio.NewWriter(w)
+	var fixed [10]byte
+	fixed[0] = magic1
+	fixed[1] = magic2
+	fixed[2] = methodDeflate
+	if h.Extra != nil {
+		fixed[3] |= flagExtra
+	}
+	if h.Name != "" {
+		fixed[3] |= flagName
+	}
+	if h.Comment != "" {
+		fixed[3] |= flagComment
+	}
+	if !h.ModTime.IsZero() && h.ModTime.Unix() > 0 {
+		binary.PutUint32LE(fixed[4:8], uint32(h.ModTime.Unix()))
+	}
+	fixed[9] = h.OS
+	if _, err := dst.Write(fixed[:]); err != nil {
+		return err
+	}
+	if h.Extra != nil {
+		var length [2]byte
+		binary.PutUint16LE(length[:], uint16(len(h.Extra)))
+		if _, err := dst.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := dst.Write(h.Extra); err != nil {
+			return err
+		}
+	}
+	if h.Name != "" {
+		if err := writeString(dst, h.Name); err != nil {
+			return err
+		}
+	}
+	if h.Comment != "" {
+		if err := writeString(dst, h.Comment); err != nil {
+			return err
+		}
+	}
+	return dst.Flush()
+}
+
+// writeString writes s to w as a zero-terminated string.
+func writeString(w *bufio.Writer, s string) error {
+	for i := 0; i < len(s); i++ {
+		if err := w.WriteByte(s[i]); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte(0)
+}
+
+// Decode decodes all gzip members from input and writes the concatenation of
+// their contents to output. The headers of the members are returned in the
+// order they appear in the input.
+func Decode(input io.Reader, output io.Writer) ([]Header, error) {
+	src := bufio.NewReader(input)
+	var headers []Header
+	for {
+		header, err := readHeader(src)
+		if err != nil {
+			if err == io.EOF && len(headers) > 0 {
+				return headers, nil
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return headers, err
+		}
+		headers = appendHeader(headers, header)
+		dst := &checksum.Writer{W: output, Hash: &checksum.CRC32{}}
+		if err := deflate.Decode(src, dst); err != nil {
+			return headers, err
+		}
+		var trailer [8]byte
+		if _, err := bufio.ReadFull(src, trailer[:]); err != nil {
+			return headers, err
+		}
+		if binary.GetUint32LE(trailer[:4]) != dst.Hash.Sum32() ||
+			binary.GetUint32LE(trailer[4:]) != uint32(dst.N) {
+			return headers, ErrChecksum
+		}
+	}
+}
+
+// appendHeader appends h to headers and returns the resulting slice.
+func appendHeader(headers []Header, h *Header) []Header {
+	if len(headers) == cap(headers) {
+		newHeaders := make([]Header, len(headers), 2*cap(headers)+1)
+		for i := 0; i < len(headers); i++ {
+			newHeaders[i] = headers[i]
+		}
+		headers = newHeaders
+	}
+	headers = headers[:len(headers)+1]
+	headers[len(headers)-1] = *h
+	return headers
+}
+
+// readHeader reads a member header from r. io.EOF is returned if r contains
+// no more data.
+func readHeader(r *bufio.Reader) (*Header, error) {
+	// All header bytes are fed to crc to allow verifying the header CRC.
+	crc := &checksum.CRC32{}
+	var fixed [10]byte
+	n, err := bufio.ReadFull(r, fixed[:])
+	if err != nil {
+		if n == 0 && err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	crc.Write(fixed[:])
+	if fixed[0] != magic1 || fixed[1] != magic2 || fixed[2] != methodDeflate {
+		return nil, ErrHeader
+	}
+	flags := fixed[3]
+	h := &Header{OS: fixed[9]}
+	if mtime := binary.GetUint32LE(fixed[4:8]); mtime != 0 {
+		h.ModTime = time.Unix(int64(mtime), 0)
+	}
+	if flags&flagExtra != 0 {
+		var length [2]byte
+		if _, err := bufio.ReadFull(r, length[:]); err != nil {
+			return nil, err
+		}
+		crc.Write(length[:])
+		h.Extra = make([]byte, binary.GetUint16LE(length[:]))
+		if _, err := bufio.ReadFull(r, h.Extra); err != nil {
+			return nil, err
+		}
+		crc.Write(h.Extra)
+	}
+	if flags&flagName != 0 {
+		if h.Name, err = readString(r, crc); err != nil {
+			return nil, err
+		}
+	}
+	if flags&flagComment != 0 {
+		if h.Comment, err = readString(r, crc); err != nil {
+			return nil, err
+		}
+	}
+	if flags&flagHCRC != 0 {
+		var headerCRC [2]byte
+		if _, err := bufio.ReadFull(r, headerCRC[:]); err != nil {
+			return nil, err
+		}
+		if binary.GetUint16LE(headerCRC[:]) != uint16(crc.Sum32()) {
+			return nil, ErrHeader
+		}
+	}
+	return h, nil
+}
+
+// readString reads a zero-terminated string from r. The read bytes including
+// the terminating zero are written to crc.
+func readString(r *bufio.Reader, crc *checksum.CRC32) (string, error) {
+	var s []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		crc.Write([]byte{b})
+		if b == 0 {
+			return string(s), nil
+		}
+		s = slices.AppendBytes(s, b)
+	}
+}
@@ -0,0 +1,176 @@
+package gzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+)
+
+var testData = [][]byte{
+	{},
+	[]byte("hello, hello, hello"),
+	tu.ReadFile(testKalevala),
+}
+
+func TestEncodeDecodedByStdlib(t *testing.T) {
+	modTime := time.Unix(1600000000, 0)
+	for _, data := range testData {
+		var encoded bytes.Buffer
+		tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded, &Header{
+			Name:    "kalevala.txt",
+			Comment: "a comment",
+			ModTime: modTime,
+			OS:      OSUnknown,
+		}))
+		r, err := gzip.NewReader(&encoded)
+		tu.ExpectNil(t, err)
+		decoded, err := ioutil.ReadAll(r)
+		tu.ExpectNil(t, err)
+		if !bytes.Equal(data, decoded) {
+			t.Fatal("decoded data differs from the original")
+		}
+		tu.Check(t, "kalevala.txt", r.Name)
+		tu.Check(t, "a comment", r.Comment)
+		tu.Check(t, modTime, r.ModTime)
+		tu.Check(t, byte(OSUnknown), r.OS)
+	}
+}
+
+func TestEncodeWithoutHeader(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(testData[1]), &encoded, nil))
+	r, err := gzip.NewReader(&encoded)
+	tu.ExpectNil(t, err)
+	decoded, err := ioutil.ReadAll(r)
+	tu.ExpectNil(t, err)
+	tu.Check(t, string(testData[1]), string(decoded))
+	tu.Check(t, "", r.Name)
+	tu.Check(t, true, r.ModTime.IsZero())
+}
+
+func TestDecodeStdlibOutput(t *testing.T) {
+	modTime := time.Unix(1500000000, 0)
+	for _, data := range testData {
+		var encoded bytes.Buffer
+		w := gzip.NewWriter(&encoded)
+		w.Name = "file.txt"
+		w.Comment = "comment"
+		w.Extra = []byte{1, 2, 3}
+		w.ModTime = modTime
+		_, err := w.Write(data)
+		tu.ExpectNil(t, err)
+		tu.ExpectNil(t, w.Close())
+		var decoded bytes.Buffer
+		headers, err := Decode(&encoded, &decoded)
+		tu.ExpectNil(t, err)
+		if !bytes.Equal(data, decoded.Bytes()) {
+			t.Fatal("decoded data differs from the original")
+		}
+		tu.Check(t, 1, len(headers))
+		tu.Check(t, "file.txt", headers[0].Name)
+		tu.Check(t, "comment", headers[0].Comment)
+		tu.Check(t, "\x01\x02\x03", string(headers[0].Extra))
+		tu.Check(t, modTime, headers[0].ModTime)
+	}
+}
+
+func TestDecodeMultipleMembers(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("first ")), &encoded,
+		&Header{Name: "first"}))
+	w := gzip.NewWriter(&encoded)
+	w.Name = "second"
+	w.Write([]byte("second"))
+	tu.ExpectNil(t, w.Close())
+
+	var decoded bytes.Buffer
+	headers, err := Decode(bytes.NewReader(encoded.Bytes()), &decoded)
+	tu.ExpectNil(t, err)
+	tu.Check(t, "first second", decoded.String())
+	tu.Check(t, 2, len(headers))
+	tu.Check(t, "first", headers[0].Name)
+	tu.Check(t, "second", headers[1].Name)
+
+	r, err := gzip.NewReader(bytes.NewReader(encoded.Bytes()))
+	tu.ExpectNil(t, err)
+	stdDecoded, err := ioutil.ReadAll(r)
+	tu.ExpectNil(t, err)
+	tu.Check(t, "first second", string(stdDecoded))
+}
+
+func TestDecodeHeaderCRC(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("data")), &encoded,
+		&Header{Name: "name"}))
+	data := encoded.Bytes()
+	// Insert a header CRC after the name field.
+	headerEnd := 10 + len("name") + 1
+	crc := crc16(data[:headerEnd], flagHCRC)
+	withCRC := make([]byte, 0, len(data)+2)
+	withCRC = append(withCRC, data[:headerEnd]...)
+	withCRC[3] |= flagHCRC
+	withCRC = append(withCRC, byte(crc), byte(crc>>8))
+	withCRC = append(withCRC, data[headerEnd:]...)
+
+	var decoded bytes.Buffer
+	_, err := Decode(bytes.NewReader(withCRC), &decoded)
+	tu.ExpectNil(t, err)
+	tu.Check(t, "data", decoded.String())
+
+	withCRC[headerEnd]++
+	_, err = Decode(bytes.NewReader(withCRC), ioutil.Discard)
+	tu.Check(t, ErrHeader, err)
+}
+
+// crc16 returns the header CRC of header after setting flags in it.
+func crc16(header []byte, flags byte) uint16 {
+	h := make([]byte, len(header))
+	copy(h, header)
+	h[3] |= flags
+	return uint16(crc32.ChecksumIEEE(h))
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(testData[1]), &encoded, nil))
+	valid := encoded.Bytes()
+
+	corrupt := func(i int) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= 0xff
+		return data
+	}
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: io.ErrUnexpectedEOF},
+		{desc: "Magic", data: corrupt(0), expected: ErrHeader},
+		{desc: "Method", data: corrupt(2), expected: ErrHeader},
+		{desc: "CRC", data: corrupt(len(valid) - 8), expected: ErrChecksum},
+		{desc: "Size", data: corrupt(len(valid) - 1), expected: ErrChecksum},
+		{
+			desc:     "Truncated",
+			data:     valid[:len(valid)-2],
+			expected: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := Decode(bytes.NewReader(c.data), ioutil.Discard)
+			tu.Check(t, c.expected, err)
+		})
+	}
+}
@@ -21,56 +21,112 @@ import (
 // Encode encodes all data from input using Huffman coding and writes the result
 // to output.
 func Encode(input io.ReadSeeker, output io.Writer) error {
+	return new(Encoder).Encode(input, output)
+}
+
+// Decode decodes data encoded using Encode from input and writes the unencoded
+// data to output.
+func Decode(input io.Reader, output io.Writer) error {
+	return new(Decoder).Decode(input, output)
+}
+
+// Encoder encodes data like Encode but reuses its buffers and data structures
+// between calls, so encoding allocates nothing once they have grown large
+// enough. The zero value is ready for use. An Encoder may be reused after an
+// error but must not be used by multiple goroutines at once.
+type Encoder struct {
+	src   *bufio.Reader
+	dst   *bits.Writer
+	trees treeAllocator
+	table codeTable
+	code  bits.List
+}
+
+// NewEncoder returns a new Encoder.
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Encode encodes all data from input using Huffman coding and writes the result
+// to output.
+func (e *Encoder) Encode(input io.ReadSeeker, output io.Writer) error {
 	if _, err := input.Seek(0, io.SeekStart); err != nil {
 		return err
 	}
-	src := bufio.NewReader(input)
+	if e.src == nil {
+		e.src = bufio.NewReader(input)
+		e.dst = bits.NewWriter(output)
+	} else {
+		e.src.Reset(input)
+		e.dst.Reset(output)
+	}
 	var freqs frequencyTable
-	if err := countFrequencies(src, &freqs); err != nil {
+	if err := countFrequencies(e.src, &freqs); err != nil {
 		return err
 	}
 	if _, err := input.Seek(0, io.SeekStart); err != nil {
 		return err
 	}
-	src.Reset(input)
-	dst := bits.NewWriter(output)
-	tree := buildCodeTree(&freqs)
+	e.src.Reset(input)
+	e.trees.reset()
+	tree := e.trees.buildTree(freqs[:])
 	if tree == nil {
 		return io.EOF
 	}
-	table := newCodeTable(tree)
-	if err := tree.encodeTo(dst); err != nil {
+	e.table.build(tree, &e.code)
+	if err := tree.encodeTo(e.dst); err != nil {
 		return err
 	}
-	if err := dst.WriteInt64(freqs.byteCount()); err != nil {
+	if err := e.dst.WriteInt64(freqs.byteCount()); err != nil {
 		return err
 	}
-	return table.Encode(src, dst)
+	return e.table.Encode(e.src, e.dst)
+}
+
+// Decoder decodes data like Decode but reuses its buffers and data structures
+// between calls, so decoding allocates nothing once they have grown large
+// enough. The zero value is ready for use. A Decoder may be reused after an
+// error but must not be used by multiple goroutines at once.
+type Decoder struct {
+	src   *bits.Reader
+	dst   *bufio.Writer
+	trees treeAllocator
+}
+
+// NewDecoder returns a new Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{}
 }
 
 // Decode decodes data encoded using Encode from input and writes the unencoded
 // data to output.
-func Decode(input io.Reader, output io.Writer) error {
-	src := bits.NewReader(input)
-	dst := bufio.NewWriter(output)
-	codeTree, err := decodeCodeTree(src)
+func (d *Decoder) Decode(input io.Reader, output io.Writer) error {
+	if d.src == nil {
+		d.src = bits.NewReader(input)
+		d.dst = bufio.NewWriter(output)
+	} else {
+		d.src.Reset(input)
+		d.dst.Reset(output)
+	}
+	d.trees.reset()
+	codeTree, err := d.trees.decodeTree(d.src)
 	if err != nil {
 		return err
 	}
-	byteCount, err := src.ReadInt64()
+	byteCount, err := d.src.ReadInt64()
 	if err != nil {
 		return err
 	}
 	for ; byteCount > 0; byteCount-- {
-		byt, err := codeTree.readCode(src)
+		byt, err := codeTree.readCode(d.src)
 		if err != nil {
 			return err
 		}
-		if err := dst.WriteByte(byt); err != nil {
+		if err := d.dst.WriteByte(byt); err != nil {
 			return err
 		}
 	}
-	return dst.Flush()
+	return d.dst.Flush()
 }
 
 // codeTable maps byte values to Huffman codes.
@@ -86,7 +142,7 @@ func (table *codeTable) Encode(src *bufio.Reader, dst *bits.Writer) error {
 			}
 			return err
 		}
-		if err := dst.WriteBits(&table[b]); err != nil {
+		if err := dst.WriteList(&table[b]); err != nil {
 			return err
 		}
 	}
@@ -95,16 +151,24 @@ func (table *codeTable) Encode(src *bufio.Reader, dst *bits.Writer) error {
 // newCodeTable constructs the codeTable corresponding codeTree.
 func newCodeTable(codeTree *codeTreeNode) *codeTable {
 	table := &codeTable{}
-	code := bits.List{}
-	buildCodeTable(table, &code, codeTree)
+	table.build(codeTree, &bits.List{})
 	return table
 }
 
+// build fills table with codes from codeTree reusing the memory of the
+// existing codes. code is used for constructing the codes and is emptied.
+func (table *codeTable) build(codeTree *codeTreeNode, code *bits.List) {
+	code.Shrink(code.Len())
+	buildCodeTable(table, code, codeTree)
+}
+
 // buildCodeTable fills table with codes from codeTree. code is used for
 // constructing the codes and may be at least partially overwritten.
 func buildCodeTable(table *codeTable, code *bits.List, codeTree *codeTreeNode) {
 	if codeTree.left == nil {
-		table[codeTree.symbol] = code.Copy()
+		entry := &table[codeTree.symbol]
+		entry.Shrink(entry.Len())
+		entry.Concat(code)
 		return
 	}
 	code.Append(false)
@@ -145,21 +209,73 @@ func (t *frequencyTable) byteCount() int64 {
 // tree.
 type codeTreeNode struct {
 	left, right *codeTreeNode // both nil iff the node is a leaf node
-	symbol      byte          // meaningless for non-leaf nodes
+	symbol      int           // meaningless for non-leaf nodes
 }
 
 // buildCodeTree builds a code tree using freqs.
 func buildCodeTree(freqs *frequencyTable) *codeTreeNode {
-	queue := priorityQueue{}
+	return buildSymbolCodeTree(freqs[:])
+}
+
+// buildSymbolCodeTree builds a code tree for an alphabet of len(freqs) symbols.
+// freqs[i] is the frequency of symbol i. Symbols with zero frequency are
+// omitted from the tree. nil is returned if all frequencies are zero.
+func buildSymbolCodeTree(freqs []int64) *codeTreeNode {
+	var trees treeAllocator
+	return trees.buildTree(freqs)
+}
+
+// treeAllocator allocates the nodes of code trees and the items of the
+// priority queue used to build them. Its memory is reused after a reset.
+type treeAllocator struct {
+	nodes []codeTreeNode
+	items []queueItem
+	queue priorityQueue
+}
+
+// reset makes the memory of the allocated nodes and items available for
+// reuse. Trees allocated before the reset must no longer be used.
+func (a *treeAllocator) reset() {
+	a.nodes = a.nodes[:0]
+	a.items = a.items[:0]
+	a.queue = a.queue[:0]
+}
+
+// newNode returns a pointer to a new node with the value node.
+func (a *treeAllocator) newNode(node codeTreeNode) *codeTreeNode {
+	if len(a.nodes) == cap(a.nodes) {
+		// Nodes are never modified after they are allocated, so pointers to
+		// the old slice stay valid.
+		a.nodes = make([]codeTreeNode, 0, 2*cap(a.nodes)+64)
+	}
+	a.nodes = a.nodes[:len(a.nodes)+1]
+	a.nodes[len(a.nodes)-1] = node
+	return &a.nodes[len(a.nodes)-1]
+}
+
+// newItem returns a pointer to a new queue item with the value item.
+func (a *treeAllocator) newItem(item queueItem) *queueItem {
+	if len(a.items) == cap(a.items) {
+		// Items are only needed until they are popped from the queue, so
+		// the old slice can be abandoned.
+		a.items = make([]queueItem, 0, 2*cap(a.items)+64)
+	}
+	a.items = a.items[:len(a.items)+1]
+	a.items[len(a.items)-1] = item
+	return &a.items[len(a.items)-1]
+}
+
+// buildTree is like buildSymbolCodeTree but allocates the tree using a.
+func (a *treeAllocator) buildTree(freqs []int64) *codeTreeNode {
+	queue := &a.queue
+	*queue = (*queue)[:0]
 	for symbol := 0; symbol < len(freqs); symbol++ {
 		freq := freqs[symbol]
 		if freq > 0 {
-			queue.Append(&queueItem{
-				node: &codeTreeNode{
-					symbol: byte(symbol),
-				},
+			queue.Append(a.newItem(queueItem{
+				node:      a.newNode(codeTreeNode{symbol: symbol}),
 				frequency: freq,
-			})
+			}))
 		}
 	}
 	queue.Init()
@@ -169,13 +285,13 @@ func buildCodeTree(freqs *frequencyTable) *codeTreeNode {
 	for queue.Len() >= 2 {
 		left := queue.Pop()
 		right := queue.Pop()
-		queue.Push(&queueItem{
-			node: &codeTreeNode{
+		queue.Push(a.newItem(queueItem{
+			node: a.newNode(codeTreeNode{
 				left:  left.node,
 				right: right.node,
-			},
+			}),
 			frequency: left.frequency + right.frequency,
-		})
+		}))
 	}
 	return queue.Pop().node
 }
@@ -186,7 +302,7 @@ func (tree *codeTreeNode) encodeTo(out *bits.Writer) error {
 		if err := out.WriteBit(true); err != nil {
 			return err
 		}
-		return out.WriteByte(tree.symbol)
+		return out.WriteByte(byte(tree.symbol))
 	}
 	if err := out.WriteBit(false); err != nil {
 		return err
@@ -200,6 +316,12 @@ func (tree *codeTreeNode) encodeTo(out *bits.Writer) error {
 // decodeCodeTree decodes a code tree from src that was previously encoded using
 // encodeTo.
 func decodeCodeTree(src *bits.Reader) (*codeTreeNode, error) {
+	var trees treeAllocator
+	return trees.decodeTree(src)
+}
+
+// decodeTree is like decodeCodeTree but allocates the tree using a.
+func (a *treeAllocator) decodeTree(src *bits.Reader) (*codeTreeNode, error) {
 	bit, err := src.ReadBit()
 	if err != nil {
 		return nil, err
@@ -209,23 +331,23 @@ func decodeCodeTree(src *bits.Reader) (*codeTreeNode, error) {
 		if err != nil {
 			return nil, err
 		}
-		return &codeTreeNode{symbol: symbol}, nil
+		return a.newNode(codeTreeNode{symbol: int(symbol)}), nil
 	}
-	left, err := decodeCodeTree(src)
+	left, err := a.decodeTree(src)
 	if err != nil {
 		return nil, err
 	}
-	right, err := decodeCodeTree(src)
+	right, err := a.decodeTree(src)
 	if err != nil {
 		return nil, err
 	}
-	return &codeTreeNode{left: left, right: right}, nil
+	return a.newNode(codeTreeNode{left: left, right: right}), nil
 }
 
 // readCode reads a code from src and returns the corresponding byte value.
 func (tree *codeTreeNode) readCode(src *bits.Reader) (byte, error) {
 	if tree.left == nil {
-		return tree.symbol, nil
+		return byte(tree.symbol), nil
 	}
 	bit, err := src.ReadBit()
 	if err != nil {
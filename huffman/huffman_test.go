@@ -3,6 +3,7 @@ package huffman
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"strings"
 	"testing"
 
@@ -17,7 +18,7 @@ const (
 
 func printTree(node *codeTreeNode, indent string) {
 	if node.left == nil {
-		fmt.Println(indent, string([]byte{node.symbol}))
+		fmt.Println(indent, string([]byte{byte(node.symbol)}))
 	} else {
 		fmt.Println(indent + "X")
 		printTree(node.left, indent+"0 ")
@@ -176,6 +177,49 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestEncoderTableReuse(t *testing.T) {
+	// The code table of a reused Encoder must hold the codes of the latest
+	// input, not of the previous one.
+	kalevala := tu.ReadFile(testKalevala)
+	small := []byte("abracadabra")
+	e := NewEncoder()
+	tu.ExpectNil(t, e.Encode(bytes.NewReader(kalevala), ioutil.Discard))
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, e.Encode(bytes.NewReader(small), &encoded))
+	fresh := NewEncoder()
+	tu.ExpectNil(t, fresh.Encode(bytes.NewReader(small), ioutil.Discard))
+	for _, b := range small {
+		tu.Check(t, fresh.table[b].String(), e.table[b].String())
+	}
+	var decoded bytes.Buffer
+	tu.ExpectNil(t, Decode(&encoded, &decoded))
+	tu.Check(t, string(small), decoded.String())
+
+	// Switching between inputs with different code trees reuses the
+	// storage of the table and the trees.
+	inputs := [][]byte{kalevala[:5000], small}
+	r := bytes.NewReader(nil)
+	allocs := testing.AllocsPerRun(5, func() {
+		for _, input := range inputs {
+			r.Reset(input)
+			tu.ExpectNil(t, e.Encode(r, ioutil.Discard))
+		}
+	})
+	tu.Check(t, 0.0, allocs)
+}
+
+func TestDecoderAllocations(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded))
+	src := bytes.NewReader(encoded.Bytes())
+	d := NewDecoder()
+	allocs := testing.AllocsPerRun(5, func() {
+		src.Reset(encoded.Bytes())
+		tu.ExpectNil(t, d.Decode(src, ioutil.Discard))
+	})
+	tu.Check(t, 0.0, allocs)
+}
+
 func BenchmarkEncode(b *testing.B) {
 	input := tu.ReadFile(testKalevala)
 	r := bytes.NewReader(input)
@@ -186,3 +230,14 @@ func BenchmarkEncode(b *testing.B) {
 		Encode(r, &buf)
 	}
 }
+
+func BenchmarkDecode(b *testing.B) {
+	var encoded bytes.Buffer
+	Encode(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded)
+	r := bytes.NewReader(encoded.Bytes())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(encoded.Bytes())
+		Decode(r, ioutil.Discard)
+	}
+}
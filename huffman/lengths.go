@@ -0,0 +1,93 @@
+package huffman
+
+import "github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+
+// CodeLengths computes Huffman code lengths for an alphabet of len(freqs)
+// symbols. freqs[i] is the frequency of symbol i. The length of the code of
+// symbol i is stored in the i'th element of the returned slice. Symbols with
+// zero frequency get a length of 0. If only one symbol has a non-zero
+// frequency, its code length is 1.
+//
+// No code is longer than maxLength bits. If the optimal code would contain
+// longer codes, the frequencies are flattened until the longest code fits. The
+// result is always a complete prefix code. 2^maxLength must be at least the
+// number of symbols with non-zero frequency.
+func CodeLengths(freqs []int64, maxLength int) []uint8 {
+	lengths := make([]uint8, len(freqs))
+	scaled := freqs
+	for {
+		tree := buildSymbolCodeTree(scaled)
+		if tree == nil {
+			return lengths
+		}
+		if tree.left == nil {
+			lengths[tree.symbol] = 1
+			return lengths
+		}
+		if assignCodeLengths(tree, 0, maxLength, lengths) {
+			return lengths
+		}
+		// Halving the frequencies while keeping them non-zero makes the
+		// tree more balanced. Eventually all frequencies become 1.
+		halved := make([]int64, len(scaled))
+		for i := 0; i < len(scaled); i++ {
+			halved[i] = (scaled[i] + 1) / 2
+		}
+		scaled = halved
+	}
+}
+
+// assignCodeLengths stores the lengths of the codes of the leaves in tree to
+// lengths. depth is the depth of tree in the whole code tree. false is returned
+// if a code is longer than maxLength.
+func assignCodeLengths(
+	tree *codeTreeNode,
+	depth, maxLength int,
+	lengths []uint8,
+) bool {
+	if tree.left == nil {
+		lengths[tree.symbol] = uint8(depth)
+		return depth <= maxLength
+	}
+	return assignCodeLengths(tree.left, depth+1, maxLength, lengths) &&
+		assignCodeLengths(tree.right, depth+1, maxLength, lengths)
+}
+
+// CanonicalCodes returns the canonical Huffman code corresponding to code
+// lengths in lengths. The code of symbol i is stored in the i'th element of the
+// returned slice. Symbols with a code length of 0 get an empty code.
+//
+// Canonical codes of the same length are consecutive integers in the order of
+// their symbols, and shorter codes precede longer codes lexicographically.
+// This is the code construction used, for example, by the DEFLATE format.
+func CanonicalCodes(lengths []uint8) []bits.List {
+	maxLength := 0
+	for i := 0; i < len(lengths); i++ {
+		if int(lengths[i]) > maxLength {
+			maxLength = int(lengths[i])
+		}
+	}
+	lengthCounts := make([]uint64, maxLength+1)
+	for i := 0; i < len(lengths); i++ {
+		lengthCounts[lengths[i]]++
+	}
+	lengthCounts[0] = 0
+	nextCode := make([]uint64, maxLength+1)
+	code := uint64(0)
+	for length := 1; length <= maxLength; length++ {
+		code = (code + lengthCounts[length-1]) << 1
+		nextCode[length] = code
+	}
+	codes := make([]bits.List, len(lengths))
+	for symbol := 0; symbol < len(lengths); symbol++ {
+		length := int(lengths[symbol])
+		if length == 0 {
+			continue
+		}
+		for i := length - 1; i >= 0; i-- {
+			codes[symbol].Append((nextCode[length]>>uint(i))&1 != 0)
+		}
+		nextCode[length]++
+	}
+	return codes
+}
@@ -0,0 +1,71 @@
+package huffman
+
+import (
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+// kraftSum returns the sum of 2^(maxLength-l) over all non-zero code lengths l.
+// The sum equals 2^maxLength for complete prefix codes.
+func kraftSum(lengths []uint8, maxLength int) int {
+	sum := 0
+	for _, l := range lengths {
+		if l > 0 {
+			sum += 1 << uint(maxLength-int(l))
+		}
+	}
+	return sum
+}
+
+func TestCodeLengths(t *testing.T) {
+	t.Run("Optimal", func(t *testing.T) {
+		freqs := []int64{7, 7, 6, 8, 5, 8, 0}
+		lengths := CodeLengths(freqs, 15)
+		expected := []uint8{3, 3, 3, 2, 3, 2, 0}
+		for i := range expected {
+			tu.Check(t, expected[i], lengths[i])
+		}
+	})
+	t.Run("SingleSymbol", func(t *testing.T) {
+		lengths := CodeLengths([]int64{0, 0, 4}, 15)
+		tu.Check(t, uint8(0), lengths[0])
+		tu.Check(t, uint8(0), lengths[1])
+		tu.Check(t, uint8(1), lengths[2])
+	})
+	t.Run("NoSymbols", func(t *testing.T) {
+		lengths := CodeLengths([]int64{0, 0}, 15)
+		tu.Check(t, uint8(0), lengths[0])
+		tu.Check(t, uint8(0), lengths[1])
+	})
+	t.Run("Limited", func(t *testing.T) {
+		// Fibonacci frequencies produce the deepest possible code tree.
+		freqs := make([]int64, 30)
+		freqs[0], freqs[1] = 1, 1
+		for i := 2; i < len(freqs); i++ {
+			freqs[i] = freqs[i-1] + freqs[i-2]
+		}
+		for _, maxLength := range []int{5, 7, 15} {
+			lengths := CodeLengths(freqs, maxLength)
+			for i, l := range lengths {
+				if l == 0 || int(l) > maxLength {
+					t.Fatalf("invalid length %d for symbol %d", l, i)
+				}
+			}
+			tu.Check(t, 1<<uint(maxLength), kraftSum(lengths, maxLength))
+		}
+	})
+}
+
+func TestCanonicalCodes(t *testing.T) {
+	// The example from RFC 1951 section 3.2.2.
+	lengths := []uint8{3, 3, 3, 3, 3, 2, 4, 4, 0}
+	expected := []string{
+		"010", "011", "100", "101", "110", "00", "1110", "1111", "",
+	}
+	codes := CanonicalCodes(lengths)
+	tu.Check(t, len(expected), len(codes))
+	for i := range expected {
+		tu.Check(t, expected[i], codes[i].String())
+	}
+}
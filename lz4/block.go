@@ -0,0 +1,173 @@
+package lz4
+
+import (
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// These constants specify the limits of the block format.
+const (
+	minMatchLength = 4
+	maxOffset      = 1<<16 - 1
+	// The last lastLiterals bytes of a block are always literals.
+	lastLiterals = 5
+	// The last match must start at least matchLimit bytes before the end of
+	// the block.
+	matchLimit = 12
+	// maxLookahead limits the length of matches searched by the encoder.
+	maxLookahead = 1 << 10
+)
+
+// MaxEncodedBlockSize returns the maximum size of a block produced by
+// EncodeBlock for n bytes of input.
+func MaxEncodedBlockSize(n int) int {
+	return n + n/255 + 16
+}
+
+// EncodeBlock compresses src using the LZ4 block format and returns the
+// compressed block.
+func EncodeBlock(src []byte) []byte {
+	e := blockEncoder{
+		dst: make([]byte, MaxEncodedBlockSize(len(src))),
+	}
+	matcher := lz77.NewMatcher(maxOffset)
+	anchor := 0
+	pos := 0
+	for pos+matchLimit <= len(src) {
+		end := len(src) - lastLiterals
+		if end-pos > maxLookahead {
+			end = pos + maxLookahead
+		}
+		length, distance := matcher.FindMatch(src[pos:end])
+		if length < minMatchLength {
+			matcher.AppendByte(src[pos])
+			pos++
+			continue
+		}
+		e.writeSequence(src[anchor:pos], distance, length)
+		matcher.Append(src[pos : pos+length])
+		pos += length
+		anchor = pos
+	}
+	e.writeLiterals(src[anchor:])
+	return e.dst[:e.n]
+}
+
+// blockEncoder holds the output of an ongoing EncodeBlock call.
+type blockEncoder struct {
+	dst []byte
+	n   int
+}
+
+// writeSequence writes a sequence consisting of literals followed by a match.
+func (e *blockEncoder) writeSequence(literals []byte, offset, length int) {
+	token := e.n
+	e.writeLiterals(literals)
+	e.dst[e.n] = byte(offset)
+	e.dst[e.n+1] = byte(offset >> 8)
+	e.n += 2
+	length -= minMatchLength
+	if length >= 15 {
+		e.dst[token] |= 15
+		e.writeLength(length - 15)
+	} else {
+		e.dst[token] |= byte(length)
+	}
+}
+
+// writeLiterals writes a token followed by literals. The match length part of
+// the token is left as zero.
+func (e *blockEncoder) writeLiterals(literals []byte) {
+	token := e.n
+	e.n++
+	if len(literals) >= 15 {
+		e.dst[token] = 15 << 4
+		e.writeLength(len(literals) - 15)
+	} else {
+		e.dst[token] = byte(len(literals)) << 4
+	}
+	e.n += slices.CopyBytes(e.dst[e.n:], literals)
+}
+
+// writeLength writes the extra bytes of a length that doesn't fit in a token.
+func (e *blockEncoder) writeLength(length int) {
+	for ; length >= 255; length -= 255 {
+		e.dst[e.n] = 255
+		e.n++
+	}
+	e.dst[e.n] = byte(length)
+	e.n++
+}
+
+// DecodeBlock decompresses the LZ4 block in src to dst and returns the number
+// of bytes written. ErrCorrupt is returned if src is not a valid block or if
+// the decompressed data doesn't fit in dst.
+func DecodeBlock(src, dst []byte) (int, error) {
+	return decodeBlock(src, dst, 0)
+}
+
+// decodeBlock decompresses the block in src to dst starting from index start
+// and returns the index of the end of the decompressed data. Matches may refer
+// to data in dst before start, which allows decoding dependent blocks.
+func decodeBlock(src, dst []byte, start int) (int, error) {
+	i := 0
+	out := start
+	for i < len(src) {
+		token := src[i]
+		i++
+		litLength, err := readLength(src, &i, int(token>>4))
+		if err != nil {
+			return 0, err
+		}
+		if litLength > len(src)-i || litLength > len(dst)-out {
+			return 0, ErrCorrupt
+		}
+		slices.CopyBytes(dst[out:], src[i:i+litLength])
+		i += litLength
+		out += litLength
+		if i == len(src) {
+			// The last sequence contains only literals.
+			return out, nil
+		}
+		if len(src)-i < 2 {
+			return 0, ErrCorrupt
+		}
+		offset := int(src[i]) | int(src[i+1])<<8
+		i += 2
+		if offset == 0 || offset > out {
+			return 0, ErrCorrupt
+		}
+		length, err := readLength(src, &i, int(token&15))
+		if err != nil {
+			return 0, err
+		}
+		length += minMatchLength
+		if length > len(dst)-out {
+			return 0, ErrCorrupt
+		}
+		for j := 0; j < length; j++ {
+			dst[out] = dst[out-offset]
+			out++
+		}
+	}
+	return 0, ErrCorrupt
+}
+
+// readLength reads the rest of a length value starting from src[*i]. length is
+// the part of the length stored in the token.
+func readLength(src []byte, i *int, length int) (int, error) {
+	if length != 15 {
+		return length, nil
+	}
+	for {
+		if *i >= len(src) {
+			return 0, ErrCorrupt
+		}
+		b := src[*i]
+		*i++
+		length += int(b)
+		if b != 255 {
+			return length, nil
+		}
+	}
+}
@@ -0,0 +1,323 @@
+/*
+Package lz4 implements the LZ4 block and frame formats.
+
+The block format represents data as a series of sequences. Each sequence
+consists of literal bytes followed by a match referring to earlier data in the
+block. Matches are at least 4 bytes long and refer up to 65535 bytes back. The
+last sequence of a block contains only literals. EncodeBlock and DecodeBlock
+compress and decompress single blocks. Matches are found using the match
+finding of package lz77.
+
+The frame format wraps a stream of blocks with a header describing the frame
+and optional checksums computed using the xxHash32 algorithm. Encode and Decode
+compress and decompress frames and are compatible with other LZ4
+implementations, such as the lz4 command line program.
+*/
+package lz4
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// Errors returned by decoding functions.
+var (
+	ErrCorrupt     = errors.New("lz4: corrupt input")
+	ErrChecksum    = errors.New("lz4: invalid checksum")
+	ErrUnsupported = errors.New("lz4: unsupported frame format")
+)
+
+// These constants are the magic numbers identifying frames.
+const (
+	frameMagic         = 0x184d2204
+	skippableMagicMask = 0xfffffff0
+	skippableMagic     = 0x184d2a50
+)
+
+// These constants are the bits of the FLG byte of a frame descriptor.
+const (
+	flagVersion         = 1 << 6
+	flagVersionMask     = 3 << 6
+	flagBlockIndep      = 1 << 5
+	flagBlockChecksum   = 1 << 4
+	flagContentSize     = 1 << 3
+	flagContentChecksum = 1 << 2
+	flagDictID          = 1 << 0
+)
+
+// maxBlockSize returns the block maximum size corresponding to the block
+// maximum size field id of a frame descriptor. ok is false if id is invalid.
+func maxBlockSize(id byte) (size int, ok bool) {
+	if id < 4 || id > 7 {
+		return 0, false
+	}
+	return 1 << (8 + 2*uint(id)), true
+}
+
+// These constants specify the frames written by Encode.
+const (
+	encodeBlockSizeID = 4
+	encodeBlockSize   = 1 << 16
+)
+
+// uncompressedBit is set in the size of a block stored without compression.
+const uncompressedBit = 1 << 31
+
+// Encode compresses data from input and writes it to output as an LZ4 frame.
+// Blocks are compressed independently and the frame includes a checksum of
+// the content.
+func Encode(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	dst := bufio.NewWriter(output)
+	var header [7]byte
+	binary.PutUint32LE(header[:4], frameMagic)
+	header[4] = flagVersion | flagBlockIndep | flagContentChecksum
+	header[5] = encodeBlockSizeID << 4
+	header[6] = headerChecksum(header[4:6])
+	if _, err := dst.Write(header[:]); err != nil {
+		return err
+	}
+	content := checksum.NewXXH32(0)
+	block := make([]byte, encodeBlockSize)
+	for {
+		n, err := src.Read(block)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		content.Write(block[:n])
+		if err := writeBlock(dst, block[:n]); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	var trailer [8]byte
+	binary.PutUint32LE(trailer[4:], content.Sum32())
+	if _, err := dst.Write(trailer[:]); err != nil {
+		return err
+	}
+	return dst.Flush()
+}
+
+// writeBlock compresses data and writes it as a frame block. If compression
+// doesn't reduce the size of data, it is stored uncompressed.
+func writeBlock(w *bufio.Writer, data []byte) error {
+	compressed := EncodeBlock(data)
+	size := uint32(len(compressed))
+	if len(compressed) >= len(data) {
+		compressed = data
+		size = uint32(len(data)) | uncompressedBit
+	}
+	var sizeBuf [4]byte
+	binary.PutUint32LE(sizeBuf[:], size)
+	if _, err := w.Write(sizeBuf[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(compressed)
+	return err
+}
+
+// headerChecksum returns the header checksum of a frame descriptor.
+func headerChecksum(descriptor []byte) byte {
+	x := checksum.NewXXH32(0)
+	x.Write(descriptor)
+	return byte(x.Sum32() >> 8)
+}
+
+// Decode decodes all LZ4 frames in input and writes the concatenation of their
+// contents to output. Skippable frames are ignored.
+func Decode(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	dst := bufio.NewWriter(output)
+	for frames := 0; ; frames++ {
+		var magic [4]byte
+		n, err := src.Read(magic[:])
+		if err != nil {
+			if err == io.EOF && n == 0 && frames > 0 {
+				return dst.Flush()
+			}
+			return unexpectedEOF(err)
+		}
+		switch m := binary.GetUint32LE(magic[:]); {
+		case m == frameMagic:
+			err = decodeFrame(src, dst)
+		case m&skippableMagicMask == skippableMagic:
+			err = skipFrame(src)
+		default:
+			err = ErrUnsupported
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// skipFrame skips the contents of a skippable frame.
+func skipFrame(src *bufio.Reader) error {
+	var size [4]byte
+	if _, err := src.Read(size[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	_, err := src.Discard(int(binary.GetUint32LE(size[:])))
+	return unexpectedEOF(err)
+}
+
+// frameDecoder holds the state of a frame being decoded.
+type frameDecoder struct {
+	src *bufio.Reader
+	dst *bufio.Writer
+	// flags is the FLG byte of the frame descriptor.
+	flags   byte
+	content *checksum.XXH32
+	// buf contains the history of dependent blocks followed by the
+	// decompressed data of the current block.
+	buf []byte
+	// history is the amount of history in buf.
+	history int
+	// block contains the data of the current block as stored in the frame.
+	block []byte
+	size  uint64
+}
+
+// decodeFrame decodes the frame following the magic number in src and writes
+// the decompressed data to dst.
+func decodeFrame(src *bufio.Reader, dst *bufio.Writer) error {
+	var descriptor [2]byte
+	if _, err := src.Read(descriptor[:]); err != nil {
+		return unexpectedEOF(err)
+	}
+	d := &frameDecoder{
+		src:     src,
+		dst:     dst,
+		flags:   descriptor[0],
+		content: checksum.NewXXH32(0),
+	}
+	if d.flags&flagVersionMask != flagVersion || descriptor[1]&0x8f != 0 ||
+		d.flags&0x02 != 0 {
+		return ErrUnsupported
+	}
+	if d.flags&flagDictID != 0 {
+		return ErrUnsupported
+	}
+	blockSize, ok := maxBlockSize((descriptor[1] >> 4) & 7)
+	if !ok {
+		return ErrUnsupported
+	}
+	headerLen := 2
+	var header [2 + 8 + 1]byte
+	slices.CopyBytes(header[:], descriptor[:])
+	if d.flags&flagContentSize != 0 {
+		headerLen += 8
+	}
+	if _, err := src.Read(header[2 : headerLen+1]); err != nil {
+		return unexpectedEOF(err)
+	}
+	if headerChecksum(header[:headerLen]) != header[headerLen] {
+		return ErrChecksum
+	}
+
+	d.block = make([]byte, blockSize)
+	if d.flags&flagBlockIndep != 0 {
+		d.buf = make([]byte, blockSize)
+	} else {
+		d.buf = make([]byte, maxOffset+blockSize)
+	}
+	for {
+		done, err := d.decodeBlock()
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+
+	if d.flags&flagContentSize != 0 && d.size != binary.GetUint64LE(header[2:10]) {
+		return ErrCorrupt
+	}
+	if d.flags&flagContentChecksum != 0 {
+		var sum [4]byte
+		if _, err := src.Read(sum[:]); err != nil {
+			return unexpectedEOF(err)
+		}
+		if binary.GetUint32LE(sum[:]) != d.content.Sum32() {
+			return ErrChecksum
+		}
+	}
+	return nil
+}
+
+// decodeBlock decodes the next block of the frame. done is true if the end
+// mark was read instead of a block.
+func (d *frameDecoder) decodeBlock() (done bool, err error) {
+	var sizeBuf [4]byte
+	if _, err := d.src.Read(sizeBuf[:]); err != nil {
+		return false, unexpectedEOF(err)
+	}
+	size := binary.GetUint32LE(sizeBuf[:])
+	if size == 0 {
+		return true, nil
+	}
+	uncompressed := size&uncompressedBit != 0
+	size &^= uncompressedBit
+	if int(size) > len(d.block) {
+		return false, ErrCorrupt
+	}
+	block := d.block[:size]
+	if _, err := d.src.Read(block); err != nil {
+		return false, unexpectedEOF(err)
+	}
+	if d.flags&flagBlockChecksum != 0 {
+		var sum [4]byte
+		if _, err := d.src.Read(sum[:]); err != nil {
+			return false, unexpectedEOF(err)
+		}
+		x := checksum.NewXXH32(0)
+		x.Write(block)
+		if binary.GetUint32LE(sum[:]) != x.Sum32() {
+			return false, ErrChecksum
+		}
+	}
+
+	var end int
+	if uncompressed {
+		end = d.history + slices.CopyBytes(d.buf[d.history:], block)
+	} else {
+		end, err = decodeBlock(block, d.buf[:d.history+len(d.block)], d.history)
+		if err != nil {
+			return false, err
+		}
+	}
+	data := d.buf[d.history:end]
+	d.content.Write(data)
+	d.size += uint64(len(data))
+	if _, err := d.dst.Write(data); err != nil {
+		return false, err
+	}
+	if d.flags&flagBlockIndep == 0 {
+		// Keep the most recent data as history for the next block.
+		d.history = end
+		if d.history > maxOffset {
+			slices.CopyBytes(d.buf, d.buf[end-maxOffset:end])
+			d.history = maxOffset
+		}
+	}
+	return false, nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,220 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os/exec"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testFiles    = "../test/files/"
+	testKalevala = testFiles + "kalevala.txt"
+)
+
+func TestBlockEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 2*maxOffset)
+	rand.New(rand.NewSource(1)).Read(random)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		// Blocks shorter than matchLimit consist of literals only.
+		{desc: "Short", data: []byte("abcabcabcab")},
+		// Literal runs of 15 or more bytes need extra length bytes.
+		{desc: "LongLiterals", data: random[:1000]},
+		// So do matches of 19 or more bytes.
+		{desc: "LongMatches", data: bytes.Repeat([]byte{'x'}, 100000)},
+		// The second copy refers to the first one at nearly the maximum
+		// offset.
+		{desc: "FarMatches", data: bytes.Repeat(random[:maxOffset-10], 2)},
+		{desc: "Kalevala", data: tu.ReadFile(testKalevala)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			encoded := EncodeBlock(c.data)
+			if len(encoded) > MaxEncodedBlockSize(len(c.data)) {
+				t.Fatalf("encoded block too large: %d", len(encoded))
+			}
+			decoded := make([]byte, len(c.data))
+			n, err := DecodeBlock(encoded, decoded)
+			tu.ExpectNil(t, err)
+			tu.Check(t, len(c.data), n)
+			if !bytes.Equal(c.data, decoded) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestBlockFormat(t *testing.T) {
+	cases := []struct {
+		desc     string
+		data     string
+		expected string
+	}{
+		{
+			// 5 literals followed by a match of length 5 at offset 5 and 8
+			// final literals.
+			desc:     "Match",
+			data:     "abcdeabcdeabcfghij",
+			expected: "\x51abcde\x05\x00\x80abcfghij",
+		},
+		{
+			// The literal length 20 is stored as 15 in the token followed by
+			// an extra length byte 5.
+			desc:     "LongLiterals",
+			data:     "abcdefghijklmnopqrst",
+			expected: "\xf0\x05abcdefghijklmnopqrst",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, string(EncodeBlock([]byte(c.data))))
+		})
+	}
+}
+
+func TestDecodeBlockErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		data []byte
+		size int
+	}{
+		{desc: "Empty", data: []byte{}, size: 10},
+		{desc: "LiteralsPastEnd", data: []byte{0x30, 'a'}, size: 10},
+		{desc: "OutputTooSmall", data: []byte{0x30, 'a', 'b', 'c'}, size: 2},
+		{desc: "ZeroOffset", data: []byte{0x10, 'a', 0, 0}, size: 10},
+		{desc: "OffsetTooFar", data: []byte{0x10, 'a', 2, 0}, size: 10},
+		{desc: "TruncatedOffset", data: []byte{0x10, 'a', 1}, size: 10},
+		{desc: "TruncatedLength", data: []byte{0xf0, 255}, size: 1000},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := DecodeBlock(c.data, make([]byte, c.size))
+			tu.Check(t, ErrCorrupt, err)
+		})
+	}
+}
+
+func TestDecodeReferenceFrames(t *testing.T) {
+	cases := []struct {
+		compressed, original string
+	}{
+		// Default settings of the lz4 program.
+		{"lz4/grammar.lsp.lz4", "grammar.lsp"},
+		// Block checksums and content size.
+		{"lz4/cp.html.lz4", "cp.html"},
+		// High compression without content checksum.
+		{"lz4/xargs.1.lz4", "xargs.1"},
+		// Dependent blocks.
+		{"lz4/sum.lz4", "sum"},
+	}
+	for _, c := range cases {
+		t.Run(c.original, func(t *testing.T) {
+			var decoded bytes.Buffer
+			compressed := tu.ReadFile(testFiles + c.compressed)
+			tu.ExpectNil(t, Decode(bytes.NewReader(compressed), &decoded))
+			if !bytes.Equal(tu.ReadFile(testFiles+c.original), decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestFrameEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	kalevala := tu.ReadFile(testKalevala)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		// A frame without blocks.
+		{desc: "Empty", data: []byte{}},
+		{desc: "OneBlock", data: kalevala[:encodeBlockSize]},
+		{desc: "MultipleBlocks", data: kalevala},
+		// Blocks that don't compress are stored uncompressed.
+		{desc: "Uncompressed", data: random},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			tu.ExpectNil(t, Encode(bytes.NewReader(c.data), &encoded))
+			tu.ExpectNil(t, Decode(&encoded, &decoded))
+			if !bytes.Equal(c.data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestEncodeDecodedByLZ4Program(t *testing.T) {
+	if _, err := exec.LookPath("lz4"); err != nil {
+		t.Skip("lz4 program not found")
+	}
+	data := tu.ReadFile(testKalevala)
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded))
+	cmd := exec.Command("lz4", "-d", "-c")
+	cmd.Stdin = &encoded
+	decoded, err := cmd.Output()
+	tu.ExpectNil(t, err)
+	if !bytes.Equal(data, decoded) {
+		t.Fatal("decoded data differs from the original")
+	}
+}
+
+func TestDecodeMultipleFrames(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("first ")), &encoded))
+	// A skippable frame with 3 bytes of data.
+	encoded.Write([]byte{0x5a, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 1, 2, 3})
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("second")), &encoded))
+	var decoded bytes.Buffer
+	tu.ExpectNil(t, Decode(&encoded, &decoded))
+	tu.Check(t, "first second", decoded.String())
+}
+
+func TestDecodeFrameErrors(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("hello hello hello")), &encoded))
+	valid := encoded.Bytes()
+	corrupt := func(i int) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= 0x01
+		return data
+	}
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: io.ErrUnexpectedEOF},
+		{desc: "Magic", data: corrupt(0), expected: ErrUnsupported},
+		{desc: "HeaderChecksum", data: corrupt(6), expected: ErrChecksum},
+		{desc: "ContentChecksum", data: corrupt(len(valid) - 1), expected: ErrChecksum},
+		{desc: "Truncated", data: valid[:len(valid)-5], expected: io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, Decode(bytes.NewReader(c.data), ioutil.Discard))
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	input := tu.ReadFile(testKalevala)
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		Encode(r, ioutil.Discard)
+	}
+}
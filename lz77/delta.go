@@ -0,0 +1,107 @@
+package lz77
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+)
+
+// A patch produced by EncodeDelta starts with a header consisting of the magic
+// bytes "LZ7D", a format version byte and the length and CRC-32 checksum of
+// the reference data as little-endian uint64 and uint32 values. The header is
+// followed by units of the large window format encoding the new data as if it
+// followed the reference data, so references may refer to any position in the
+// reference data and up to deltaWindowSize bytes back in the new data. The
+// units are followed by the trailer describing the new data.
+const (
+	deltaMagic         = "LZ7D"
+	deltaFormatVersion = 1
+	deltaHeaderSize    = len(deltaMagic) + 1 + 8 + 4
+	deltaWindowSize    = 1 << DefaultWindowBits
+	// deltaMaxChain is larger than largeWindowMaxChain because finding the
+	// position in the reference data after a change is essential for small
+	// patches.
+	deltaMaxChain = 1024
+)
+
+// ErrReferenceMismatch is returned by DecodeDelta if the reference data differs
+// from the data the patch was created against.
+var ErrReferenceMismatch = errors.New("lz77: patch doesn't match the reference data")
+
+// EncodeDelta reads new data from input and writes a patch encoding it relative
+// to the reference data old to output. Parts of the new data found in old are
+// coded as references to old, so the patch of a mostly unchanged file is
+// small. The whole reference data is kept in the window, which takes about
+// nine bytes of memory per byte of old.
+func EncodeDelta(old []byte, input io.Reader, output io.Writer) error {
+	summer := &checksum.Reader{R: input, Hash: &checksum.CRC32{}}
+	src := bufio.NewReaderSize(summer, largeLookaheadSize)
+	dst := bits.NewWriter(output)
+	var oldCRC checksum.CRC32
+	oldCRC.Write(old)
+	header := [deltaHeaderSize]byte{
+		deltaMagic[0], deltaMagic[1], deltaMagic[2], deltaMagic[3], deltaFormatVersion,
+	}
+	binary.PutUint64LE(header[len(deltaMagic)+1:], uint64(len(old)))
+	binary.PutUint32LE(header[len(deltaMagic)+9:], oldCRC.Sum32())
+	for _, b := range header {
+		if err := dst.WriteByte(b); err != nil {
+			return err
+		}
+	}
+	window := newEncoderWindowBuffer(len(old) + deltaWindowSize)
+	window.maxChain = deltaMaxChain
+	window.append(old)
+	if err := encodeVariableUnits(src, dst, window); err != nil {
+		return err
+	}
+	if err := dst.Flush(); err != nil {
+		return err
+	}
+	var trailer [trailerSize]byte
+	binary.PutUint64LE(trailer[:], uint64(summer.N))
+	binary.PutUint32LE(trailer[8:], summer.Hash.Sum32())
+	_, err := output.Write(trailer[:])
+	return err
+}
+
+// DecodeDelta reads a patch produced by EncodeDelta from input, applies it to
+// the reference data old and writes the new data to output.
+// ErrReferenceMismatch is returned if old is not the data the patch was created
+// against. A *CorruptInputError is returned if the patch is invalid.
+func DecodeDelta(old []byte, input io.Reader, output io.Writer) error {
+	d := &bitDecoder{r: bits.NewReader(input)}
+	var header [deltaHeaderSize]byte
+	if _, err := d.r.ReadAlignedBytes(header[:]); err != nil {
+		return truncated(err, d.offset())
+	}
+	if string(header[:len(deltaMagic)]) != deltaMagic {
+		return &CorruptInputError{0, "invalid magic"}
+	}
+	if v := header[len(deltaMagic)]; v != deltaFormatVersion {
+		return &CorruptInputError{int64(len(deltaMagic)), fmt.Sprintf("unsupported version %d", v)}
+	}
+	var oldCRC checksum.CRC32
+	oldCRC.Write(old)
+	if binary.GetUint64LE(header[len(deltaMagic)+1:]) != uint64(len(old)) ||
+		binary.GetUint32LE(header[len(deltaMagic)+9:]) != oldCRC.Sum32() {
+		return ErrReferenceMismatch
+	}
+	summer := &checksum.Writer{W: output, Hash: &checksum.CRC32{}}
+	window := len(old) + deltaWindowSize
+	history := newHistoryBuffer(summer, window, window+historyBufferSize)
+	history.reset(summer, old)
+	if err := decodeVariableUnits(d, history, window); err != nil {
+		return err
+	}
+	trailer, offset, err := d.readTrailer()
+	if err != nil {
+		return err
+	}
+	return checkTrailer(trailer[:], offset, history.total-int64(len(old)), summer.Hash.Sum32())
+}
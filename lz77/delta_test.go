@@ -0,0 +1,121 @@
+package lz77
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+// edit returns a copy of data with a few bytes replaced, inserted and removed
+// at random positions.
+func edit(data []byte, rng *rand.Rand) []byte {
+	edited := append([]byte{}, data...)
+	for i := 0; i < 10; i++ {
+		pos := rng.Intn(len(edited))
+		switch i % 3 {
+		case 0:
+			edited[pos] ^= 0xff
+		case 1:
+			insert := []byte("inserted text")
+			edited = append(edited[:pos], append(insert, edited[pos:]...)...)
+		case 2:
+			end := pos + rng.Intn(100)
+			if end > len(edited) {
+				end = len(edited)
+			}
+			edited = append(edited[:pos], edited[end:]...)
+		}
+	}
+	return edited
+}
+
+func TestDeltaEncodingAndDecoding(t *testing.T) {
+	kalevala := tu.ReadFile(testKalevala)
+	edited := edit(kalevala, rand.New(rand.NewSource(1)))
+	random := make([]byte, 10000)
+	rand.New(rand.NewSource(2)).Read(random)
+	cases := []struct {
+		desc     string
+		old, new []byte
+		maxSize  int
+	}{
+		{desc: "Empty", old: []byte{}, new: []byte{}, maxSize: 30},
+		{desc: "EmptyOld", old: []byte{}, new: []byte("abcabcabc"), maxSize: 40},
+		{desc: "EmptyNew", old: []byte("abcabcabc"), new: []byte{}, maxSize: 30},
+		{desc: "Unchanged", old: kalevala, new: kalevala, maxSize: 40},
+		{desc: "Edited", old: kalevala, new: edited, maxSize: 200},
+		{desc: "Unrelated", old: random, new: kalevala, maxSize: len(kalevala)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var patch, decoded bytes.Buffer
+			tu.ExpectNil(t, EncodeDelta(c.old, bytes.NewReader(c.new), &patch))
+			if patch.Len() > c.maxSize {
+				t.Fatalf("expected a patch of at most %d bytes, found %d", c.maxSize, patch.Len())
+			}
+			tu.ExpectNil(t, DecodeDelta(c.old, &patch, &decoded))
+			if !bytes.Equal(c.new, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecodeDeltaReferenceMismatch(t *testing.T) {
+	old := []byte("abcdefghijklmnopqrstuvwxyz")
+	var patch bytes.Buffer
+	tu.ExpectNil(t, EncodeDelta(old, bytes.NewReader([]byte("abcdefghij")), &patch))
+	cases := []struct {
+		desc string
+		old  []byte
+	}{
+		{desc: "Length", old: old[:25]},
+		{desc: "Contents", old: bytes.ToUpper(old)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := DecodeDelta(c.old, bytes.NewReader(patch.Bytes()), ioutil.Discard)
+			tu.Check(t, ErrReferenceMismatch, err)
+		})
+	}
+}
+
+func TestDecodeDeltaCorruptInput(t *testing.T) {
+	old := []byte("abcdefghijklmnopqrstuvwxyz")
+	var patch bytes.Buffer
+	tu.ExpectNil(t, EncodeDelta(old, bytes.NewReader([]byte("xyzabc")), &patch))
+	valid := patch.Bytes()
+	corrupt := func(i int) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= 0x01
+		return data
+	}
+	cases := []struct {
+		desc   string
+		data   []byte
+		offset int64
+	}{
+		{desc: "Empty", data: []byte{}, offset: 0},
+		{desc: "Magic", data: corrupt(0), offset: 0},
+		{desc: "Version", data: corrupt(4), offset: 4},
+		{desc: "TruncatedHeader", data: valid[:10], offset: 10},
+		{desc: "TruncatedUnits", data: valid[:deltaHeaderSize], offset: int64(deltaHeaderSize)},
+		{desc: "TruncatedTrailer", data: valid[:len(valid)-1], offset: int64(len(valid)) - 12},
+		{desc: "Length", data: corrupt(len(valid) - 12), offset: int64(len(valid)) - 12},
+		{desc: "Checksum", data: corrupt(len(valid) - 1), offset: int64(len(valid)) - 4},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := DecodeDelta(old, bytes.NewReader(c.data), ioutil.Discard)
+			corrupt, ok := err.(*CorruptInputError)
+			if !ok {
+				t.Fatalf("expected *CorruptInputError, found %v", err)
+			}
+			tu.Check(t, c.offset, corrupt.Offset)
+		})
+	}
+}
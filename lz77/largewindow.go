@@ -0,0 +1,288 @@
+package lz77
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/intcode"
+)
+
+// The large window format produced by EncodeLargeWindow is marked with its own
+// format version. The stream header is followed by the base-2 logarithm of the
+// window size as a byte and a bit stream of units. Bits are packed into bytes
+// starting from the most significant bit.
+//
+// A literal is a 0-bit followed by the 8 bits of the byte. A reference is a
+// 1-bit followed by the length coded using the Elias gamma code and the
+// distance coded using the Elias delta code. Lengths have no upper limit and
+// are at least 2, so a length of 1 is used as the end marker. The end marker is
+// followed by zero bits up to the next byte boundary and the trailer.
+const (
+	largeWindowFormatVersion = 3
+	// largeLookaheadSize is the amount of data searched for in the window at
+	// a time. Longer matches are found by extending the matches found.
+	largeLookaheadSize = 256
+	// largeWindowMaxChain limits the time spent finding each match.
+	largeWindowMaxChain = 256
+	largeEndMarker      = 1
+	minLargeMatchLength = 2
+	// literalCost is the number of bits taken by a literal.
+	literalCost = 9
+)
+
+// These constants specify the supported window sizes of EncodeLargeWindow as
+// base-2 logarithms.
+const (
+	MinWindowBits     = 12
+	MaxWindowBits     = 22
+	DefaultWindowBits = 20
+)
+
+// LargeWindowOptions specifies the format produced by EncodeLargeWindow.
+type LargeWindowOptions struct {
+	// WindowBits is the base-2 logarithm of the window size. It must be in
+	// range [MinWindowBits, MaxWindowBits]. If it is 0, DefaultWindowBits
+	// is used.
+	WindowBits int
+}
+
+func (o LargeWindowOptions) valid() bool {
+	return o.WindowBits == 0 ||
+		(o.WindowBits >= MinWindowBits && o.WindowBits <= MaxWindowBits)
+}
+
+// EncodeLargeWindow is like Encode but codes references using variable-length
+// integer codes. This allows a much larger window and matches of any length, so
+// repeats far apart in the input are found and long repeats are coded as
+// single references.
+func EncodeLargeWindow(input io.Reader, output io.Writer, opts LargeWindowOptions) error {
+	if !opts.valid() {
+		return ErrOptions
+	}
+	if opts.WindowBits == 0 {
+		opts.WindowBits = DefaultWindowBits
+	}
+	summer := &checksum.Reader{R: input, Hash: &checksum.CRC32{}}
+	src := bufio.NewReaderSize(summer, largeLookaheadSize)
+	dst := bits.NewWriter(output)
+	header := [headerSize + 1]byte{
+		magic[0], magic[1], magic[2], magic[3], largeWindowFormatVersion, byte(opts.WindowBits),
+	}
+	for _, b := range header {
+		if err := dst.WriteByte(b); err != nil {
+			return err
+		}
+	}
+	window := newEncoderWindowBuffer(1 << uint(opts.WindowBits))
+	window.maxChain = largeWindowMaxChain
+	if err := encodeVariableUnits(src, dst, window); err != nil {
+		return err
+	}
+	if err := dst.Flush(); err != nil {
+		return err
+	}
+	var trailer [trailerSize]byte
+	binary.PutUint64LE(trailer[:], uint64(summer.N))
+	binary.PutUint32LE(trailer[8:], summer.Hash.Sum32())
+	_, err := output.Write(trailer[:])
+	return err
+}
+
+// encodeVariableUnits encodes all data from src as units of the large window
+// format terminated by the end marker and writes them to dst. Data already in
+// window can be referred to.
+func encodeVariableUnits(src *bufio.Reader, dst *bits.Writer, window *encoderWindowBuffer) error {
+	lastDistance := 0
+	for {
+		lookahead, err := src.Peek(largeLookaheadSize)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(lookahead) == 0 {
+			break
+		}
+		length, distance := window.findMatch(lookahead)
+		// The data after a changed byte is likely to continue the previous
+		// match, which the limited search may miss in a large window.
+		if lastDistance > 0 && lastDistance <= window.size && int64(lastDistance) <= window.pos {
+			if n := window.extendMatch(lookahead, lastDistance); n > length {
+				length, distance = n, lastDistance
+			}
+		}
+		if length < minLargeMatchLength || referenceCost(length, distance) >= literalCost*length {
+			if err := dst.WriteBit(false); err != nil {
+				return err
+			}
+			if err := dst.WriteByte(lookahead[0]); err != nil {
+				return err
+			}
+			window.appendByte(lookahead[0])
+			src.Discard(1)
+			continue
+		}
+		window.append(lookahead[:length])
+		src.Discard(length)
+		// A match covering the whole lookahead may continue past it.
+		for extended := length == len(lookahead); extended; {
+			lookahead, err := src.Peek(largeLookaheadSize)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			n := window.extendMatch(lookahead, distance)
+			window.append(lookahead[:n])
+			src.Discard(n)
+			length += n
+			extended = n > 0 && n == len(lookahead)
+		}
+		if err := dst.WriteBit(true); err != nil {
+			return err
+		}
+		if err := intcode.WriteGamma(dst, uint64(length)); err != nil {
+			return err
+		}
+		if err := intcode.WriteDelta(dst, uint64(distance)); err != nil {
+			return err
+		}
+		lastDistance = distance
+	}
+	if err := dst.WriteBit(true); err != nil {
+		return err
+	}
+	return intcode.WriteGamma(dst, largeEndMarker)
+}
+
+// referenceCost returns the number of bits taken by a reference.
+func referenceCost(length, distance int) int {
+	return 1 + intcode.GammaLen(uint64(length)) + intcode.DeltaLen(uint64(distance))
+}
+
+// bitDecoder reads the bit stream of the large window format and keeps track
+// of the position in the input.
+type bitDecoder struct {
+	r *bits.Reader
+	// base is the offset of the start of r in the input.
+	base int64
+}
+
+// offset returns the offset of the byte containing the next bit.
+func (d *bitDecoder) offset() int64 {
+	return d.base + d.r.BitsRead()/8
+}
+
+func (d *bitDecoder) readBit() (bool, error) {
+	bit, err := d.r.ReadBit()
+	if err != nil {
+		return false, truncated(err, d.offset())
+	}
+	return bit, nil
+}
+
+// readUint reads a width bits wide integer starting from the most significant
+// bit.
+func (d *bitDecoder) readUint(width uint) (uint64, error) {
+	n, err := d.r.ReadBits(width)
+	if err != nil {
+		return 0, truncated(err, d.offset())
+	}
+	return n, nil
+}
+
+// readCode reads an integer coded using read.
+func (d *bitDecoder) readCode(read func(*bits.Reader) (uint64, error)) (uint64, error) {
+	start := d.offset()
+	n, err := read(d.r)
+	if err == intcode.ErrOverflow {
+		return 0, &CorruptInputError{start, "invalid integer code"}
+	}
+	if err != nil {
+		return 0, truncated(err, d.offset())
+	}
+	return n, nil
+}
+
+// readTrailer skips the padding after the end marker and reads the trailer.
+// The offset of the trailer is also returned.
+func (d *bitDecoder) readTrailer() (trailer [trailerSize]byte, offset int64, err error) {
+	d.r.AlignToByte()
+	offset = d.offset()
+	if _, err := d.r.ReadAlignedBytes(trailer[:]); err != nil {
+		return trailer, offset, truncated(err, offset)
+	}
+	return trailer, offset, nil
+}
+
+// decodeLargeWindow decodes the large window format from src after the stream
+// header and writes the decoded data to summer.
+func decodeLargeWindow(src io.Reader, summer *checksum.Writer) error {
+	d := &bitDecoder{r: bits.NewReader(src), base: int64(headerSize)}
+	windowBits, err := d.readUint(8)
+	if err != nil {
+		return err
+	}
+	if windowBits < MinWindowBits || windowBits > MaxWindowBits {
+		return &CorruptInputError{int64(headerSize), fmt.Sprintf("unsupported window size 2^%d", windowBits)}
+	}
+	window := 1 << uint(windowBits)
+	history := newHistoryBuffer(summer, window, window+historyBufferSize)
+	if err := decodeVariableUnits(d, history, window); err != nil {
+		return err
+	}
+	trailer, offset, err := d.readTrailer()
+	if err != nil {
+		return err
+	}
+	return checkTrailer(trailer[:], offset, history.total, summer.Hash.Sum32())
+}
+
+// decodeVariableUnits decodes units of the large window format from d up to
+// and including the end marker and appends the decoded data to history.
+// References may refer up to window bytes back.
+func decodeVariableUnits(d *bitDecoder, history *historyBuffer, window int) error {
+	for {
+		start := d.offset()
+		isReference, err := d.readBit()
+		if err != nil {
+			return err
+		}
+		if !isReference {
+			b, err := d.readUint(8)
+			if err != nil {
+				return err
+			}
+			if err := history.writeByte(byte(b)); err != nil {
+				return err
+			}
+			continue
+		}
+		length, err := d.readCode(intcode.ReadGamma)
+		if err != nil {
+			return err
+		}
+		if length == largeEndMarker {
+			break
+		}
+		distance, err := d.readCode(intcode.ReadDelta)
+		if err != nil {
+			return err
+		}
+		if distance > uint64(window) || distance > uint64(history.total) {
+			return &CorruptInputError{start, "reference distance out of range"}
+		}
+		// The match is copied in parts fitting in the history buffer.
+		for length > 0 {
+			n := length
+			if n > historyBufferSize {
+				n = historyBufferSize
+			}
+			if err := history.copyMatch(int(distance), int(n)); err != nil {
+				return err
+			}
+			length -= n
+		}
+	}
+	return history.flush()
+}
@@ -0,0 +1,133 @@
+package lz77
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala5 = "../test/files/complexity-analysis/kalevala5.txt"
+)
+
+func TestLargeWindowEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	cases := []struct {
+		desc string
+		data []byte
+		opts LargeWindowOptions
+	}{
+		{desc: "Empty", data: []byte{}},
+		{desc: "Short", data: []byte("abcabcabcabcabcabc")},
+		{desc: "Run", data: bytes.Repeat([]byte{'x'}, 1000000)},
+		{desc: "Random", data: random, opts: LargeWindowOptions{WindowBits: MinWindowBits}},
+		{desc: "Kalevala", data: tu.ReadFile(testKalevala)},
+		{desc: "Kalevala5", data: tu.ReadFile(testKalevala5), opts: LargeWindowOptions{WindowBits: MaxWindowBits}},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			tu.ExpectNil(t, EncodeLargeWindow(bytes.NewReader(c.data), &encoded, c.opts))
+			tu.ExpectNil(t, Decode(&encoded, &decoded))
+			if !bytes.Equal(c.data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestLargeWindowLongRepeats(t *testing.T) {
+	// kalevala5.txt consists of copies of kalevala.txt, so each copy after the
+	// first one should take only a few bytes.
+	var single, repeated bytes.Buffer
+	tu.ExpectNil(t, EncodeLargeWindow(bytes.NewReader(tu.ReadFile(testKalevala)), &single, LargeWindowOptions{}))
+	tu.ExpectNil(t, EncodeLargeWindow(bytes.NewReader(tu.ReadFile(testKalevala5)), &repeated, LargeWindowOptions{}))
+	if repeated.Len() > single.Len()+100 {
+		t.Fatalf("expected at most %d bytes, found %d", single.Len()+100, repeated.Len())
+	}
+}
+
+func TestLargeWindowFormat(t *testing.T) {
+	// The units are literal 'a', literal 'b', reference (2, 2) and the end
+	// marker. The reference consists of a 1-bit, gamma code 010 and delta code
+	// 0100. The end marker is a 1-bit followed by gamma code 1.
+	expected := "4c5a37370314" + "3098a930" + "0400000000000000" + "a60ad736"
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, EncodeLargeWindow(bytes.NewReader([]byte("abab")), &encoded, LargeWindowOptions{WindowBits: 20}))
+	tu.Check(t, expected, hex.EncodeToString(encoded.Bytes()))
+}
+
+func TestDecodeLargeWindowCorruptInput(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, EncodeLargeWindow(bytes.NewReader([]byte("abab")), &encoded, LargeWindowOptions{}))
+	valid := encoded.Bytes()
+	corrupt := func(i int) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= 0x01
+		return data
+	}
+	stream := func(s string) []byte {
+		return []byte("LZ77\x03\x14" + s)
+	}
+	cases := []struct {
+		desc   string
+		data   []byte
+		offset int64
+	}{
+		{desc: "WindowSize", data: []byte("LZ77\x03\x30"), offset: 5},
+		{desc: "Truncated", data: []byte("LZ77\x03"), offset: 5},
+		// A literal 'a' followed by a reference (2, 2).
+		{desc: "DistanceBeforeStart", data: stream("\x30\xd2\x00"), offset: 7},
+		// A reference with a gamma code of 64 zeros.
+		{desc: "InvalidGamma", data: stream("\x80\x00\x00\x00\x00\x00\x00\x00\x00\x80"), offset: 6},
+		// A literal whose byte is cut short by the end of the input.
+		{desc: "MissingEndMarker", data: stream("\x30"), offset: 6},
+		{desc: "TruncatedTrailer", data: valid[:len(valid)-1], offset: int64(len(valid)) - 12},
+		{desc: "Length", data: corrupt(len(valid) - 12), offset: int64(len(valid)) - 12},
+		{desc: "Checksum", data: corrupt(len(valid) - 1), offset: int64(len(valid)) - 4},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := Decode(bytes.NewReader(c.data), ioutil.Discard)
+			corrupt, ok := err.(*CorruptInputError)
+			if !ok {
+				t.Fatalf("expected *CorruptInputError, found %v", err)
+			}
+			tu.Check(t, c.offset, corrupt.Offset)
+		})
+	}
+}
+
+func TestLargeWindowOptions(t *testing.T) {
+	for _, windowBits := range []int{-1, MinWindowBits - 1, MaxWindowBits + 1} {
+		opts := LargeWindowOptions{WindowBits: windowBits}
+		tu.Check(t, ErrOptions, EncodeLargeWindow(bytes.NewReader(nil), ioutil.Discard, opts))
+	}
+}
+
+func BenchmarkEncodeLargeWindow(b *testing.B) {
+	input := tu.ReadFile(testKalevala)
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		EncodeLargeWindow(r, ioutil.Discard, LargeWindowOptions{})
+	}
+}
+
+func BenchmarkDecodeLargeWindow(b *testing.B) {
+	var encoded bytes.Buffer
+	EncodeLargeWindow(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded, LargeWindowOptions{})
+	r := bytes.NewReader(encoded.Bytes())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(encoded.Bytes())
+		Decode(r, ioutil.Discard)
+	}
+}
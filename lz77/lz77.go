@@ -1,29 +1,48 @@
 /*
 Package lz77 implements LZ77 encoding and decoding.
 
-Encode outputs data in blocks. A block starts with an 8-bit header and is
-followed by at most eight data units. At the end of the data stream there may be
-less than 8 units following a header. In this case the bits in the header
-without corresponding data units are meaningless.
+Encoded data starts with a 5-byte stream header consisting of the magic bytes
+"LZ77" and a format version byte. The header is followed by blocks.
 
-Each bit in the header specifies the type of the corresponding unit following
-the header. A 0-bit means the corresponding unit is a literal byte. A 1-bit
-means the corresponding unit is a reference to a previous location in the data
-stream.
+A block starts with an 8-bit header and is followed by at most eight data
+units. Each bit in the header specifies the type of the corresponding unit
+following the header. A 0-bit means the corresponding unit is a literal byte. A
+1-bit means the corresponding unit is a reference to a previous location in the
+data stream.
 
 A reference is a pair (l, d) where l is the length of the referred byte sequence
 and d is the starting point of the sequence as an offset from the current
 position. References are encoded as unsigned 16-bit little-endian integers. The
 length part of the integer is 4 bits and the distance part takes the remaining
 12 bits.
+
+The last unit of the data stream is an end marker, which is a reference whose
+length and distance are both 0. The bits in the header of the last block without
+corresponding data units are meaningless. The end marker is followed by a
+trailer containing the length of the uncompressed data as a little-endian
+uint64 value and the CRC-32 checksum of the uncompressed data as a
+little-endian uint32 value.
+
+EncodeParallel splits the data into blocks compressed concurrently. Its output
+has format version 2 and stores each block as a separate series of units
+prefixed with the compressed and uncompressed sizes of the block.
+
+EncodeLargeWindow codes references using variable-length integer codes instead
+of 16-bit values, which allows windows of up to 4 MiB and matches of any
+length. Its output has format version 3.
+
+Decode and DecodeParallel accept all three formats.
 */
 package lz77
 
 import (
+	"fmt"
 	"io"
 
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
 )
 
@@ -38,18 +57,92 @@ const (
 const (
 	lookaheadBufferSize = (1 << refLenBits) - 1
 	windowBufferSize    = (1 << refDistBits) - 1
+	// historyBufferSize is the size of the buffer holding decoded data.
+	historyBufferSize = 1 << 18
 )
 
+// These constants specify the stream header and trailer.
+const (
+	magic         = "LZ77"
+	formatVersion = 1
+	headerSize    = len(magic) + 1
+	trailerSize   = 8 + 4
+)
+
+// endMarker is the value of the reference marking the end of the data stream.
+const endMarker = 0
+
 // Encode reads data from input, encodes it using LZ77 and writes the result to
 // output.
 func Encode(input io.Reader, output io.Writer) error {
-	src := bufio.NewReaderSize(input, lookaheadBufferSize)
-	dst := bufio.NewWriter(output)
-	window := newEncoderWindowBuffer(windowBufferSize)
-	headerBuf := make([]byte, 1)
-	units := make([]uint16, 0, 8)
+	return EncodeTrace(input, output, nil)
+}
 
-	for {
+// EncodeTrace is like Encode but also reports each encoded unit to tracer. If
+// tracer is nil, nothing is reported.
+func EncodeTrace(input io.Reader, output io.Writer, tracer Tracer) error {
+	return new(Encoder).EncodeTrace(input, output, tracer)
+}
+
+// Encoder encodes data like Encode but reuses its buffers and window between
+// calls, so encoding allocates nothing after the first call. The zero value is
+// ready for use. An Encoder may be reused after an error but must not be used
+// by multiple goroutines at once.
+type Encoder struct {
+	summer checksum.Reader
+	crc    checksum.CRC32
+	src    *bufio.Reader
+	dst    *bufio.Writer
+	window *encoderWindowBuffer
+}
+
+// NewEncoder returns a new Encoder.
+func NewEncoder() *Encoder {
+	return &Encoder{}
+}
+
+// Encode reads data from input, encodes it using LZ77 and writes the result to
+// output.
+func (e *Encoder) Encode(input io.Reader, output io.Writer) error {
+	return e.EncodeTrace(input, output, nil)
+}
+
+// EncodeTrace is like Encode but also reports each encoded unit to tracer. If
+// tracer is nil, nothing is reported.
+func (e *Encoder) EncodeTrace(input io.Reader, output io.Writer, tracer Tracer) error {
+	e.crc.Reset()
+	e.summer = checksum.Reader{R: input, Hash: &e.crc}
+	if e.src == nil {
+		e.src = bufio.NewReaderSize(&e.summer, lookaheadBufferSize)
+		e.dst = bufio.NewWriter(output)
+		e.window = newEncoderWindowBuffer(windowBufferSize)
+	} else {
+		e.src.Reset(&e.summer)
+		e.dst.Reset(output)
+		e.window.reset()
+	}
+	streamHeader := [headerSize]byte{magic[0], magic[1], magic[2], magic[3], formatVersion}
+	if _, err := e.dst.Write(streamHeader[:]); err != nil {
+		return err
+	}
+	if err := encodeUnits(e.src, e.dst, e.window, tracer); err != nil {
+		return err
+	}
+	if err := writeTrailer(e.dst, &e.summer); err != nil {
+		return err
+	}
+	return e.dst.Flush()
+}
+
+// encodeUnits encodes all data from src as blocks of units terminated by the
+// end marker and writes them to dst. Data already in window can be referred to.
+// If tracer is not nil, each unit is reported to it.
+func encodeUnits(src *bufio.Reader, dst *bufio.Writer, window *encoderWindowBuffer, tracer Tracer) error {
+	var headerArr [1]byte
+	var unitsArr [8]uint16
+	headerBuf := headerArr[:]
+	units := unitsArr[:0]
+	for done := false; !done; {
 		headerBuf[0] = 0
 		unitHeader := bits.NewList(headerBuf)
 		units = units[:0]
@@ -60,6 +153,9 @@ func Encode(input io.Reader, output io.Writer) error {
 					return err
 				}
 				if len(lookahead) == 0 {
+					unitHeader.Set(i, true)
+					units = slices.AppendUint16(units, endMarker)
+					done = true
 					break
 				}
 			}
@@ -70,9 +166,15 @@ func Encode(input io.Reader, output io.Writer) error {
 				if err != nil {
 					return err
 				}
+				if tracer != nil {
+					tracer.Literal(window.pos, next)
+				}
 				units = slices.AppendUint16(units, uint16(next))
 				window.appendByte(next)
 			} else {
+				if tracer != nil {
+					tracer.Reference(window.pos, int(ref.length), int(ref.distance))
+				}
 				unitHeader.Set(i, true)
 				units = slices.AppendUint16(units, ref.asUint16())
 				window.append(lookahead[:ref.length])
@@ -81,9 +183,6 @@ func Encode(input io.Reader, output io.Writer) error {
 				}
 			}
 		}
-		if len(units) == 0 {
-			break
-		}
 		if _, err := dst.Write(headerBuf); err != nil {
 			return err
 		}
@@ -99,7 +198,17 @@ func Encode(input io.Reader, output io.Writer) error {
 			}
 		}
 	}
-	return dst.Flush()
+	return nil
+}
+
+// writeTrailer writes the stream trailer describing the data read through
+// summer to w.
+func writeTrailer(w *bufio.Writer, summer *checksum.Reader) error {
+	var trailer [trailerSize]byte
+	binary.PutUint64LE(trailer[:], uint64(summer.N))
+	binary.PutUint32LE(trailer[8:], summer.Hash.Sum32())
+	_, err := w.Write(trailer[:])
+	return err
 }
 
 // writeUint16 writes n to w in little-endian byte order.
@@ -109,51 +218,181 @@ func writeUint16(w *bufio.Writer, n uint16) error {
 	return err
 }
 
+// CorruptInputError is returned by Decode if the input is not valid LZ77 data.
+type CorruptInputError struct {
+	// Offset is the position in the input where the invalid data starts.
+	Offset int64
+	// Reason describes the problem with the data.
+	Reason string
+}
+
+func (e *CorruptInputError) Error() string {
+	return fmt.Sprintf("lz77: corrupt input at offset %d: %s", e.Offset, e.Reason)
+}
+
+// Decode reads LZ77 encoded data from input, decodes it and writes the decoded
+// data to output. A *CorruptInputError is returned if the stream header is
+// invalid, a reference points outside the decoded data, a reference has zero
+// length, the input ends before the trailer or the trailer doesn't match the
+// decoded data.
+func Decode(input io.Reader, output io.Writer) error {
+	return DecodeParallel(input, output, 1)
+}
+
+// DecodeParallel is like Decode but decodes data produced by EncodeParallel
+// using the specified number of worker goroutines. Data produced by Encode is
+// always decoded sequentially.
+func DecodeParallel(input io.Reader, output io.Writer, workers int) error {
+	return new(Decoder).decode(input, output, workers)
+}
+
+// Decoder decodes data like Decode but reuses its buffers between calls, so
+// decoding data produced by Encode or an Encoder allocates nothing after the
+// first call. The zero value is ready for use. A Decoder may be reused after an
+// error but must not be used by multiple goroutines at once.
+type Decoder struct {
+	src     *bufio.Reader
+	summer  checksum.Writer
+	crc     checksum.CRC32
+	history *historyBuffer
+}
+
+// NewDecoder returns a new Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{}
+}
+
 // Decode reads LZ77 encoded data from input, decodes it and writes the decoded
-// data to output.
-func Decode(input io.Reader, output io.Writer) (err error) {
-	src := bits.NewReader(input)
-	dst := bufio.NewWriter(output)
-	window := newWindowBuffer(windowBufferSize)
-	headerBuf := make([]byte, 1)
+// data to output like the package-level Decode.
+func (d *Decoder) Decode(input io.Reader, output io.Writer) error {
+	return d.decode(input, output, 1)
+}
 
+// decode decodes data from input to output using the specified number of
+// worker goroutines for data produced by EncodeParallel.
+func (d *Decoder) decode(input io.Reader, output io.Writer, workers int) error {
+	if workers < 1 {
+		return ErrOptions
+	}
+	if d.src == nil {
+		d.src = bufio.NewReader(input)
+	} else {
+		d.src.Reset(input)
+	}
+	src := d.src
+	d.crc.Reset()
+	d.summer = checksum.Writer{W: output, Hash: &d.crc}
+	summer := &d.summer
+	var streamHeader [headerSize]byte
+	if _, err := src.Read(streamHeader[:]); err != nil {
+		return truncated(err, 0)
+	}
+	if string(streamHeader[:len(magic)]) != magic {
+		return &CorruptInputError{0, "invalid magic"}
+	}
+	var total int64
+	offset := int64(headerSize)
+	switch v := streamHeader[len(magic)]; v {
+	case formatVersion:
+		if d.history == nil {
+			d.history = newHistoryBuffer(summer, windowBufferSize, historyBufferSize)
+		} else {
+			d.history.reset(summer, nil)
+		}
+		history := d.history
+		var err error
+		if offset, err = decodeUnits(src, history, offset); err != nil {
+			return err
+		}
+		if err := history.flush(); err != nil {
+			return err
+		}
+		total = history.total
+	case blockFormatVersion:
+		var err error
+		if offset, err = decodeBlocks(src, summer, offset, workers); err != nil {
+			return err
+		}
+		total = summer.N
+	case largeWindowFormatVersion:
+		return decodeLargeWindow(src, summer)
+	default:
+		return &CorruptInputError{int64(len(magic)), fmt.Sprintf("unsupported version %d", v)}
+	}
+	var trailer [trailerSize]byte
+	if _, err := src.Read(trailer[:]); err != nil {
+		return truncated(err, offset)
+	}
+	return checkTrailer(trailer[:], offset, total, summer.Hash.Sum32())
+}
+
+// checkTrailer checks that the trailer at offset matches the decoded data of
+// total bytes with checksum crc.
+func checkTrailer(trailer []byte, offset, total int64, crc uint32) error {
+	if binary.GetUint64LE(trailer) != uint64(total) {
+		return &CorruptInputError{offset, "length mismatch"}
+	}
+	if binary.GetUint32LE(trailer[8:]) != crc {
+		return &CorruptInputError{offset + 8, "checksum mismatch"}
+	}
+	return nil
+}
+
+// decodeUnits decodes blocks of units from src up to and including the end
+// marker and appends the decoded data to history. offset is the position of
+// src in the input and the position after the end marker is returned.
+func decodeUnits(src *bufio.Reader, history *historyBuffer, offset int64) (int64, error) {
+	var headerArr [1]byte
+	headerBuf := headerArr[:]
 	for {
+		var err error
 		headerBuf[0], err = src.ReadByte()
 		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
+			return offset, truncated(err, offset)
 		}
+		offset++
 		header := bits.NewList(headerBuf)
 		for i := 0; i < header.Len(); i++ {
 			if header.Get(i) {
 				ref, err := decodeReference(src)
 				if err != nil {
-					if err == io.EOF {
-						break
-					}
-					return err
+					return offset, truncated(err, offset)
 				}
-				if err := window.expandReference(dst, ref); err != nil {
-					return err
+				if ref.asUint16() == endMarker {
+					return offset + 2, nil
+				}
+				if ref.length == 0 {
+					return offset, &CorruptInputError{offset, "zero-length reference"}
+				}
+				if ref.distance == 0 || int64(ref.distance) > history.total {
+					return offset, &CorruptInputError{offset, "reference distance out of range"}
+				}
+				if err := history.copyMatch(int(ref.distance), int(ref.length)); err != nil {
+					return offset, err
 				}
+				offset += 2
 			} else {
 				next, err := src.ReadByte()
 				if err != nil {
-					if err == io.EOF {
-						break
-					}
-					return err
+					return offset, truncated(err, offset)
 				}
-				if err := dst.WriteByte(next); err != nil {
-					return err
+				if err := history.writeByte(next); err != nil {
+					return offset, err
 				}
-				window.appendByte(next)
+				offset++
 			}
 		}
 	}
-	return dst.Flush()
+}
+
+// truncated converts an error caused by the input ending before the end of the
+// data stream at offset to a *CorruptInputError. Other errors are returned as
+// is.
+func truncated(err error, offset int64) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &CorruptInputError{offset, "unexpected end of input"}
+	}
+	return err
 }
 
 // reference is a reference to an earlier byte sequence in the current window
@@ -168,172 +407,238 @@ func (r reference) asUint16() uint16 {
 }
 
 // decodeReference decodes a single reference from r.
-func decodeReference(r *bits.Reader) (reference, error) {
-	ref, err := r.ReadUint16()
-	if err != nil {
+func decodeReference(r *bufio.Reader) (reference, error) {
+	var data [2]byte
+	if n, err := r.Read(data[:]); err != nil {
+		if err == io.EOF && n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
 		return reference{}, err
 	}
+	ref := uint16(data[0]) | uint16(data[1])<<8
 	return reference{
 		length:   ref >> refDistBits,
 		distance: ref & (^uint16(0) >> refLenBits),
 	}, nil
 }
 
-// windowBuffer is a sliding window that keeps track of recent processed bytes
-// to allow replacing future duplicate byte sequences with references.
-type windowBuffer struct {
-	// buf stores the data. It's size is larger than the size of the window so
-	// that moving data from the end of the window to the beginning can be done
-	// less often.
+// historyBuffer holds the data produced by the decoder. Literals are appended
+// and matches are copied directly within the buffer. When the buffer becomes
+// full, the decoded data is written to the output in a single write and the
+// most recent window of data is moved to the beginning of the buffer.
+type historyBuffer struct {
+	out io.Writer
 	buf []byte
-	// The byte range [start,start+size) in buf contains the current state of
-	// the window.
-	start, size int
+	// pos is the end of the decoded data in buf. Data before written has
+	// already been written to out.
+	pos, written int
+	// window is the amount of data kept when the buffer is emptied.
+	window int
+	// total is the total amount of decoded data.
+	total int64
 }
 
-// newWindowBuffer returns a windowBuffer capable of holding size bytes of
-// recent data.
-func newWindowBuffer(size int) *windowBuffer {
-	return &windowBuffer{
-		buf:  make([]byte, 4*size),
-		size: size,
+// newHistoryBuffer returns a historyBuffer that writes to out and can refer to
+// window bytes of earlier data. size is the size of the buffer and must be
+// larger than window.
+func newHistoryBuffer(out io.Writer, window, size int) *historyBuffer {
+	return &historyBuffer{
+		out:    out,
+		buf:    make([]byte, size),
+		window: window,
 	}
 }
 
-// append copies bytes in data to the end of the window while discarding an
-// equal amount of bytes from the beginning of the window.
-func (w *windowBuffer) append(data []byte) {
-	if w.start+w.size+len(data) >= len(w.buf) {
-		slices.CopyBytes(w.buf, w.buf[w.start+len(data):w.start+w.size])
-		w.start = -len(data)
+// writeByte appends b to the decoded data.
+func (h *historyBuffer) writeByte(b byte) error {
+	if h.pos == len(h.buf) {
+		if err := h.slide(); err != nil {
+			return err
+		}
 	}
-	slices.CopyBytes(w.buf[w.start+w.size:], data)
-	w.start += len(data)
-}
-
-// appendByte is similar to append but for a single byte.
-func (w *windowBuffer) appendByte(b byte) {
-	w.append([]byte{b})
+	h.buf[h.pos] = b
+	h.pos++
+	h.total++
+	return nil
 }
 
-// expandReference expands ref by writing the corresponding byte sequence in the
-// window to out and the end of the window.
-func (w *windowBuffer) expandReference(out *bufio.Writer, ref reference) error {
-	start := w.size - int(ref.distance)
-	for i := 0; i < int(ref.length); i++ {
-		byt := w.get(start)
-		if err := out.WriteByte(byt); err != nil {
+// copyMatch appends length bytes starting distance bytes before the end of the
+// decoded data. The match may overlap with the data it produces, in which case
+// the last distance bytes are repeated. distance must be in range
+// [1, min(window, h.total)] and length at most len(h.buf)-window.
+func (h *historyBuffer) copyMatch(distance, length int) error {
+	if len(h.buf)-h.pos < length {
+		if err := h.slide(); err != nil {
 			return err
 		}
-		w.appendByte(byt)
 	}
+	src := h.pos - distance
+	dst := h.buf[h.pos : h.pos+length]
+	// Each copy doubles the amount of data available to copy from, so
+	// overlapping matches need only a logarithmic number of copies.
+	for n := 0; n < length; {
+		n += slices.CopyBytes(dst[n:], h.buf[src:h.pos+n])
+	}
+	h.pos += length
+	h.total += int64(length)
+	return nil
+}
+
+// slide writes the pending decoded data to the output and moves the most
+// recent window of data to the beginning of the buffer.
+func (h *historyBuffer) slide() error {
+	if _, err := h.out.Write(h.buf[h.written:h.pos]); err != nil {
+		return err
+	}
+	slices.CopyBytes(h.buf, h.buf[h.pos-h.window:h.pos])
+	h.pos = h.window
+	h.written = h.window
 	return nil
 }
 
-// get returns the byte at logical index i in the window.
-func (w *windowBuffer) get(i int) byte {
-	return w.buf[w.start+i]
+// reset discards the decoded data and makes h write to out. prime is placed in
+// the buffer as earlier data that can be referred to but isn't written to out.
+// prime must not be longer than the window.
+func (h *historyBuffer) reset(out io.Writer, prime []byte) {
+	h.out = out
+	h.pos = slices.CopyBytes(h.buf, prime)
+	h.written = h.pos
+	h.total = int64(h.pos)
+}
+
+// flush writes the pending decoded data to the output.
+func (h *historyBuffer) flush() error {
+	_, err := h.out.Write(h.buf[h.written:h.pos])
+	h.written = h.pos
+	return err
 }
 
-// encoderWindowBuffer pairs a windowBuffer instance with a dictionary to
-// support finding longest prefixes of data in the window.
+// encoderWindowBuffer is a sliding window that keeps track of recent processed
+// bytes to allow replacing future duplicate byte sequences with references. It
+// pairs a ring buffer holding the window with a dictionary to support finding
+// longest prefixes of data in the window. No memory is allocated after the
+// window is created.
 type encoderWindowBuffer struct {
-	win *windowBuffer
+	// buf is a ring buffer holding the data in the window. Its size is a
+	// power of two at least as large as the window.
+	buf  []byte
+	mask int64
+	// size is the size of the window.
+	size int
 	// A dictionary used to speed up prefix matching performance.
 	dict *dictionary
-	// A monotonically increasing counter representing the current position in
-	// the data stream.
+	// pos is the position of the next byte in the data stream.
 	pos int64
+	// maxChain limits the number of positions examined when finding a
+	// prefix. 0 means no limit.
+	maxChain int
 }
 
 // newEncoderWindowBuffer returns an encoderWindowBuffer with the specified
 // size.
 func newEncoderWindowBuffer(size int) *encoderWindowBuffer {
+	dict := newDictionary(size)
 	return &encoderWindowBuffer{
-		win:  newWindowBuffer(size),
-		dict: newDictionary(),
-		pos:  int64(size),
+		buf:  make([]byte, len(dict.prev)),
+		mask: dict.mask,
+		size: size,
+		dict: dict,
 	}
 }
 
+// reset empties the window.
+func (w *encoderWindowBuffer) reset() {
+	w.dict.reset()
+	w.pos = 0
+}
+
 // append copies bytes in data to the end of the window while discarding an
 // equal amount of bytes from the beginning of the window.
 func (w *encoderWindowBuffer) append(data []byte) {
-	// Remove discarded byte sequences from dictionary.
-	for i := 0; i < len(data); i++ {
-		pos := w.pos - int64(w.win.size) + int64(i)
-		w.dict.removeLesserThan(w.dictKey(i), pos)
-	}
-
-	// Copy data to buffer.
-	w.win.append(data)
-
-	// Add new byte sequences to dictionary.
-	w.pos += int64(len(data))
-	pos := w.pos - dictKeySize - int64(len(data)) + 1
-	bufIndex := w.win.size - dictKeySize - len(data) + 1
-	if bufIndex < 0 {
-		pos -= int64(bufIndex)
-		bufIndex = 0
-	}
-	for i := 0; i < len(data); i++ {
-		w.dict.add(w.dictKey(bufIndex+i), pos+int64(i))
+	for _, b := range data {
+		w.appendByte(b)
 	}
 }
 
 // appendByte is similar to append but for a single byte.
 func (w *encoderWindowBuffer) appendByte(b byte) {
-	w.append([]byte{b})
+	w.buf[w.pos&w.mask] = b
+	if w.pos > 0 {
+		// The byte completes the key of the sequence starting at the
+		// previous byte.
+		key := dictKey{w.buf[(w.pos-1)&w.mask], b}
+		w.dict.add(key, w.pos-1)
+	}
+	w.pos++
 }
 
 // findLongestPrefix returns a reference to the longest prefix of input found in
 // the current window. A zeroed reference is returned if no prefix is found.
+// Of equally long prefixes the most recent one is returned. The window must be
+// small enough for the distance to fit in a reference.
 func (w *encoderWindowBuffer) findLongestPrefix(input []byte) reference {
-	if len(input) < dictKeySize {
-		return reference{}
+	length, distance := w.findMatch(input)
+	return reference{
+		length:   uint16(length),
+		distance: uint16(distance),
 	}
-	start := 0
-	length := 0
-	key := dictKey{}
-	for i := 0; i < dictKeySize; i++ {
-		key[i] = input[i]
+}
+
+// findMatch returns the length and distance of the longest prefix of input
+// found in the current window. If no prefix is found, length and distance are
+// 0. Of equally long prefixes the most recent one is returned.
+func (w *encoderWindowBuffer) findMatch(input []byte) (length, distance int) {
+	if len(input) < dictKeySize {
+		return 0, 0
 	}
-	w.dict.removeLesserThan(key, w.pos-int64(w.win.size))
-	value := w.dict.get(key)
-	for value != nil {
-		i := int(value.value) - (int(w.pos) - w.win.size)
+	var start int64
+	minPos := w.pos - int64(w.size)
+	pos := w.dict.first(dictKey{input[0], input[1]})
+	for chain := 0; pos >= 0 && pos >= minPos && length < len(input); pos = w.dict.next(pos) {
+		if w.maxChain > 0 {
+			if chain == w.maxChain {
+				break
+			}
+			chain++
+		}
+		// The prefix can't extend past the end of the window.
+		maxLength := len(input)
+		if w.pos-pos < int64(maxLength) {
+			maxLength = int(w.pos - pos)
+		}
 		j := 0
-		for ; j < len(input) && i+j < w.win.size; j++ {
-			if w.get(i+j) != input[j] {
+		for ; j < maxLength; j++ {
+			if w.buf[(pos+int64(j))&w.mask] != input[j] {
 				break
 			}
 		}
 		if j > length {
-			start = i
+			start = pos
 			length = j
 		}
-		value = value.next
 	}
 	if length == 0 {
-		return reference{}
-	}
-	return reference{
-		length:   uint16(length),
-		distance: uint16(w.win.size - start),
+		return 0, 0
 	}
+	return length, int(w.pos - start)
 }
 
-// get returns the byte at logical index i in the window.
-func (w *encoderWindowBuffer) get(i int) byte {
-	return w.win.get(i)
-}
-
-// dictKey returns the dictionary key corresponding to logical position i in the
-// window.
-func (w *encoderWindowBuffer) dictKey(i int) dictKey {
-	key := dictKey{}
-	for j := 0; j < len(key); j++ {
-		key[j] = w.get(i + j)
+// extendMatch returns the length of the common prefix of input and the data
+// starting distance bytes before the end of the window. The data may run past
+// the end of the window into input, so a match can repeat itself. distance
+// must be in range [1, w.size].
+func (w *encoderWindowBuffer) extendMatch(input []byte, distance int) int {
+	for j, b := range input {
+		k := w.pos - int64(distance) + int64(j)
+		var c byte
+		if k < w.pos {
+			c = w.buf[k&w.mask]
+		} else {
+			c = input[k-w.pos]
+		}
+		if c != b {
+			return j
+		}
 	}
-	return key
+	return len(input)
 }
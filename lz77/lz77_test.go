@@ -2,7 +2,9 @@ package lz77
 
 import (
 	"bytes"
-	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
@@ -14,139 +16,115 @@ const (
 	testKalevala = "../test/files/kalevala.txt"
 )
 
-func (d *dictionary) forEach(t *testing.T, f func(*dictEntry)) {
-	t.Helper()
-	for _, bucket := range d.buckets {
-		for _, entry := range bucket {
-			f(entry)
-		}
+// chain returns the positions of key in d from the most recent to the oldest.
+func (d *dictionary) chain(key dictKey) []int64 {
+	var positions []int64
+	for pos := d.first(key); pos >= 0; pos = d.next(pos) {
+		positions = append(positions, pos)
 	}
+	return positions
 }
 
-func newDictOf(entries ...*dictEntry) *dictionary {
-	dict := newDictionary()
-	for _, e := range entries {
-		dict.setEntry(e)
-	}
-	return dict
-}
-
-func checkDictValue(t *testing.T, found, expected *dictValue) {
+func checkDeepEqual(t *testing.T, expected, found interface{}) {
 	t.Helper()
-	if expected == nil && found == nil {
-		return
-	} else if expected != nil && found != nil {
-		tu.Check(t, expected.value, found.value)
-		checkDictValue(t, found.next, expected.next)
-	} else {
+	if !reflect.DeepEqual(expected, found) {
 		t.Fatalf("expected %v, found %v", expected, found)
 	}
 }
 
-func checkDict(t *testing.T, found, expected *dictionary) {
-	t.Helper()
-	expected.forEach(t, func(eentry *dictEntry) {
-		t.Helper()
-		key := eentry.key
-		fentry := found.get(key)
-		if eentry == nil && fentry == nil {
-			return
-		} else if eentry != nil && fentry != nil {
-			checkDictValue(t, found.get(key), eentry.first)
-		} else {
-			t.Fatalf("expected %v, found %v", eentry, fentry)
-		}
-	})
+func TestDictionary(t *testing.T) {
+	dict := newDictionary(3)
+	tu.Check(t, 4, len(dict.prev))
+	dict.add(dictKey{1, 2}, 0)
+	dict.add(dictKey{2, 1}, 1)
+	dict.add(dictKey{1, 2}, 2)
+	checkDeepEqual(t, []int64{2, 0}, dict.chain(dictKey{1, 2}))
+	checkDeepEqual(t, []int64{1}, dict.chain(dictKey{2, 1}))
+	checkDeepEqual(t, []int64(nil), dict.chain(dictKey{1, 1}))
 }
 
 func TestEncoderWindowBuffer(t *testing.T) {
 	window := newEncoderWindowBuffer(4)
 	t.Run("Append", func(t *testing.T) {
 		window.append([]byte{4, 9, 1})
-		for i, b := range []byte{0, 4, 9, 1} {
-			if window.get(i) != b {
-				t.Fatalf("expected byte %d to be %d, found %d",
-					i, b, window.get(i))
-			}
-		}
-		tu.Check(t, int64(7), window.pos)
-		checkDict(t, window.dict, newDictOf(
-			&dictEntry{
-				key:   dictKey{0, 4},
-				first: &dictValue{value: 3},
-			},
-			&dictEntry{
-				key:   dictKey{4, 9},
-				first: &dictValue{value: 4},
-			},
-			&dictEntry{
-				key:   dictKey{9, 1},
-				first: &dictValue{value: 5},
-			},
-		))
+		tu.Check(t, int64(3), window.pos)
+		checkDeepEqual(t, []int64{0}, window.dict.chain(dictKey{4, 9}))
+		checkDeepEqual(t, []int64{1}, window.dict.chain(dictKey{9, 1}))
 
 		window.append([]byte{3, 2})
-		for i, b := range []byte{9, 1, 3, 2} {
-			if window.get(i) != b {
-				t.Fatalf("expected byte %d to be %d, found %d",
-					i, b, window.get(i))
-			}
-		}
-		tu.Check(t, int64(9), window.pos)
-		checkDict(t, window.dict, newDictOf(
-			&dictEntry{
-				key:   dictKey{9, 1},
-				first: &dictValue{value: 5},
-			},
-			&dictEntry{
-				key:   dictKey{1, 3},
-				first: &dictValue{value: 6},
-			},
-			&dictEntry{
-				key:   dictKey{3, 2},
-				first: &dictValue{value: 7},
-			},
-		))
+		tu.Check(t, int64(5), window.pos)
+		checkDeepEqual(t, []byte{2, 9, 1, 3}, window.buf)
+		checkDeepEqual(t, []int64{2}, window.dict.chain(dictKey{1, 3}))
+		checkDeepEqual(t, []int64{3}, window.dict.chain(dictKey{3, 2}))
 	})
 	t.Run("FindLongestPrefix", func(t *testing.T) {
 		expected := reference{length: 2, distance: 3}
 		ref := window.findLongestPrefix([]byte{1, 3})
-		if ref != expected {
-			for i := 0; i < window.win.size; i++ {
-				fmt.Print(window.get(i), " ")
-			}
-			fmt.Println()
-			t.Fatalf("expected %v, found %v", expected, ref)
-		}
+		tu.Check(t, expected, ref)
 		expected = reference{length: 0, distance: 0}
 		ref = window.findLongestPrefix([]byte{0, 1})
 		tu.Check(t, expected, ref)
 		expected = reference{length: 2, distance: 3}
 		ref = window.findLongestPrefix([]byte{1, 3, 6})
 		tu.Check(t, expected, ref)
-	})
-	t.Run("ExpandReference", func(t *testing.T) {
-		var buf bytes.Buffer
-		w := bufio.NewWriter(&buf)
-		window.win.expandReference(w, reference{
-			length:   3,
-			distance: 4,
-		})
-		w.Flush()
-		expected := []byte{9, 1, 3}
-		found := buf.Bytes()
-		if !bytes.Equal(expected, found) {
-			t.Fatalf("expected %v, found %v", expected, found)
-		}
-		for i, b := range []byte{2, 9, 1, 3} {
-			if window.get(i) != b {
-				t.Fatalf("expected byte %d to be %d, found %d",
-					i, b, window.get(i))
-			}
-		}
+		// The match can't extend past the end of the window.
+		expected = reference{length: 4, distance: 4}
+		ref = window.findLongestPrefix([]byte{9, 1, 3, 2, 9})
+		tu.Check(t, expected, ref)
+		// The sequence has slid out of the window.
+		expected = reference{length: 0, distance: 0}
+		ref = window.findLongestPrefix([]byte{4, 9})
+		tu.Check(t, expected, ref)
 	})
 }
 
+func TestFindLongestPrefixWindowStart(t *testing.T) {
+	// The window buffer is zeroed before the data stream starts, but those
+	// zeros must not be referred to.
+	w := newEncoderWindowBuffer(16)
+	tu.Check(t, reference{}, w.findLongestPrefix([]byte{0, 0, 0, 0}))
+	w.append([]byte{0, 0, 0})
+	ref := w.findLongestPrefix([]byte{0, 0, 0, 0})
+	if ref.length == 0 || ref.distance > 3 {
+		t.Fatalf("expected a reference to the 3 bytes of data, found %v", ref)
+	}
+}
+
+func TestHistoryBuffer(t *testing.T) {
+	var out bytes.Buffer
+	h := newHistoryBuffer(&out, 4, 10)
+	for _, b := range []byte{0, 1, 2, 3} {
+		tu.ExpectNil(t, h.writeByte(b))
+	}
+	// Overlapping match repeating the last two bytes.
+	tu.ExpectNil(t, h.copyMatch(2, 5))
+	// Doesn't fit in the buffer and causes the window to slide.
+	tu.ExpectNil(t, h.copyMatch(4, 3))
+	tu.ExpectNil(t, h.flush())
+	expected := []byte{0, 1, 2, 3, 2, 3, 2, 3, 2, 3, 2, 3}
+	if !bytes.Equal(expected, out.Bytes()) {
+		t.Fatalf("expected %v, found %v", expected, out.Bytes())
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher(8)
+	length, _ := m.FindMatch([]byte{0, 0, 0})
+	tu.Check(t, 0, length)
+	m.Append([]byte{1, 2, 3, 1, 2})
+	length, distance := m.FindMatch([]byte{1, 2, 3, 4})
+	tu.Check(t, 3, length)
+	tu.Check(t, 5, distance)
+	length, _ = m.FindMatch([]byte{0, 0, 1})
+	tu.Check(t, 0, length)
+	m.Append([]byte{9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9, 8})
+	length, _ = m.FindMatch([]byte{1, 2})
+	tu.Check(t, 0, length)
+	length, distance = m.FindMatch([]byte{9, 8, 7})
+	tu.Check(t, 2, length)
+	tu.Check(t, 2, distance)
+}
+
 func TestReference(t *testing.T) {
 	ref := reference{
 		length:   0b1000,
@@ -162,7 +140,7 @@ func TestReference(t *testing.T) {
 	w.WriteUint16(ref.asUint16())
 	w.Flush()
 	t.Run("Decode", func(t *testing.T) {
-		decoded, _ := decodeReference(bits.NewReader(&encoded))
+		decoded, _ := decodeReference(bufio.NewReader(&encoded))
 		tu.Check(t, ref, decoded)
 	})
 }
@@ -204,9 +182,192 @@ func TestEncodingAndDecoding(t *testing.T) {
 	}
 }
 
+func TestDecodeCorruptInput(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("abcabcabc")), &encoded))
+	valid := encoded.Bytes()
+	corrupt := func(i int) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= 0x01
+		return data
+	}
+	stream := func(s string) []byte {
+		return []byte("LZ77\x01" + s)
+	}
+	cases := []struct {
+		desc   string
+		data   []byte
+		offset int64
+	}{
+		{desc: "Empty", data: []byte{}, offset: 0},
+		{desc: "Magic", data: corrupt(0), offset: 0},
+		{desc: "Version", data: corrupt(4), offset: 4},
+		{desc: "ZeroLength", data: stream("\x80\x01\x00"), offset: 6},
+		{desc: "ZeroDistance", data: stream("\x40a\x00\x20"), offset: 7},
+		{desc: "DistanceBeforeStart", data: stream("\x40a\x02\x20"), offset: 7},
+		{desc: "TruncatedReference", data: stream("\x40a\x01"), offset: 7},
+		{desc: "TruncatedBlock", data: stream("\x00abc"), offset: 9},
+		{desc: "MissingEndMarker", data: stream("\x00abcdefgh"), offset: 14},
+		{desc: "TruncatedTrailer", data: valid[:len(valid)-1], offset: int64(len(valid)) - 12},
+		{desc: "Length", data: corrupt(len(valid) - 12), offset: int64(len(valid)) - 12},
+		{desc: "Checksum", data: corrupt(len(valid) - 1), offset: int64(len(valid)) - 4},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := Decode(bytes.NewReader(c.data), ioutil.Discard)
+			corrupt, ok := err.(*CorruptInputError)
+			if !ok {
+				t.Fatalf("expected *CorruptInputError, found %v", err)
+			}
+			tu.Check(t, c.offset, corrupt.Offset)
+		})
+	}
+}
+
+func TestEncodedFormat(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("abab")), &encoded))
+	expected := []byte{
+		'L', 'Z', '7', '7', 1,
+		// Two literals, a reference (2, 2) and the end marker.
+		0x30, 'a', 'b', 0x02, 0x20, 0x00, 0x00,
+		4, 0, 0, 0, 0, 0, 0, 0,
+		0xa6, 0x0a, 0xd7, 0x36,
+	}
+	if !bytes.Equal(expected, encoded.Bytes()) {
+		t.Fatalf("expected %x, found %x", expected, encoded.Bytes())
+	}
+}
+
+func TestDecodeFuzzedInput(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(tu.ReadFile(testKalevala)[:5000]), &encoded))
+	valid := encoded.Bytes()
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var data []byte
+		if i%4 == 0 {
+			data = make([]byte, rng.Intn(100))
+			rng.Read(data)
+		} else {
+			data = make([]byte, rng.Intn(len(valid)+1))
+			copy(data, valid)
+			for j := rng.Intn(4); j >= 0 && len(data) > 0; j-- {
+				data[rng.Intn(len(data))] ^= byte(1 + rng.Intn(255))
+			}
+		}
+		err := Decode(bytes.NewReader(data), ioutil.Discard)
+		if _, ok := err.(*CorruptInputError); err != nil && !ok {
+			t.Fatalf("unexpected error for input %x: %v", data, err)
+		}
+	}
+}
+
+// recordingTracer reconstructs the uncompressed data from the traced units.
+type recordingTracer struct {
+	t    *testing.T
+	data []byte
+	refs int
+}
+
+func (r *recordingTracer) Literal(offset int64, b byte) {
+	tu.Check(r.t, int64(len(r.data)), offset)
+	r.data = append(r.data, b)
+}
+
+func (r *recordingTracer) Reference(offset int64, length, distance int) {
+	tu.Check(r.t, int64(len(r.data)), offset)
+	if distance < 1 || distance > len(r.data) {
+		r.t.Fatalf("invalid distance %d at offset %d", distance, offset)
+	}
+	for i := 0; i < length; i++ {
+		r.data = append(r.data, r.data[len(r.data)-distance])
+	}
+	r.refs++
+}
+
+func TestEncodeTrace(t *testing.T) {
+	data := tu.ReadFile(testKalevala)[:10000]
+	tracer := &recordingTracer{t: t}
+	var traced, plain bytes.Buffer
+	tu.ExpectNil(t, EncodeTrace(bytes.NewReader(data), &traced, tracer))
+	tu.ExpectNil(t, Encode(bytes.NewReader(data), &plain))
+	if !bytes.Equal(data, tracer.data) {
+		t.Fatal("traced units differ from the input")
+	}
+	if tracer.refs == 0 {
+		t.Fatal("no references traced")
+	}
+	if !bytes.Equal(plain.Bytes(), traced.Bytes()) {
+		t.Fatal("tracing changed the encoded data")
+	}
+}
+
+func TestEncodeAllocations(t *testing.T) {
+	// Encoding allocates only when setting up, so the number of allocations
+	// doesn't depend on the size of the input.
+	allocs := func(input []byte) float64 {
+		r := bytes.NewReader(input)
+		return testing.AllocsPerRun(5, func() {
+			r.Reset(input)
+			Encode(r, ioutil.Discard)
+		})
+	}
+	kalevala := tu.ReadFile(testKalevala)
+	tu.Check(t, allocs(kalevala[:100]), allocs(kalevala))
+}
+
+func TestEncoderWindowReset(t *testing.T) {
+	// Encoding the same data twice with one Encoder must not produce
+	// references to the data of the first call.
+	input := tu.ReadFile(testKalevala)[:5000]
+	var expected, encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(input), &expected))
+	e := NewEncoder()
+	for i := 0; i < 2; i++ {
+		encoded.Reset()
+		tu.ExpectNil(t, e.Encode(bytes.NewReader(input), &encoded))
+		if !bytes.Equal(expected.Bytes(), encoded.Bytes()) {
+			t.Fatalf("call %d: reused encoder produced different output", i)
+		}
+		tu.Check(t, int64(len(input)), e.window.pos)
+	}
+	var decoded bytes.Buffer
+	tu.ExpectNil(t, Decode(&encoded, &decoded))
+	if !bytes.Equal(input, decoded.Bytes()) {
+		t.Fatal("decoded data differs from the input")
+	}
+	// The window is allocated only on the first call.
+	window := e.window
+	tu.ExpectNil(t, e.Encode(bytes.NewReader([]byte("abc")), ioutil.Discard))
+	if e.window != window {
+		t.Fatal("window was reallocated")
+	}
+	r := bytes.NewReader(input)
+	allocs := testing.AllocsPerRun(5, func() {
+		r.Reset(input)
+		tu.ExpectNil(t, e.Encode(r, ioutil.Discard))
+	})
+	tu.Check(t, 0.0, allocs)
+}
+
+func TestDecoderAllocations(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded))
+	src := bytes.NewReader(encoded.Bytes())
+	d := NewDecoder()
+	allocs := testing.AllocsPerRun(5, func() {
+		src.Reset(encoded.Bytes())
+		tu.ExpectNil(t, d.Decode(src, ioutil.Discard))
+	})
+	tu.Check(t, 0.0, allocs)
+}
+
 func BenchmarkEncode(b *testing.B) {
 	input := tu.ReadFile(testKalevala)
 	r := bytes.NewReader(input)
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		r.Reset(input)
@@ -214,3 +375,15 @@ func BenchmarkEncode(b *testing.B) {
 		Encode(r, &buf)
 	}
 }
+
+func BenchmarkDecode(b *testing.B) {
+	var encoded bytes.Buffer
+	Encode(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded)
+	input := encoded.Bytes()
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		Decode(r, ioutil.Discard)
+	}
+}
@@ -0,0 +1,36 @@
+package lz77
+
+// Matcher finds earlier occurrences of upcoming data in a sliding window of
+// recently processed data. It exposes the prefix matching used by Encode so that
+// other packages can implement their own LZ77 based formats on top of it.
+//
+// Matches found by a Matcher never refer to data before the first byte added
+// to it and never extend past the end of the window.
+type Matcher struct {
+	win *encoderWindowBuffer
+}
+
+// NewMatcher returns a Matcher with a window of windowSize bytes.
+func NewMatcher(windowSize int) *Matcher {
+	return &Matcher{win: newEncoderWindowBuffer(windowSize)}
+}
+
+// FindMatch returns the length and distance of the longest prefix of lookahead
+// found in the window. The distance is measured backwards from the end of the
+// window, so a distance of 1 refers to the most recently added byte. If no
+// prefix is found, length is 0. Prefixes shorter than two bytes are never
+// found.
+func (m *Matcher) FindMatch(lookahead []byte) (length, distance int) {
+	return m.win.findMatch(lookahead)
+}
+
+// Append adds data to the end of the window. Bytes that no longer fit in the
+// window are discarded from the beginning of the window.
+func (m *Matcher) Append(data []byte) {
+	m.win.append(data)
+}
+
+// AppendByte is similar to Append but for a single byte.
+func (m *Matcher) AppendByte(b byte) {
+	m.win.appendByte(b)
+}
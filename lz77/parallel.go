@@ -0,0 +1,340 @@
+package lz77
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+)
+
+// The block format produced by EncodeParallel is marked with its own format
+// version. The stream header is followed by a flags byte and the maximum
+// uncompressed size of a block as a little-endian uint32 value. Each block
+// starts with its compressed and uncompressed sizes as little-endian uint32
+// values followed by the units of the block terminated by an end marker. The
+// blocks are followed by a zero compressed size and the trailer.
+const (
+	blockFormatVersion    = 2
+	blockStreamHeaderSize = 1 + 4
+	blockHeaderSize       = 4 + 4
+	// flagPrimed is set if blocks may refer to the end of the previous block.
+	flagPrimed = 1 << 0
+	// maxBlockSize is the largest supported uncompressed block size.
+	maxBlockSize = 1 << 30
+)
+
+// DefaultBlockSize is the block size used by EncodeParallel if
+// ParallelOptions.BlockSize is 0.
+const DefaultBlockSize = 1 << 20
+
+// ErrOptions is returned if invalid options are passed to a function.
+var ErrOptions = errors.New("lz77: invalid options")
+
+// ParallelOptions specifies how EncodeParallel splits the work.
+type ParallelOptions struct {
+	// Workers is the number of goroutines compressing blocks. It must be at
+	// least 1.
+	Workers int
+	// BlockSize is the uncompressed size of a block. It must be in range
+	// [1, 2^30]. If it is 0, DefaultBlockSize is used.
+	BlockSize int
+	// Prime makes the end of each block available to references from the
+	// next block. It improves compression but prevents decoding the blocks
+	// in parallel.
+	Prime bool
+}
+
+func (o ParallelOptions) valid() bool {
+	return o.Workers >= 1 && o.BlockSize >= 0 && o.BlockSize <= maxBlockSize
+}
+
+// EncodeParallel is like Encode but splits the input into blocks that are
+// compressed concurrently. The blocks are written in order and can be decoded
+// in parallel using DecodeParallel unless opts.Prime is set.
+func EncodeParallel(input io.Reader, output io.Writer, opts ParallelOptions) error {
+	if !opts.valid() {
+		return ErrOptions
+	}
+	if opts.BlockSize == 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	summer := &checksum.Reader{R: input, Hash: &checksum.CRC32{}}
+	src := bufio.NewReader(summer)
+	dst := bufio.NewWriter(output)
+	var flags byte
+	if opts.Prime {
+		flags |= flagPrimed
+	}
+	header := [headerSize + blockStreamHeaderSize]byte{
+		magic[0], magic[1], magic[2], magic[3], blockFormatVersion, flags,
+	}
+	binary.PutUint32LE(header[headerSize+1:], uint32(opts.BlockSize))
+	if _, err := dst.Write(header[:]); err != nil {
+		return err
+	}
+
+	var prev []byte
+	next := func() (*blockJob, error) {
+		data := make([]byte, opts.BlockSize)
+		n, err := src.Read(data)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		job := &blockJob{data: data[:n], done: make(chan struct{})}
+		if opts.Prime {
+			job.prime = windowTail(prev)
+		}
+		prev = job.data
+		return job, nil
+	}
+	newProcessor := func() func(*blockJob) {
+		window := newEncoderWindowBuffer(windowBufferSize)
+		data := bufio.NewBytesReader(nil)
+		src := bufio.NewReaderSize(data, lookaheadBufferSize)
+		dst := bufio.NewWriter(nil)
+		return func(job *blockJob) {
+			window.reset()
+			window.append(job.prime)
+			data.Reset(job.data)
+			src.Reset(data)
+			dst.Reset(&job.output)
+			job.err = encodeUnits(src, dst, window, nil)
+			if job.err == nil {
+				job.err = dst.Flush()
+			}
+		}
+	}
+	finish := func(job *blockJob) error {
+		var sizes [blockHeaderSize]byte
+		binary.PutUint32LE(sizes[:4], uint32(job.output.Len()))
+		binary.PutUint32LE(sizes[4:], uint32(len(job.data)))
+		if _, err := dst.Write(sizes[:]); err != nil {
+			return err
+		}
+		_, err := dst.Write(job.output.Bytes())
+		return err
+	}
+	if err := runPipeline(opts.Workers, next, newProcessor, finish); err != nil {
+		return err
+	}
+
+	var end [4]byte
+	if _, err := dst.Write(end[:]); err != nil {
+		return err
+	}
+	if err := writeTrailer(dst, summer); err != nil {
+		return err
+	}
+	return dst.Flush()
+}
+
+// decodeBlocks decodes the blocks of a stream produced by EncodeParallel from
+// src using workers goroutines and writes the decoded data to out. offset is
+// the position of src in the input and the position after the blocks is
+// returned.
+func decodeBlocks(src *bufio.Reader, out io.Writer, offset int64, workers int) (int64, error) {
+	var header [blockStreamHeaderSize]byte
+	if _, err := src.Read(header[:]); err != nil {
+		return offset, truncated(err, offset)
+	}
+	flags := header[0]
+	if flags&^flagPrimed != 0 {
+		return offset, &CorruptInputError{offset, "unsupported flags"}
+	}
+	blockSize := int64(binary.GetUint32LE(header[1:]))
+	if blockSize == 0 || blockSize > maxBlockSize {
+		return offset, &CorruptInputError{offset + 1, "invalid block size"}
+	}
+	offset += blockStreamHeaderSize
+
+	var prev *blockJob
+	next := func() (*blockJob, error) {
+		var sizes [blockHeaderSize]byte
+		if _, err := src.Read(sizes[:4]); err != nil {
+			return nil, truncated(err, offset)
+		}
+		compressedSize := int64(binary.GetUint32LE(sizes[:4]))
+		if compressedSize == 0 {
+			offset += 4
+			return nil, nil
+		}
+		if _, err := src.Read(sizes[4:]); err != nil {
+			return nil, truncated(err, offset+4)
+		}
+		size := int64(binary.GetUint32LE(sizes[4:]))
+		if size == 0 || size > blockSize {
+			return nil, &CorruptInputError{offset + 4, "invalid block size"}
+		}
+		if compressedSize > maxEncodedBlockSize(size) {
+			return nil, &CorruptInputError{offset, "invalid compressed block size"}
+		}
+		// The block is read in chunks instead of allocating compressedSize
+		// bytes up front, so a corrupt size can't cause a huge allocation.
+		var data bufio.Buffer
+		n, err := data.ReadFrom(io.LimitReader(src, compressedSize))
+		if err != nil {
+			return nil, err
+		}
+		if n < compressedSize {
+			return nil, truncated(io.EOF, offset+blockHeaderSize)
+		}
+		job := &blockJob{
+			data:   data.Bytes(),
+			size:   int(size),
+			offset: offset + blockHeaderSize,
+			done:   make(chan struct{}),
+		}
+		if flags&flagPrimed != 0 {
+			job.prev = prev
+			prev = job
+		}
+		offset += blockHeaderSize + compressedSize
+		return job, nil
+	}
+	newProcessor := func() func(*blockJob) {
+		history := newHistoryBuffer(nil, windowBufferSize, historyBufferSize)
+		data := bufio.NewBytesReader(nil)
+		src := bufio.NewReader(data)
+		return func(job *blockJob) {
+			var prime []byte
+			if job.prev != nil {
+				<-job.prev.done
+				if job.prev.err != nil {
+					job.err = job.prev.err
+					return
+				}
+				prime = windowTail(job.prev.output.Bytes())
+				// Dropping the reference lets finished blocks be
+				// garbage collected.
+				job.prev = nil
+			}
+			history.reset(&job.output, prime)
+			data.Reset(job.data)
+			src.Reset(data)
+			end, err := decodeUnits(src, history, job.offset)
+			if err == nil {
+				err = history.flush()
+			}
+			if err == nil && end != job.offset+int64(len(job.data)) {
+				err = &CorruptInputError{end, "data after end marker"}
+			}
+			if err == nil && history.total-int64(len(prime)) != int64(job.size) {
+				err = &CorruptInputError{job.offset - 4, "block size mismatch"}
+			}
+			job.err = err
+		}
+	}
+	finish := func(job *blockJob) error {
+		_, err := out.Write(job.output.Bytes())
+		return err
+	}
+	err := runPipeline(workers, next, newProcessor, finish)
+	return offset, err
+}
+
+// maxEncodedBlockSize returns the largest possible compressed size of a block
+// of size bytes. Each byte and the end marker take at most two bytes and each
+// group of eight units has a header byte.
+func maxEncodedBlockSize(size int64) int64 {
+	return 2*(size+1) + (size+8)/8
+}
+
+// windowTail returns the last window of data.
+func windowTail(data []byte) []byte {
+	if len(data) > windowBufferSize {
+		return data[len(data)-windowBufferSize:]
+	}
+	return data
+}
+
+// blockJob is a block of a stream processed by runPipeline.
+type blockJob struct {
+	// data is the uncompressed data of the block when encoding and the
+	// compressed data when decoding.
+	data []byte
+	// prime is the data preceding the block that the block may refer to when
+	// encoding.
+	prime []byte
+	// prev is the preceding block whose end the block may refer to when
+	// decoding.
+	prev *blockJob
+	// size is the uncompressed size of the block when decoding.
+	size int
+	// offset is the position of data in the input when decoding.
+	offset int64
+	// output holds the result of processing the block.
+	output bufio.Buffer
+	err    error
+	// done is closed after the block has been processed.
+	done chan struct{}
+}
+
+// runPipeline processes blocks concurrently using the specified number of
+// worker goroutines. next is called repeatedly to produce the blocks until it
+// returns a nil block or an error. Each worker calls newProcessor once and
+// processes blocks using the returned function. finish is called for each
+// processed block in the order the blocks were produced. Processing stops at
+// the first error, which is returned.
+func runPipeline(
+	workers int,
+	next func() (*blockJob, error),
+	newProcessor func() func(*blockJob),
+	finish func(*blockJob) error,
+) error {
+	jobs := make(chan *blockJob)
+	// pending holds the blocks in order until they are finished. Its
+	// capacity limits the number of blocks in memory at once.
+	pending := make(chan *blockJob, workers)
+	stop := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			process := newProcessor()
+			for job := range jobs {
+				process(job)
+				close(job.done)
+			}
+		}()
+	}
+	nextErr := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			job, err := next()
+			if err != nil || job == nil {
+				nextErr <- err
+				return
+			}
+			select {
+			case pending <- job:
+			case <-stop:
+				nextErr <- nil
+				return
+			}
+			jobs <- job
+		}
+	}()
+	var err error
+	for job := range pending {
+		<-job.done
+		if err != nil {
+			continue
+		}
+		err = job.err
+		if err == nil {
+			err = finish(job)
+		}
+		if err != nil {
+			close(stop)
+		}
+	}
+	if e := <-nextErr; err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package lz77
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+func TestParallelEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	kalevala := tu.ReadFile(testKalevala)
+	cases := []struct {
+		desc string
+		data []byte
+		opts ParallelOptions
+	}{
+		{desc: "Empty", data: []byte{}, opts: ParallelOptions{Workers: 2}},
+		{desc: "ExactBlocks", data: []byte("abcabcabcabc"), opts: ParallelOptions{Workers: 2, BlockSize: 3}},
+		{desc: "SingleWorker", data: kalevala, opts: ParallelOptions{Workers: 1, BlockSize: 10000}},
+		{desc: "Kalevala", data: kalevala, opts: ParallelOptions{Workers: 4, BlockSize: 10000}},
+		{desc: "KalevalaPrimed", data: kalevala, opts: ParallelOptions{Workers: 4, BlockSize: 10000, Prime: true}},
+		{desc: "DefaultBlockSize", data: kalevala, opts: ParallelOptions{Workers: 4}},
+		{desc: "Random", data: random, opts: ParallelOptions{Workers: 3, BlockSize: 777, Prime: true}},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded bytes.Buffer
+			tu.ExpectNil(t, EncodeParallel(bytes.NewReader(c.data), &encoded, c.opts))
+			for _, workers := range []int{1, 4} {
+				var decoded bytes.Buffer
+				tu.ExpectNil(t, DecodeParallel(bytes.NewReader(encoded.Bytes()), &decoded, workers))
+				if !bytes.Equal(c.data, decoded.Bytes()) {
+					t.Fatalf("decoded data differs from the original with %d workers", workers)
+				}
+			}
+		})
+	}
+}
+
+func TestParallelPrimingImprovesCompression(t *testing.T) {
+	data := tu.ReadFile(testKalevala)
+	var plain, primed bytes.Buffer
+	opts := ParallelOptions{Workers: 4, BlockSize: 1000}
+	tu.ExpectNil(t, EncodeParallel(bytes.NewReader(data), &plain, opts))
+	opts.Prime = true
+	tu.ExpectNil(t, EncodeParallel(bytes.NewReader(data), &primed, opts))
+	if primed.Len() >= plain.Len() {
+		t.Fatalf("primed size %d is not smaller than unprimed size %d", primed.Len(), plain.Len())
+	}
+}
+
+func TestEncodedParallelFormat(t *testing.T) {
+	// The second block "ab" refers to the first block only when primed.
+	cases := []struct {
+		desc     string
+		prime    bool
+		expected string
+	}{
+		{
+			desc:     "Independent",
+			expected: "4c5a3737020002000000" + "05000000020000002061620000" + "05000000020000002061620000" + "00000000",
+		},
+		{
+			desc:     "Primed",
+			prime:    true,
+			expected: "4c5a3737020102000000" + "05000000020000002061620000" + "0500000002000000c002200000" + "00000000",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded bytes.Buffer
+			opts := ParallelOptions{Workers: 2, BlockSize: 2, Prime: c.prime}
+			tu.ExpectNil(t, EncodeParallel(bytes.NewReader([]byte("abab")), &encoded, opts))
+			// The trailer is the same as in the sequential format.
+			found := hex.EncodeToString(encoded.Bytes()[:encoded.Len()-trailerSize])
+			tu.Check(t, c.expected, found)
+		})
+	}
+}
+
+func TestDecodeParallelCorruptInput(t *testing.T) {
+	stream := func(flags string, s string) []byte {
+		return []byte("LZ77\x02" + flags + "\x04\x00\x00\x00" + s)
+	}
+	block := "\x06\x00\x00\x00\x03\x00\x00\x00\x10abc\x00\x00"
+	cases := []struct {
+		desc   string
+		data   []byte
+		offset int64
+	}{
+		{desc: "Truncated", data: []byte("LZ77\x02\x00"), offset: 5},
+		{desc: "Flags", data: stream("\x02", ""), offset: 5},
+		{desc: "BlockSize", data: []byte("LZ77\x02\x00\x00\x00\x00\x00"), offset: 6},
+		{desc: "ZeroSizeBlock", data: stream("\x00", "\x06\x00\x00\x00\x00\x00\x00\x00"), offset: 14},
+		{desc: "OversizedBlock", data: stream("\x00", "\x06\x00\x00\x00\x05\x00\x00\x00"), offset: 14},
+		{desc: "CompressedSize", data: stream("\x00", "\x64\x00\x00\x00\x03\x00\x00\x00"), offset: 10},
+		{desc: "TruncatedBlock", data: stream("\x00", block[:11]), offset: 18},
+		{desc: "SizeMismatch", data: stream("\x00", "\x06\x00\x00\x00\x02\x00\x00\x00\x10abc\x00\x00\x00\x00\x00\x00"), offset: 14},
+		{desc: "DataAfterEndMarker", data: stream("\x00", "\x07\x00\x00\x00\x03\x00\x00\x00\x10abc\x00\x00x\x00\x00\x00\x00"), offset: 24},
+		{desc: "MissingTerminator", data: stream("\x00", block), offset: 24},
+		// Without priming, the second block can't refer to the first block.
+		{desc: "Unprimed", data: stream("\x00", block+"\x03\x00\x00\x00\x03\x00\x00\x00\x80\x03\x30"), offset: 33},
+		{desc: "TruncatedTrailer", data: stream("\x00", block+"\x00\x00\x00\x00"), offset: 28},
+	}
+	for _, c := range cases {
+		for _, workers := range []int{1, 4} {
+			t.Run(c.desc, func(t *testing.T) {
+				err := DecodeParallel(bytes.NewReader(c.data), ioutil.Discard, workers)
+				corrupt, ok := err.(*CorruptInputError)
+				if !ok {
+					t.Fatalf("expected *CorruptInputError, found %v", err)
+				}
+				tu.Check(t, c.offset, corrupt.Offset)
+			})
+		}
+	}
+}
+
+func TestDecodeParallelHugeBlockHeader(t *testing.T) {
+	// The headers claim blocks of 1 GiB taking over 2 GiB compressed, but
+	// the input ends right after them.
+	data := []byte("LZ77\x02\x00\x00\x00\x00\x40" + "\x00\x00\x00\x88\x00\x00\x00\x40")
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := DecodeParallel(bytes.NewReader(data), ioutil.Discard, 4)
+	runtime.ReadMemStats(&after)
+	corrupt, ok := err.(*CorruptInputError)
+	if !ok {
+		t.Fatalf("expected *CorruptInputError, found %v", err)
+	}
+	tu.Check(t, int64(18), corrupt.Offset)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<24 {
+		t.Fatalf("allocated %d bytes for a truncated block", allocated)
+	}
+}
+
+func TestParallelOptions(t *testing.T) {
+	cases := []ParallelOptions{
+		{Workers: 0},
+		{Workers: 1, BlockSize: -1},
+		{Workers: 1, BlockSize: maxBlockSize + 1},
+	}
+	for _, opts := range cases {
+		tu.Check(t, ErrOptions, EncodeParallel(bytes.NewReader(nil), ioutil.Discard, opts))
+	}
+	tu.Check(t, ErrOptions, DecodeParallel(bytes.NewReader(nil), ioutil.Discard, 0))
+}
+
+func BenchmarkEncodeParallel(b *testing.B) {
+	input := tu.ReadFile(testKalevala)
+	r := bytes.NewReader(input)
+	opts := ParallelOptions{Workers: 4, BlockSize: 1 << 16}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		EncodeParallel(r, ioutil.Discard, opts)
+	}
+}
@@ -0,0 +1,12 @@
+package lz77
+
+// Tracer receives the decisions made by the encoder. The methods are called in
+// the order of the units in the encoded data.
+type Tracer interface {
+	// Literal is called when the byte b at offset in the uncompressed data is
+	// encoded as a literal.
+	Literal(offset int64, b byte)
+	// Reference is called when length bytes at offset in the uncompressed
+	// data are encoded as a reference to the data distance bytes earlier.
+	Reference(offset int64, length, distance int)
+}
@@ -0,0 +1,48 @@
+package lz78
+
+// phraseTable is the dictionary of the decoder.
+type phraseTable struct {
+	// prefix holds the index of the phrase each phrase extends.
+	prefix []uint16
+	// suffix holds the last byte of each phrase.
+	suffix []byte
+	// stack holds the most recently expanded phrase.
+	stack []byte
+	// len is the number of phrases in the table.
+	len uint32
+}
+
+// newPhraseTable returns a table containing the empty phrase that can hold at
+// most size phrases.
+func newPhraseTable(size int) *phraseTable {
+	return &phraseTable{
+		prefix: make([]uint16, size),
+		suffix: make([]byte, size),
+		stack:  make([]byte, size),
+		len:    1,
+	}
+}
+
+// add adds the phrase formed by appending b to phrase prefix to the table.
+func (t *phraseTable) add(prefix uint32, b byte) {
+	t.prefix[t.len] = uint16(prefix)
+	t.suffix[t.len] = b
+	t.len++
+}
+
+// reset removes all phrases except the empty phrase from the table.
+func (t *phraseTable) reset() {
+	t.len = 1
+}
+
+// expand returns the bytes of phrase index. The returned slice is valid until
+// the next call to expand.
+func (t *phraseTable) expand(index uint32) []byte {
+	i := len(t.stack)
+	for index != 0 {
+		i--
+		t.stack[i] = t.suffix[index]
+		index = uint32(t.prefix[index])
+	}
+	return t.stack[i:]
+}
@@ -0,0 +1,149 @@
+/*
+Package lz78 implements LZ78 encoding and decoding.
+
+The encoder maintains a dictionary of phrases. Initially the dictionary contains
+only the empty phrase with index 0. The encoder repeatedly finds the longest
+phrase in the dictionary matching the input and outputs a token consisting of
+the index of the phrase and the byte following it. The phrase extended by the
+byte is then added to the dictionary.
+
+Tokens are written as a bit stream. The index of a token is written using the
+smallest number of bits that can represent the indices of all phrases in the
+dictionary and the end code, which is equal to the number of phrases. The byte
+of a token is written using 8 bits. The dictionary holds at most maxPhrases
+phrases. When it becomes full, it is reset to contain only the empty phrase.
+
+The stream ends with the end code followed by the index of the final phrase,
+which isn't followed by a byte. The final phrase is the empty phrase if the
+input ends right after a token.
+*/
+package lz78
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/trie"
+)
+
+// ErrCorrupt is returned by Decode if the input is not valid LZ78 data.
+var ErrCorrupt = errors.New("lz78: corrupt input")
+
+// maxPhrases is the largest number of phrases in the dictionary, including the
+// empty phrase.
+const maxPhrases = 1 << 16
+
+// Encode reads data from input, encodes it using LZ78 and writes the result to
+// output.
+func Encode(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	dst := bits.NewWriter(output)
+	dict := trie.New(maxPhrases)
+	phrases := uint32(1)
+	phrase := uint32(0)
+	for {
+		b, err := src.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if child, ok := dict.Find(phrase, b); ok {
+			phrase = child
+			continue
+		}
+		if err := writeIndex(dst, phrase, phrases); err != nil {
+			return err
+		}
+		if err := dst.WriteByte(b); err != nil {
+			return err
+		}
+		dict.Add(phrase, b, phrases)
+		phrases++
+		if phrases == maxPhrases {
+			dict.Reset(1)
+			phrases = 1
+		}
+		phrase = 0
+	}
+	if err := writeIndex(dst, phrases, phrases); err != nil {
+		return err
+	}
+	if err := writeIndex(dst, phrase, phrases); err != nil {
+		return err
+	}
+	return dst.Flush()
+}
+
+// indexWidth returns the number of bits used for indices when the dictionary
+// contains phrases phrases.
+func indexWidth(phrases uint32) int {
+	return bits.Len64(uint64(phrases))
+}
+
+// writeIndex writes index to w using the width specified by the number of
+// phrases in the dictionary.
+func writeIndex(w *bits.Writer, index, phrases uint32) error {
+	return w.WriteBits(uint64(index), uint(indexWidth(phrases)))
+}
+
+// readIndex reads an index from r using the width specified by the number of
+// phrases in the dictionary.
+func readIndex(r *bits.Reader, phrases uint32) (uint32, error) {
+	index, err := r.ReadBits(uint(indexWidth(phrases)))
+	return uint32(index), err
+}
+
+// Decode reads LZ78 encoded data from input, decodes it and writes the decoded
+// data to output.
+func Decode(input io.Reader, output io.Writer) error {
+	src := bits.NewReader(input)
+	dst := bufio.NewWriter(output)
+	dict := newPhraseTable(maxPhrases)
+	for {
+		index, err := readIndex(src, dict.len)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if index == dict.len {
+			final, err := readIndex(src, dict.len)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if final >= dict.len {
+				return ErrCorrupt
+			}
+			if _, err := dst.Write(dict.expand(final)); err != nil {
+				return err
+			}
+			return dst.Flush()
+		}
+		if index > dict.len {
+			return ErrCorrupt
+		}
+		b, err := src.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if _, err := dst.Write(dict.expand(index)); err != nil {
+			return err
+		}
+		if err := dst.WriteByte(b); err != nil {
+			return err
+		}
+		dict.add(index, b)
+		if dict.len == maxPhrases {
+			dict.reset()
+		}
+	}
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package lz78
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+)
+
+func TestEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 500000)
+	rand.New(rand.NewSource(1)).Read(random)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		{desc: "Short", data: []byte("abcabcabcabcabcabc")},
+		{desc: "Run", data: bytes.Repeat([]byte{'x'}, 100000)},
+		// Random data fills the dictionary several times.
+		{desc: "Random", data: random},
+		{desc: "Kalevala", data: tu.ReadFile(testKalevala)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			tu.ExpectNil(t, Encode(bytes.NewReader(c.data), &encoded))
+			tu.ExpectNil(t, Decode(&encoded, &decoded))
+			if !bytes.Equal(c.data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	// The tokens are (0, 'a'), (0, 'b') and (1, 'b') followed by the end
+	// code 4 and the empty final phrase. The indices are 1, 2, 2, 3 and 3 bits
+	// wide.
+	expected := []byte{0x30, 0x8c, 0x4b, 0x14, 0x00}
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("abab")), &encoded))
+	if !bytes.Equal(expected, encoded.Bytes()) {
+		t.Fatalf("expected %x, found %x", expected, encoded.Bytes())
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: io.ErrUnexpectedEOF},
+		{desc: "Truncated", data: []byte{0x30}, expected: io.ErrUnexpectedEOF},
+		// The second token refers to phrase 3 when only 2 phrases exist.
+		{desc: "Index", data: []byte{0x30, 0xe0}, expected: ErrCorrupt},
+		// The final phrase is the end code.
+		{desc: "FinalIndex", data: []byte{0xc0}, expected: ErrCorrupt},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, Decode(bytes.NewReader(c.data), ioutil.Discard))
+		})
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	input := tu.ReadFile(testKalevala)
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		Encode(r, ioutil.Discard)
+	}
+}
@@ -0,0 +1,73 @@
+package lzw
+
+import (
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+)
+
+// Order specifies the order in which the bits of codes are packed into bytes.
+type Order int
+
+const (
+	// LSB packs codes starting from the least significant bit of each byte.
+	// This order is used by the compress program and GIF.
+	LSB Order = iota
+	// MSB packs codes starting from the most significant bit of each byte.
+	// This order is used by TIFF and PDF.
+	MSB
+)
+
+// bitsOrder returns the util/bits equivalent of o.
+func (o Order) bitsOrder() bits.Order {
+	if o == MSB {
+		return bits.MSB
+	}
+	return bits.LSB
+}
+
+// codeWriter writes variable width codes into an io.Writer.
+type codeWriter struct {
+	w *bits.Writer
+	// n is the number of bits written to w.
+	n int64
+}
+
+// newCodeWriter returns a codeWriter that writes to w using order.
+func newCodeWriter(w io.Writer, order Order) *codeWriter {
+	return &codeWriter{w: bits.NewWriterOrder(w, order.bitsOrder())}
+}
+
+// writeCode writes the width least significant bits of code. width must be at
+// most 16.
+func (w *codeWriter) writeCode(code uint32, width uint) error {
+	w.n += int64(width)
+	return w.w.WriteBits(uint64(code), width)
+}
+
+// written returns the number of full bytes written.
+func (w *codeWriter) written() int64 {
+	return w.n / 8
+}
+
+// flush writes all pending bits padded with zero bits to full bytes to the
+// underlying writer.
+func (w *codeWriter) flush() error {
+	return w.w.Flush()
+}
+
+// codeReader reads variable width codes from an io.Reader.
+type codeReader struct {
+	r *bits.Reader
+}
+
+// readCode reads a code of width bits. width must be at most 16. io.EOF is
+// returned if the input ends before a full code is read. The remaining bits
+// are considered padding.
+func (r *codeReader) readCode(width uint) (uint32, error) {
+	code, err := r.r.ReadBits(width)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return uint32(code), err
+}
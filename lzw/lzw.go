@@ -0,0 +1,345 @@
+/*
+Package lzw implements Lempel-Ziv-Welch compression.
+
+The encoder keeps a dictionary of strings seen in the input. Initially the
+dictionary contains all single bytes as codes 0-255. The encoder repeatedly
+finds the longest string in the dictionary matching the input, outputs its code
+and adds the string extended by the next input byte to the dictionary.
+
+Codes are written using a variable width starting from 9 bits. The width grows
+by one bit whenever the next code to be added no longer fits, until the maximum
+width is reached. Code 256 is the CLEAR code, which resets the dictionary and
+the code width. When the dictionary is full, the encoder monitors the
+compression ratio and emits a CLEAR code once the ratio starts to decline.
+
+Encode and Decode handle raw code streams with a configurable bit order and
+maximum code width. EncodeZ and DecodeZ handle the .Z format of the Unix
+compress program, which adds a 3-byte header to a code stream in LSB order.
+Compatible with compress, whenever the code width changes in the .Z format, the
+stream is padded to the next boundary of a group of 8 codes.
+*/
+package lzw
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/trie"
+)
+
+// Errors returned by encoding and decoding functions.
+var (
+	ErrCorrupt = errors.New("lzw: corrupt input")
+	ErrHeader  = errors.New("lzw: invalid header")
+	ErrWidth   = errors.New("lzw: invalid maximum code width")
+)
+
+// These constants specify the range of valid code widths.
+const (
+	MinWidth = 9
+	MaxWidth = 16
+)
+
+// These constants are the reserved codes of the code stream.
+const (
+	clearCode = 256
+	// firstCode is the first code added to the dictionary.
+	firstCode = 257
+)
+
+// checkGap is the number of input bytes between checks of the compression
+// ratio when the dictionary is full.
+const checkGap = 10000
+
+// groupSize is the number of codes in a group in the .Z format.
+const groupSize = 8
+
+// Options specifies the format of a code stream.
+type Options struct {
+	// Order is the order of bits in the code stream.
+	Order Order
+	// MaxWidth is the largest width of a code in bits. It must be in range
+	// [MinWidth, MaxWidth].
+	MaxWidth int
+}
+
+// valid reports whether the options are valid.
+func (o Options) valid() bool {
+	return o.MaxWidth >= MinWidth && o.MaxWidth <= MaxWidth &&
+		(o.Order == LSB || o.Order == MSB)
+}
+
+// Encode compresses data from input and writes it to output as a raw code
+// stream formatted according to opts.
+func Encode(input io.Reader, output io.Writer, opts Options) error {
+	if !opts.valid() {
+		return ErrWidth
+	}
+	return newEncoder(output, opts, false).encode(input)
+}
+
+// Decode decodes a raw code stream formatted according to opts from input and
+// writes the uncompressed data to output.
+func Decode(input io.Reader, output io.Writer, opts Options) error {
+	if !opts.valid() {
+		return ErrWidth
+	}
+	return newDecoder(input, opts, false, true).decode(output)
+}
+
+// maxCode returns the largest code that can be written using width bits
+// before the width is increased. If grouped is true, the quirk of the compress
+// program is reproduced: the initial width is never considered the last width,
+// so a maximum width of 9 bits results in 10-bit codes once the dictionary is
+// full.
+func maxCode(width, maxWidth uint, grouped bool) uint32 {
+	if width == maxWidth && !(grouped && width == MinWidth) {
+		// The last code width is never increased.
+		return 1 << maxWidth
+	}
+	return 1<<width - 1
+}
+
+// encoder holds the state of an ongoing compression.
+type encoder struct {
+	w        *codeWriter
+	maxWidth uint
+	// grouped tells whether width changes are aligned to groups of codes.
+	grouped bool
+	width   uint
+	// next is the next code added to the dictionary.
+	next uint32
+	// groupCodes is the number of codes written in the current group.
+	groupCodes int
+	dict       *trie.Trie
+}
+
+// newEncoder returns an encoder writing a code stream to output.
+func newEncoder(output io.Writer, opts Options, grouped bool) *encoder {
+	return &encoder{
+		w:        newCodeWriter(output, opts.Order),
+		maxWidth: uint(opts.MaxWidth),
+		grouped:  grouped,
+		width:    MinWidth,
+		next:     firstCode,
+		dict:     trie.New(1 << uint(opts.MaxWidth)),
+	}
+}
+
+// encode compresses data from input.
+func (e *encoder) encode(input io.Reader) error {
+	src := bufio.NewReader(input)
+	b, err := src.ReadByte()
+	if err == io.EOF {
+		return e.w.flush()
+	} else if err != nil {
+		return err
+	}
+	code := uint32(b)
+	inCount := int64(1)
+	checkpoint := int64(checkGap)
+	var ratio int64
+	for {
+		b, err := src.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		inCount++
+		if child, ok := e.dict.Find(code, b); ok {
+			code = child
+			continue
+		}
+		if err := e.writeCode(code, false); err != nil {
+			return err
+		}
+		if e.next < 1<<e.maxWidth {
+			e.dict.Add(code, b, e.next)
+			e.next++
+		} else if inCount >= checkpoint {
+			checkpoint = inCount + checkGap
+			r := inCount << 8 / (e.w.written() + 1)
+			if r > ratio {
+				ratio = r
+			} else {
+				ratio = 0
+				e.dict.Reset(firstCode)
+				e.next = firstCode
+				if err := e.writeCode(clearCode, true); err != nil {
+					return err
+				}
+			}
+		}
+		code = uint32(b)
+	}
+	if err := e.writeCode(code, false); err != nil {
+		return err
+	}
+	return e.w.flush()
+}
+
+// writeCode writes code and updates the code width. If clear is true, the
+// width is reset to MinWidth.
+func (e *encoder) writeCode(code uint32, clear bool) error {
+	if err := e.w.writeCode(code, e.width); err != nil {
+		return err
+	}
+	e.groupCodes = (e.groupCodes + 1) % groupSize
+	if !clear && e.next <= maxCode(e.width, e.maxWidth, e.grouped) {
+		return nil
+	}
+	if e.grouped {
+		for ; e.groupCodes > 0 && e.groupCodes < groupSize; e.groupCodes++ {
+			if err := e.w.writeCode(0, e.width); err != nil {
+				return err
+			}
+		}
+		e.groupCodes = 0
+	}
+	if clear {
+		e.width = MinWidth
+	} else {
+		e.width++
+	}
+	return nil
+}
+
+// decoder holds the state of an ongoing decompression.
+type decoder struct {
+	r        codeReader
+	maxWidth uint
+	grouped  bool
+	// blockMode tells whether code 256 is the CLEAR code.
+	blockMode bool
+	width     uint
+	// next is the next code added to the dictionary.
+	next       uint32
+	groupCodes int
+	prefix     []uint16
+	suffix     []byte
+	// stack holds the string of the current code.
+	stack []byte
+}
+
+// newDecoder returns a decoder reading a code stream from input.
+func newDecoder(input io.Reader, opts Options, grouped, blockMode bool) *decoder {
+	size := 1 << uint(opts.MaxWidth)
+	d := &decoder{
+		r:         codeReader{r: bits.NewReaderOrder(input, opts.Order.bitsOrder())},
+		maxWidth:  uint(opts.MaxWidth),
+		grouped:   grouped,
+		blockMode: blockMode,
+		prefix:    make([]uint16, size),
+		suffix:    make([]byte, size),
+		stack:     make([]byte, size),
+	}
+	d.reset()
+	return d
+}
+
+// reset empties the dictionary and resets the code width.
+func (d *decoder) reset() {
+	d.width = MinWidth
+	d.next = clearCode
+	if d.blockMode {
+		d.next = firstCode
+	}
+}
+
+// decode decompresses the code stream and writes the output to output.
+func (d *decoder) decode(output io.Writer) error {
+	dst := bufio.NewWriter(output)
+	prev := -1
+	for {
+		code, err := d.readCode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if code == clearCode && d.blockMode {
+			if err := d.skipGroup(); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			d.reset()
+			prev = -1
+			continue
+		}
+		var s []byte
+		switch {
+		case prev < 0 && code < 256:
+			d.stack[0] = byte(code)
+			s = d.stack[:1]
+		case prev < 0:
+			return ErrCorrupt
+		case code < d.next:
+			s = d.expand(code, len(d.stack))
+		case code == d.next:
+			// The code refers to the string being added to the dictionary,
+			// which is the previous string followed by its first byte.
+			s = d.expand(uint32(prev), len(d.stack)-1)
+			s = s[:len(s)+1]
+			s[len(s)-1] = s[0]
+		default:
+			return ErrCorrupt
+		}
+		if _, err := dst.Write(s); err != nil {
+			return err
+		}
+		if prev >= 0 && d.next < 1<<d.maxWidth {
+			d.prefix[d.next] = uint16(prev)
+			d.suffix[d.next] = s[0]
+			d.next++
+		}
+		prev = int(code)
+		if d.next > maxCode(d.width, d.maxWidth, d.grouped) {
+			if err := d.skipGroup(); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			d.width++
+		}
+	}
+	return dst.Flush()
+}
+
+// readCode reads the next code using the current code width.
+func (d *decoder) readCode() (uint32, error) {
+	code, err := d.r.readCode(d.width)
+	d.groupCodes = (d.groupCodes + 1) % groupSize
+	return code, err
+}
+
+// skipGroup skips the padding after the last code of a group if the code
+// stream is grouped.
+func (d *decoder) skipGroup() error {
+	if !d.grouped {
+		return nil
+	}
+	for d.groupCodes > 0 {
+		if _, err := d.readCode(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expand stores the string of code in d.stack so that it ends before index
+// end and returns the string.
+func (d *decoder) expand(code uint32, end int) []byte {
+	i := end
+	for code >= 256 {
+		i--
+		d.stack[i] = d.suffix[code]
+		code = uint32(d.prefix[code])
+	}
+	i--
+	d.stack[i] = byte(code)
+	return d.stack[i:end]
+}
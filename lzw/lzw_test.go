@@ -0,0 +1,173 @@
+package lzw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os/exec"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testFiles    = "../test/files/"
+	testKalevala = testFiles + "kalevala.txt"
+)
+
+func TestEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	kalevala := tu.ReadFile(testKalevala)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		{desc: "Byte", data: []byte{'a'}},
+		// The second code refers to the entry the decoder is about to add.
+		{desc: "KwKwK", data: bytes.Repeat([]byte{'a'}, 10)},
+		// More than 256 new entries widen the codes past 9 bits.
+		{desc: "WidthGrowth", data: kalevala[:20000]},
+		{desc: "FullDictionary", data: kalevala},
+		// The compression ratio drops once the text is followed by random
+		// data, so the dictionary is cleared.
+		{desc: "Clear", data: append(append([]byte{}, kalevala[:50000]...), random...)},
+	}
+	for _, order := range []Order{LSB, MSB} {
+		for _, width := range []int{MinWidth, 12, MaxWidth} {
+			opts := Options{Order: order, MaxWidth: width}
+			for _, c := range cases {
+				t.Run(fmt.Sprintf("%d/%d/%s", order, width, c.desc), func(t *testing.T) {
+					var encoded, decoded bytes.Buffer
+					tu.ExpectNil(t, Encode(bytes.NewReader(c.data), &encoded, opts))
+					tu.ExpectNil(t, Decode(&encoded, &decoded, opts))
+					if !bytes.Equal(c.data, decoded.Bytes()) {
+						t.Fatal("decoded data differs from the original")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestCodeStream(t *testing.T) {
+	// The codes are 'a', 'b', 257 ("ab"), 257 and 'b'.
+	data := []byte("abababb")
+	cases := []struct {
+		order    Order
+		expected []byte
+	}{
+		{LSB, []byte{0x61, 0xc4, 0x04, 0x0c, 0x28, 0x06}},
+		{MSB, []byte{0x30, 0x98, 0xa0, 0x30, 0x13, 0x10}},
+	}
+	for _, c := range cases {
+		var encoded bytes.Buffer
+		opts := Options{Order: c.order, MaxWidth: 12}
+		tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded, opts))
+		if !bytes.Equal(c.expected, encoded.Bytes()) {
+			t.Fatalf("expected %x, found %x", c.expected, encoded.Bytes())
+		}
+	}
+}
+
+func TestZEncodingAndDecoding(t *testing.T) {
+	// The output is also decoded using gzip, which implements the .Z format,
+	// if it is available.
+	_, err := exec.LookPath("gzip")
+	haveGzip := err == nil
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	kalevala := tu.ReadFile(testKalevala)
+	// The stream is padded whenever the code width changes.
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		// The width never changes.
+		{desc: "Short", data: []byte("abcabcabcabcabcabc")},
+		{desc: "WidthGrowth", data: kalevala[:20000]},
+		// A CLEAR code resets the width to 9 bits.
+		{desc: "Clear", data: append(append([]byte{}, kalevala[:50000]...), random...)},
+		{desc: "Kalevala", data: kalevala},
+	}
+	// With a maximum width of 9 bits, the codes grow to 10 bits like in the
+	// compress program.
+	for _, width := range []int{MinWidth, 12, MaxWidth} {
+		for _, c := range cases {
+			t.Run(fmt.Sprintf("%d/%s", width, c.desc), func(t *testing.T) {
+				var encoded, decoded bytes.Buffer
+				tu.ExpectNil(t, EncodeZ(bytes.NewReader(c.data), &encoded, width))
+				if haveGzip {
+					cmd := exec.Command("gzip", "-d", "-c")
+					cmd.Stdin = bytes.NewReader(encoded.Bytes())
+					output, err := cmd.Output()
+					tu.ExpectNil(t, err)
+					if !bytes.Equal(c.data, output) {
+						t.Fatal("data decoded by gzip differs from the original")
+					}
+				}
+				tu.ExpectNil(t, DecodeZ(&encoded, &decoded))
+				if !bytes.Equal(c.data, decoded.Bytes()) {
+					t.Fatal("decoded data differs from the original")
+				}
+			})
+		}
+	}
+}
+
+func TestDecodeZErrors(t *testing.T) {
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: io.ErrUnexpectedEOF},
+		{desc: "Magic", data: []byte{0x1f, 0x8b, 0x90}, expected: ErrHeader},
+		{desc: "Reserved", data: []byte{0x1f, 0x9d, 0xd0}, expected: ErrHeader},
+		{desc: "Width", data: []byte{0x1f, 0x9d, 0x91}, expected: ErrWidth},
+		// The first code isn't a literal.
+		{desc: "FirstCode", data: []byte{0x1f, 0x9d, 0x90, 0x01, 0x01}, expected: ErrCorrupt},
+		// The second code is past the next dictionary entry.
+		{desc: "Code", data: []byte{0x1f, 0x9d, 0x90, 0x61, 0x04, 0x02}, expected: ErrCorrupt},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, DecodeZ(bytes.NewReader(c.data), ioutil.Discard))
+		})
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for _, width := range []int{0, MinWidth - 1, MaxWidth + 1} {
+		opts := Options{Order: LSB, MaxWidth: width}
+		tu.Check(t, ErrWidth, Encode(bytes.NewReader(nil), ioutil.Discard, opts))
+		tu.Check(t, ErrWidth, Decode(bytes.NewReader(nil), ioutil.Discard, opts))
+		tu.Check(t, ErrWidth, EncodeZ(bytes.NewReader(nil), ioutil.Discard, width))
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	input := tu.ReadFile(testKalevala)
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		EncodeZ(r, ioutil.Discard, MaxWidth)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	var encoded bytes.Buffer
+	EncodeZ(bytes.NewReader(tu.ReadFile(testKalevala)), &encoded, MaxWidth)
+	input := encoded.Bytes()
+	r := bytes.NewReader(input)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		DecodeZ(r, ioutil.Discard)
+	}
+}
@@ -0,0 +1,53 @@
+package lzw
+
+import (
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+)
+
+// These constants specify the header of the .Z format. The header consists of
+// two magic bytes followed by a flag byte containing the maximum code width.
+const (
+	magic1 = 0x1f
+	magic2 = 0x9d
+	// flagBlockMode is set if code 256 is the CLEAR code.
+	flagBlockMode = 0x80
+	flagReserved  = 0x60
+	flagWidthMask = 0x1f
+)
+
+// EncodeZ compresses data from input and writes it to output in the .Z format
+// using codes of at most maxWidth bits.
+func EncodeZ(input io.Reader, output io.Writer, maxWidth int) error {
+	opts := Options{Order: LSB, MaxWidth: maxWidth}
+	if !opts.valid() {
+		return ErrWidth
+	}
+	header := [3]byte{magic1, magic2, flagBlockMode | byte(maxWidth)}
+	if _, err := output.Write(header[:]); err != nil {
+		return err
+	}
+	return newEncoder(output, opts, true).encode(input)
+}
+
+// DecodeZ decodes data in the .Z format from input and writes the
+// uncompressed data to output.
+func DecodeZ(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	var header [3]byte
+	if _, err := src.Read(header[:]); err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
+	}
+	if header[0] != magic1 || header[1] != magic2 || header[2]&flagReserved != 0 {
+		return ErrHeader
+	}
+	opts := Options{Order: LSB, MaxWidth: int(header[2] & flagWidthMask)}
+	if !opts.valid() {
+		return ErrWidth
+	}
+	blockMode := header[2]&flagBlockMode != 0
+	return newDecoder(src, opts, true, blockMode).decode(output)
+}
@@ -0,0 +1,468 @@
+/*
+Package seekable implements a compressed container format supporting random
+access to the uncompressed data.
+
+The uncompressed data is split into blocks of equal size, except for the last
+block which may be shorter. Each block is compressed independently using
+package lz77 or huffman, so any block can be decompressed without the data
+preceding it. The container is formatted as follows:
+
+	magic "SEEK"
+	format version as a byte
+	codec as a byte
+	compressed blocks
+	index
+	footer
+
+The index holds an entry for each block consisting of the uncompressed and the
+compressed offset of the block as little endian uint64 values. Compressed
+offsets are relative to the start of the container. The footer consists of the
+following fields:
+
+	offset of the index as a little endian uint64 value
+	number of blocks as a little endian uint32 value
+	size of the uncompressed data as a little endian uint64 value
+	magic "SEEK"
+
+Encode creates containers and Reader reads them.
+*/
+package seekable
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/huffman"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// Errors returned by the functions of this package.
+var (
+	ErrCorrupt     = errors.New("seekable: corrupt input")
+	ErrUnsupported = errors.New("seekable: unsupported format")
+	ErrCodec       = errors.New("seekable: invalid codec")
+	ErrBlockSize   = errors.New("seekable: invalid block size")
+	ErrOffset      = errors.New("seekable: invalid offset")
+	ErrWhence      = errors.New("seekable: invalid whence")
+)
+
+// Codec specifies the compression algorithm used for blocks.
+type Codec byte
+
+// These constants are the supported codecs.
+const (
+	LZ77    Codec = 1
+	Huffman Codec = 2
+)
+
+// valid reports whether c is a supported codec.
+func (c Codec) valid() bool {
+	return c == LZ77 || c == Huffman
+}
+
+// encode compresses data from input and writes the result to output.
+func (c Codec) encode(input io.ReadSeeker, output io.Writer) error {
+	if c == Huffman {
+		return huffman.Encode(input, output)
+	}
+	return lz77.Encode(input, output)
+}
+
+// decode decompresses data from input and writes the result to output.
+func (c Codec) decode(input io.Reader, output io.Writer) error {
+	if c == Huffman {
+		return huffman.Decode(input, output)
+	}
+	return lz77.Decode(input, output)
+}
+
+// These constants describe the layout of a container.
+const (
+	magic         = "SEEK"
+	formatVersion = 1
+	headerSize    = 6
+	entrySize     = 16
+	footerSize    = 24
+	// initialIndexSize is the initial capacity of the index in entries.
+	initialIndexSize = 64
+	// cacheSize is the number of decompressed blocks cached by Reader.
+	cacheSize = 4
+)
+
+// DefaultBlockSize is a reasonable block size for most uses. Smaller blocks
+// make random access faster at the cost of compression ratio.
+const DefaultBlockSize = 1 << 20
+
+// Encode compresses data from input and writes it to output as a container
+// with blocks of blockSize bytes compressed using codec.
+func Encode(input io.Reader, output io.Writer, codec Codec, blockSize int) error {
+	if !codec.valid() {
+		return ErrCodec
+	}
+	if blockSize <= 0 {
+		return ErrBlockSize
+	}
+	src := bufio.NewReader(input)
+	dst := &countingWriter{w: bufio.NewWriter(output)}
+	var header [headerSize]byte
+	slices.CopyBytes(header[:], []byte(magic))
+	header[4] = formatVersion
+	header[5] = byte(codec)
+	if _, err := dst.Write(header[:]); err != nil {
+		return err
+	}
+	index := make([]byte, 0, initialIndexSize*entrySize)
+	var uncompressed int64
+	block := make([]byte, blockSize)
+	blockReader := bufio.NewBytesReader(nil)
+	for {
+		n, err := src.Read(block)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if len(index) == cap(index) {
+			grown := make([]byte, len(index), 2*cap(index))
+			slices.CopyBytes(grown, index)
+			index = grown
+		}
+		index = index[:len(index)+entrySize]
+		entry := index[len(index)-entrySize:]
+		binary.PutUint64LE(entry[:8], uint64(uncompressed))
+		binary.PutUint64LE(entry[8:], uint64(dst.n))
+		blockReader.Reset(block[:n])
+		if err := codec.encode(blockReader, dst); err != nil {
+			return err
+		}
+		uncompressed += int64(n)
+		if err == io.EOF {
+			break
+		}
+	}
+	var footer [footerSize]byte
+	binary.PutUint64LE(footer[:8], uint64(dst.n))
+	binary.PutUint32LE(footer[8:12], uint32(len(index)/entrySize))
+	binary.PutUint64LE(footer[12:20], uint64(uncompressed))
+	slices.CopyBytes(footer[20:], []byte(magic))
+	if _, err := dst.Write(index); err != nil {
+		return err
+	}
+	if _, err := dst.Write(footer[:]); err != nil {
+		return err
+	}
+	return dst.w.Flush()
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// block is an index entry of a container.
+type block struct {
+	uncompressed, compressed int64
+}
+
+// Reader provides random access to the uncompressed data of a container. Only
+// the blocks containing the requested data are decompressed. The most recently
+// used blocks are cached, so sequential reads decompress each block once.
+//
+// ReadAt may be called concurrently. Blocks are decompressed concurrently and
+// only lookups in the cache are serialized. Read and Seek share an offset and
+// must not be called concurrently with each other.
+type Reader struct {
+	r      io.ReaderAt
+	codec  Codec
+	blocks []block
+	// indexOffset is the offset of the index, which is also the end of the
+	// last block.
+	indexOffset int64
+	size        int64
+	pos         int64
+
+	// lock guards the fields below. Sending to it locks and receiving from
+	// it unlocks.
+	lock  chan struct{}
+	cache [cacheSize]cacheEntry
+	// clock is incremented on each cache access to track the least recently
+	// used entry.
+	clock uint64
+	// free holds the buffers of evicted blocks for reuse.
+	free [cacheSize][]byte
+}
+
+// cacheEntry is a decompressed block in the cache of Reader.
+type cacheEntry struct {
+	block int // Index of the block or -1 if the entry is empty.
+	data  []byte
+	used  uint64 // Value of Reader.clock on the last access.
+}
+
+// NewReader returns a Reader reading the container of size bytes from r. The
+// index of the container is read and validated before returning.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < headerSize+footerSize {
+		return nil, ErrCorrupt
+	}
+	var header [headerSize]byte
+	if _, err := bufio.ReadFull(io.NewSectionReader(r, 0, headerSize), header[:]); err != nil {
+		return nil, err
+	}
+	var footer [footerSize]byte
+	if _, err := bufio.ReadFull(io.NewSectionReader(r, size-footerSize, footerSize), footer[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != magic || string(footer[20:]) != magic {
+		return nil, ErrUnsupported
+	}
+	if header[4] != formatVersion || !Codec(header[5]).valid() {
+		return nil, ErrUnsupported
+	}
+	indexOffset := int64(binary.GetUint64LE(footer[:8]))
+	count := int64(binary.GetUint32LE(footer[8:12]))
+	uncompressedSize := int64(binary.GetUint64LE(footer[12:20]))
+	if indexOffset < headerSize || indexOffset+count*entrySize != size-footerSize ||
+		uncompressedSize < 0 || (count == 0) != (uncompressedSize == 0) {
+		return nil, ErrCorrupt
+	}
+	index := make([]byte, count*entrySize)
+	if _, err := bufio.ReadFull(io.NewSectionReader(r, indexOffset, count*entrySize), index); err != nil {
+		return nil, err
+	}
+	blocks := make([]block, count)
+	for i := range blocks {
+		entry := index[i*entrySize:]
+		b := block{
+			uncompressed: int64(binary.GetUint64LE(entry[:8])),
+			compressed:   int64(binary.GetUint64LE(entry[8:16])),
+		}
+		// Blocks must be nonempty and contiguous.
+		if i == 0 {
+			if b.uncompressed != 0 || b.compressed != headerSize {
+				return nil, ErrCorrupt
+			}
+		} else if b.uncompressed <= blocks[i-1].uncompressed ||
+			b.compressed <= blocks[i-1].compressed {
+			return nil, ErrCorrupt
+		}
+		if b.uncompressed >= uncompressedSize || b.compressed >= indexOffset {
+			return nil, ErrCorrupt
+		}
+		blocks[i] = b
+	}
+	reader := &Reader{
+		r:           r,
+		codec:       Codec(header[5]),
+		blocks:      blocks,
+		indexOffset: indexOffset,
+		size:        uncompressedSize,
+		lock:        make(chan struct{}, 1),
+	}
+	for i := range reader.cache {
+		reader.cache[i].block = -1
+	}
+	return reader, nil
+}
+
+// Size returns the size of the uncompressed data.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// ReadAt reads len(p) bytes of uncompressed data starting at offset off into p.
+// It implements io.ReaderAt.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrOffset
+	}
+	total := 0
+	for total < len(p) {
+		if off >= r.size {
+			return total, io.EOF
+		}
+		i := r.findBlock(off)
+		n, err := r.readBlock(p[total:], i, off-r.blocks[i].uncompressed)
+		total += n
+		off += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// findBlock returns the index of the block containing uncompressed offset off.
+func (r *Reader) findBlock(off int64) int {
+	// The block is in range [low, high).
+	low, high := 0, len(r.blocks)
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		if r.blocks[mid].uncompressed <= off {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low
+}
+
+// readBlock copies data starting at offset off of block i into p. The number
+// of bytes copied is returned. The block is decompressed without holding the
+// lock if it isn't cached.
+func (r *Reader) readBlock(p []byte, i int, off int64) (int, error) {
+	r.lock <- struct{}{}
+	r.clock++
+	for j := range r.cache {
+		if r.cache[j].block == i {
+			r.cache[j].used = r.clock
+			n := slices.CopyBytes(p, r.cache[j].data[off:])
+			<-r.lock
+			return n, nil
+		}
+	}
+	buf := r.takeFree()
+	<-r.lock
+
+	data, err := r.decodeBlock(i, buf)
+	if err != nil {
+		return 0, err
+	}
+
+	r.lock <- struct{}{}
+	r.store(i, data)
+	n := slices.CopyBytes(p, data[off:])
+	<-r.lock
+	return n, nil
+}
+
+// takeFree removes and returns a buffer from the free list or nil if it is
+// empty. The lock must be held.
+func (r *Reader) takeFree() []byte {
+	for j, buf := range r.free {
+		if buf != nil {
+			r.free[j] = nil
+			return buf
+		}
+	}
+	return nil
+}
+
+// putFree adds buf to the free list if there is room. The lock must be held.
+func (r *Reader) putFree(buf []byte) {
+	for j := range r.free {
+		if r.free[j] == nil {
+			r.free[j] = buf[:0]
+			return
+		}
+	}
+}
+
+// store adds the decompressed data of block i to the cache, evicting the least
+// recently used entry. If another call already cached the block, data is put
+// in the free list instead. The lock must be held.
+func (r *Reader) store(i int, data []byte) {
+	oldest := 0
+	for j := range r.cache {
+		if r.cache[j].block == i {
+			r.putFree(data)
+			return
+		}
+		if r.cache[j].used < r.cache[oldest].used {
+			oldest = j
+		}
+	}
+	if r.cache[oldest].data != nil {
+		r.putFree(r.cache[oldest].data)
+	}
+	r.cache[oldest] = cacheEntry{block: i, data: data, used: r.clock}
+}
+
+// decodeBlock decompresses block i and returns its data. buf is used for
+// storing the data if it has enough capacity.
+func (r *Reader) decodeBlock(i int, buf []byte) ([]byte, error) {
+	start, end := r.blocks[i].compressed, r.indexOffset
+	length := r.size - r.blocks[i].uncompressed
+	if i+1 < len(r.blocks) {
+		end = r.blocks[i+1].compressed
+		length = r.blocks[i+1].uncompressed - r.blocks[i].uncompressed
+	}
+	dst := &blockWriter{data: buf[:0], limit: length}
+	if err := r.codec.decode(io.NewSectionReader(r.r, start, end-start), dst); err != nil {
+		return nil, err
+	}
+	if int64(len(dst.data)) != length {
+		return nil, ErrCorrupt
+	}
+	return dst.data, nil
+}
+
+// blockWriter collects the decompressed data of a block. Writing more than
+// limit bytes fails with ErrCorrupt, which stops the decoder as soon as
+// corrupt input produces too much data. The buffer grows as data is written,
+// so a corrupt index can't cause a large allocation up front.
+type blockWriter struct {
+	data  []byte
+	limit int64
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	n := len(w.data)
+	if int64(len(p)) > w.limit-int64(n) {
+		return 0, ErrCorrupt
+	}
+	if n+len(p) > cap(w.data) {
+		newCap := int64(2*cap(w.data) + len(p))
+		if newCap > w.limit {
+			newCap = w.limit
+		}
+		data := make([]byte, n, newCap)
+		slices.CopyBytes(data, w.data)
+		w.data = data
+	}
+	w.data = w.data[:n+len(p)]
+	return slices.CopyBytes(w.data[n:], p), nil
+}
+
+// Read reads uncompressed data into p starting at the current offset. It
+// implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.size-r.pos {
+		p = p[:r.size-r.pos]
+	}
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	return n, err
+}
+
+// Seek sets the offset for the next Read. It implements io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, ErrWhence
+	}
+	if offset < 0 {
+		return 0, ErrOffset
+	}
+	r.pos = offset
+	return offset, nil
+}
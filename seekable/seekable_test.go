@@ -0,0 +1,263 @@
+package seekable
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+)
+
+// encode returns data encoded using Encode.
+func encode(t *testing.T, data []byte, codec Codec, blockSize int) []byte {
+	t.Helper()
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded, codec, blockSize))
+	return encoded.Bytes()
+}
+
+// newReader returns a Reader reading encoded.
+func newReader(t *testing.T, encoded []byte) *Reader {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(encoded), int64(len(encoded)))
+	tu.ExpectNil(t, err)
+	return r
+}
+
+func TestEncodingAndDecoding(t *testing.T) {
+	kalevala := tu.ReadFile(testKalevala)
+	cases := []struct {
+		desc      string
+		data      []byte
+		blockSize int
+	}{
+		{desc: "Empty", data: []byte{}, blockSize: 10},
+		{desc: "SingleByte", data: []byte("a"), blockSize: 10},
+		{desc: "ExactBlocks", data: []byte("abcdefghijklmnopqrst"), blockSize: 10},
+		{desc: "PartialBlock", data: []byte("abcdefghijklmnopqrstu"), blockSize: 10},
+		{desc: "Kalevala", data: kalevala, blockSize: 1 << 16},
+		{desc: "KalevalaSingleBlock", data: kalevala, blockSize: DefaultBlockSize},
+	}
+	codecs := []struct {
+		desc  string
+		codec Codec
+	}{
+		{desc: "LZ77", codec: LZ77},
+		{desc: "Huffman", codec: Huffman},
+	}
+	for _, codec := range codecs {
+		for _, c := range cases {
+			t.Run(codec.desc+"/"+c.desc, func(t *testing.T) {
+				r := newReader(t, encode(t, c.data, codec.codec, c.blockSize))
+				tu.Check(t, int64(len(c.data)), r.Size())
+				decoded, err := ioutil.ReadAll(r)
+				tu.ExpectNil(t, err)
+				if !bytes.Equal(c.data, decoded) {
+					t.Fatal("decoded data differs from the original")
+				}
+			})
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	encoded := encode(t, []byte("abcde"), LZ77, 3)
+	tu.Check(t, "SEEK\x01\x01", string(encoded[:headerSize]))
+	footer := encoded[len(encoded)-footerSize:]
+	indexOffset := binary.GetUint64LE(footer)
+	tu.Check(t, uint64(len(encoded)-footerSize-2*entrySize), indexOffset)
+	tu.Check(t, uint32(2), binary.GetUint32LE(footer[8:]))
+	tu.Check(t, uint64(5), binary.GetUint64LE(footer[12:]))
+	tu.Check(t, magic, string(footer[20:]))
+	index := encoded[indexOffset:]
+	tu.Check(t, uint64(0), binary.GetUint64LE(index))
+	tu.Check(t, uint64(headerSize), binary.GetUint64LE(index[8:]))
+	tu.Check(t, uint64(3), binary.GetUint64LE(index[16:]))
+	// The blocks are decodable on their own.
+	var decoded bytes.Buffer
+	second := encoded[binary.GetUint64LE(index[24:]):indexOffset]
+	tu.ExpectNil(t, LZ77.decode(bytes.NewReader(second), &decoded))
+	tu.Check(t, "de", decoded.String())
+}
+
+func TestReadAt(t *testing.T) {
+	data := tu.ReadFile(testKalevala)
+	r := newReader(t, encode(t, data, LZ77, 1000))
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		off := rng.Int63n(int64(len(data)))
+		p := make([]byte, rng.Intn(3000))
+		n, err := r.ReadAt(p, off)
+		expected := data[off:]
+		if len(expected) > len(p) {
+			expected = expected[:len(p)]
+		} else if len(expected) < len(p) {
+			tu.Check(t, io.EOF, err)
+		} else {
+			tu.ExpectNil(t, err)
+		}
+		if !bytes.Equal(expected, p[:n]) {
+			t.Fatalf("data at offset %d differs from the original", off)
+		}
+	}
+	n, err := r.ReadAt(make([]byte, 1), int64(len(data)))
+	tu.Check(t, 0, n)
+	tu.Check(t, io.EOF, err)
+	_, err = r.ReadAt(make([]byte, 1), -1)
+	tu.Check(t, ErrOffset, err)
+}
+
+func TestReadAtConcurrent(t *testing.T) {
+	data := tu.ReadFile(testKalevala)
+	r := newReader(t, encode(t, data, LZ77, 1000))
+	errs := make(chan error)
+	for w := 0; w < 8; w++ {
+		go func(seed int64) {
+			rng := rand.New(rand.NewSource(seed))
+			p := make([]byte, 1500)
+			for i := 0; i < 100; i++ {
+				off := rng.Int63n(int64(len(data) - len(p)))
+				if _, err := r.ReadAt(p, off); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(data[off:off+int64(len(p))], p) {
+					errs <- fmt.Errorf("data at offset %d differs from the original", off)
+					return
+				}
+			}
+			errs <- nil
+		}(int64(w))
+	}
+	for w := 0; w < 8; w++ {
+		tu.ExpectNil(t, <-errs)
+	}
+}
+
+func TestCache(t *testing.T) {
+	r := newReader(t, encode(t, []byte("abcdefghijklmnopqrstuvwxyz"), LZ77, 4))
+	p := make([]byte, 1)
+	// Alternating between blocks keeps both of them cached.
+	for i := 0; i < 10; i++ {
+		_, err := r.ReadAt(p, int64(i%2*4))
+		tu.ExpectNil(t, err)
+	}
+	cached := 0
+	for _, e := range r.cache {
+		if e.block >= 0 {
+			cached++
+		}
+	}
+	tu.Check(t, 2, cached)
+	// Reading more blocks than fit in the cache evicts the least recently
+	// used ones and reuses their buffers.
+	for off := int64(0); off < 26; off += 4 {
+		_, err := r.ReadAt(p, off)
+		tu.ExpectNil(t, err)
+	}
+	for _, e := range r.cache {
+		if e.block < 3 {
+			t.Fatalf("block %d wasn't evicted", e.block)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	data := []byte("abcdefghijklmnopqrstuvwxyz")
+	r := newReader(t, encode(t, data, Huffman, 4))
+	p := make([]byte, 3)
+	cases := []struct {
+		offset   int64
+		whence   int
+		position int64
+		expected string
+	}{
+		{offset: 5, whence: io.SeekStart, position: 5, expected: "fgh"},
+		{offset: 2, whence: io.SeekCurrent, position: 10, expected: "klm"},
+		{offset: -4, whence: io.SeekEnd, position: 22, expected: "wxy"},
+		{offset: -10, whence: io.SeekCurrent, position: 15, expected: "pqr"},
+	}
+	for _, c := range cases {
+		position, err := r.Seek(c.offset, c.whence)
+		tu.ExpectNil(t, err)
+		tu.Check(t, c.position, position)
+		_, err = io.ReadFull(r, p)
+		tu.ExpectNil(t, err)
+		tu.Check(t, c.expected, string(p))
+	}
+	_, err := r.Seek(-1, io.SeekStart)
+	tu.Check(t, ErrOffset, err)
+	_, err = r.Seek(0, 3)
+	tu.Check(t, ErrWhence, err)
+	_, err = r.Seek(100, io.SeekStart)
+	tu.ExpectNil(t, err)
+	_, err = r.Read(p)
+	tu.Check(t, io.EOF, err)
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tu.Check(t, ErrCodec, Encode(bytes.NewReader(nil), ioutil.Discard, 0, 10))
+	tu.Check(t, ErrBlockSize, Encode(bytes.NewReader(nil), ioutil.Discard, LZ77, 0))
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	valid := encode(t, []byte("abcdefghij"), LZ77, 4)
+	corrupt := func(i int, b byte) []byte {
+		data := append([]byte{}, valid...)
+		data[i] = b
+		return data
+	}
+	indexOffset := int(binary.GetUint64LE(valid[len(valid)-footerSize:]))
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: ErrCorrupt},
+		{desc: "Magic", data: corrupt(0, 'X'), expected: ErrUnsupported},
+		{desc: "FooterMagic", data: corrupt(len(valid)-1, 'X'), expected: ErrUnsupported},
+		{desc: "Version", data: corrupt(4, 2), expected: ErrUnsupported},
+		{desc: "Codec", data: corrupt(5, 9), expected: ErrUnsupported},
+		{desc: "IndexOffset", data: corrupt(len(valid)-footerSize, 0), expected: ErrCorrupt},
+		{desc: "BlockCount", data: corrupt(len(valid)-footerSize+8, 2), expected: ErrCorrupt},
+		{desc: "FirstBlock", data: corrupt(indexOffset, 1), expected: ErrCorrupt},
+		{desc: "BlockOrder", data: corrupt(indexOffset+2*entrySize, 1), expected: ErrCorrupt},
+		{desc: "Truncated", data: valid[:len(valid)-1], expected: ErrUnsupported},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := NewReader(bytes.NewReader(c.data), int64(len(c.data)))
+			tu.Check(t, c.expected, err)
+		})
+	}
+}
+
+func TestReadCorruptBlock(t *testing.T) {
+	encoded := encode(t, []byte("abcdefghij"), LZ77, 4)
+	// The second block claims a length of 1 byte but contains 4.
+	encoded[len(encoded)-footerSize-entrySize] = 5
+	r := newReader(t, encoded)
+	_, err := ioutil.ReadAll(r)
+	tu.Check(t, ErrCorrupt, err)
+}
+
+func BenchmarkReadAt(b *testing.B) {
+	data := tu.ReadFile(testKalevala)
+	var encoded bytes.Buffer
+	Encode(bytes.NewReader(data), &encoded, LZ77, 1<<14)
+	r, _ := NewReader(bytes.NewReader(encoded.Bytes()), int64(encoded.Len()))
+	p := make([]byte, 100)
+	rng := rand.New(rand.NewSource(1))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ReadAt(p, rng.Int63n(int64(len(data)-len(p))))
+	}
+}
@@ -0,0 +1,261 @@
+package snappy
+
+import (
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// These constants are the element types stored in the two lowest bits of a tag
+// byte.
+const (
+	tagLiteral = 0
+	tagCopy1   = 1
+	tagCopy2   = 2
+	tagCopy4   = 3
+)
+
+// These constants specify the limits of the block format.
+const (
+	minMatchLength = 4
+	maxOffset      = 1<<16 - 1
+	// maxLookahead limits the length of matches searched by the encoder.
+	maxLookahead = 1 << 10
+	// maxBlockLength is the largest length a block may declare.
+	maxBlockLength = 1<<32 - 1
+)
+
+// MaxEncodedBlockSize returns the maximum size of a block produced by
+// EncodeBlock for n bytes of input.
+func MaxEncodedBlockSize(n int) int {
+	return 32 + n + n/6
+}
+
+// EncodeBlock compresses src using the Snappy raw block format and returns the
+// compressed block.
+func EncodeBlock(src []byte) []byte {
+	e := blockEncoder{
+		dst: make([]byte, MaxEncodedBlockSize(len(src))),
+	}
+	e.n = putUvarint(e.dst, uint64(len(src)))
+	matcher := lz77.NewMatcher(maxOffset)
+	anchor := 0
+	for pos := 0; pos < len(src); {
+		end := len(src)
+		if end-pos > maxLookahead {
+			end = pos + maxLookahead
+		}
+		length, distance := matcher.FindMatch(src[pos:end])
+		if length < minMatchLength {
+			matcher.AppendByte(src[pos])
+			pos++
+			continue
+		}
+		e.writeLiteral(src[anchor:pos])
+		e.writeCopy(distance, length)
+		matcher.Append(src[pos : pos+length])
+		pos += length
+		anchor = pos
+	}
+	e.writeLiteral(src[anchor:])
+	return e.dst[:e.n]
+}
+
+// blockEncoder holds the output of an ongoing EncodeBlock call.
+type blockEncoder struct {
+	dst []byte
+	n   int
+}
+
+// writeLiteral writes a literal element containing literals. Nothing is
+// written if literals is empty.
+func (e *blockEncoder) writeLiteral(literals []byte) {
+	if len(literals) == 0 {
+		return
+	}
+	n := uint32(len(literals) - 1)
+	switch {
+	case n < 60:
+		e.dst[e.n] = byte(n)<<2 | tagLiteral
+		e.n++
+	case n < 1<<8:
+		e.dst[e.n] = 60<<2 | tagLiteral
+		e.dst[e.n+1] = byte(n)
+		e.n += 2
+	case n < 1<<16:
+		e.dst[e.n] = 61<<2 | tagLiteral
+		e.dst[e.n+1] = byte(n)
+		e.dst[e.n+2] = byte(n >> 8)
+		e.n += 3
+	case n < 1<<24:
+		e.dst[e.n] = 62<<2 | tagLiteral
+		e.dst[e.n+1] = byte(n)
+		e.dst[e.n+2] = byte(n >> 8)
+		e.dst[e.n+3] = byte(n >> 16)
+		e.n += 4
+	default:
+		e.dst[e.n] = 63<<2 | tagLiteral
+		e.dst[e.n+1] = byte(n)
+		e.dst[e.n+2] = byte(n >> 8)
+		e.dst[e.n+3] = byte(n >> 16)
+		e.dst[e.n+4] = byte(n >> 24)
+		e.n += 5
+	}
+	e.n += slices.CopyBytes(e.dst[e.n:], literals)
+}
+
+// writeCopy writes copy elements for a match of length bytes at offset.
+// length must be at least minMatchLength.
+func (e *blockEncoder) writeCopy(offset, length int) {
+	// A single copy element can hold at most 64 bytes. The remainder is
+	// kept at least minMatchLength bytes long.
+	for length >= 68 {
+		e.writeCopy2(offset, 64)
+		length -= 64
+	}
+	if length > 64 {
+		e.writeCopy2(offset, 60)
+		length -= 60
+	}
+	if length < 12 && offset < 2048 {
+		e.dst[e.n] = byte(offset>>8)<<5 | byte(length-4)<<2 | tagCopy1
+		e.dst[e.n+1] = byte(offset)
+		e.n += 2
+		return
+	}
+	e.writeCopy2(offset, length)
+}
+
+// writeCopy2 writes a copy element with a 2-byte offset.
+func (e *blockEncoder) writeCopy2(offset, length int) {
+	e.dst[e.n] = byte(length-1)<<2 | tagCopy2
+	e.dst[e.n+1] = byte(offset)
+	e.dst[e.n+2] = byte(offset >> 8)
+	e.n += 3
+}
+
+// DecodedBlockLength returns the length of the decompressed data of the block
+// in src.
+func DecodedBlockLength(src []byte) (int, error) {
+	length, n := uvarint(src)
+	if n <= 0 || length > maxBlockLength {
+		return 0, ErrCorrupt
+	}
+	return int(length), nil
+}
+
+// DecodeBlock decompresses the Snappy raw block in src and returns the
+// decompressed data. ErrCorrupt is returned if src is not a valid block.
+func DecodeBlock(src []byte) ([]byte, error) {
+	length, n := uvarint(src)
+	if n <= 0 || length > maxBlockLength {
+		return nil, ErrCorrupt
+	}
+	// Each byte of src can expand to at most 64 bytes of output. Checking
+	// this before allocating prevents corrupt lengths from causing huge
+	// allocations.
+	if length > 64*uint64(len(src)) {
+		return nil, ErrCorrupt
+	}
+	dst := make([]byte, length)
+	if err := decodeElements(src[n:], dst); err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// decodeElements decodes the elements of a block in src to dst. The elements
+// must fill dst exactly.
+func decodeElements(src, dst []byte) error {
+	i := 0
+	out := 0
+	for i < len(src) {
+		tag := src[i]
+		i++
+		var length, offset int
+		switch tag & 3 {
+		case tagLiteral:
+			length = int(tag >> 2)
+			if length >= 60 {
+				extra := length - 59
+				if len(src)-i < extra {
+					return ErrCorrupt
+				}
+				length = 0
+				for j := extra - 1; j >= 0; j-- {
+					length = length<<8 | int(src[i+j])
+				}
+				i += extra
+			}
+			length++
+			if length > len(src)-i || length > len(dst)-out {
+				return ErrCorrupt
+			}
+			out += slices.CopyBytes(dst[out:], src[i:i+length])
+			i += length
+			continue
+		case tagCopy1:
+			if len(src)-i < 1 {
+				return ErrCorrupt
+			}
+			length = 4 + int(tag>>2)&7
+			offset = int(tag>>5)<<8 | int(src[i])
+			i++
+		case tagCopy2:
+			if len(src)-i < 2 {
+				return ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(src[i]) | int(src[i+1])<<8
+			i += 2
+		case tagCopy4:
+			if len(src)-i < 4 {
+				return ErrCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(src[i]) | int(src[i+1])<<8 |
+				int(src[i+2])<<16 | int(src[i+3])<<24
+			i += 4
+		}
+		if offset <= 0 || offset > out || length > len(dst)-out {
+			return ErrCorrupt
+		}
+		for j := 0; j < length; j++ {
+			dst[out] = dst[out-offset]
+			out++
+		}
+	}
+	if out != len(dst) {
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// putUvarint stores n in dst as a varint and returns the number of bytes
+// written.
+func putUvarint(dst []byte, n uint64) int {
+	i := 0
+	for ; n >= 0x80; i++ {
+		dst[i] = byte(n) | 0x80
+		n >>= 7
+	}
+	dst[i] = byte(n)
+	return i + 1
+}
+
+// uvarint decodes a varint from the beginning of src. It returns the value and
+// the number of bytes read. The number of bytes is 0 if src is too short and
+// negative if the value overflows 64 bits.
+func uvarint(src []byte) (uint64, int) {
+	var n uint64
+	for i := 0; i < len(src); i++ {
+		if i == 10 {
+			return 0, -1
+		}
+		b := src[i]
+		n |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return n, i + 1
+		}
+	}
+	return 0, 0
+}
@@ -0,0 +1,197 @@
+/*
+Package snappy implements the Snappy raw block format and the Snappy framing
+format.
+
+A raw block starts with the length of the uncompressed data as a varint and is
+followed by elements. Each element is either a literal containing bytes copied
+to the output as is or a copy referring to earlier data in the block.
+EncodeBlock and DecodeBlock compress and decompress raw blocks. Matches are
+found using the match finding of package lz77.
+
+The framing format splits a stream into chunks. The stream starts with a stream
+identifier chunk and each data chunk contains at most 65536 bytes of
+uncompressed data and a masked CRC-32C checksum of it. Encode and Decode
+compress and decompress streams in the framing format.
+*/
+package snappy
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+)
+
+// Errors returned by decoding functions.
+var (
+	ErrCorrupt     = errors.New("snappy: corrupt input")
+	ErrChecksum    = errors.New("snappy: invalid checksum")
+	ErrUnsupported = errors.New("snappy: unsupported chunk type")
+)
+
+// These constants are the chunk types of the framing format.
+const (
+	chunkCompressed       = 0x00
+	chunkUncompressed     = 0x01
+	chunkMinSkippable     = 0x80
+	chunkPadding          = 0xfe
+	chunkStreamIdentifier = 0xff
+)
+
+// streamIdentifier is the contents of a stream identifier chunk.
+const streamIdentifier = "sNaPpY"
+
+// maxChunkDataLength is the largest amount of uncompressed data in a chunk.
+const maxChunkDataLength = 1 << 16
+
+// Encode compresses data from input and writes it to output using the
+// framing format.
+func Encode(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	dst := bufio.NewWriter(output)
+	if err := writeChunkHeader(dst, chunkStreamIdentifier, len(streamIdentifier)); err != nil {
+		return err
+	}
+	if _, err := dst.Write([]byte(streamIdentifier)); err != nil {
+		return err
+	}
+	data := make([]byte, maxChunkDataLength)
+	for {
+		n, err := src.Read(data)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		if err := writeDataChunk(dst, data[:n]); err != nil {
+			return err
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return dst.Flush()
+}
+
+// writeDataChunk writes data as a compressed chunk. If compression doesn't
+// reduce the size of data enough, it is written as an uncompressed chunk.
+func writeDataChunk(w *bufio.Writer, data []byte) error {
+	chunkType := byte(chunkCompressed)
+	body := EncodeBlock(data)
+	if len(body) >= len(data)-len(data)/8 {
+		chunkType = chunkUncompressed
+		body = data
+	}
+	if err := writeChunkHeader(w, chunkType, 4+len(body)); err != nil {
+		return err
+	}
+	var sum [4]byte
+	binary.PutUint32LE(sum[:], maskedChecksum(data))
+	if _, err := w.Write(sum[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// writeChunkHeader writes the type and length of a chunk.
+func writeChunkHeader(w *bufio.Writer, chunkType byte, length int) error {
+	header := [4]byte{chunkType, byte(length), byte(length >> 8), byte(length >> 16)}
+	_, err := w.Write(header[:])
+	return err
+}
+
+// maskedChecksum returns the masked CRC-32C checksum of data. The checksum is
+// masked because computing CRCs of data containing embedded CRCs is
+// problematic.
+func maskedChecksum(data []byte) uint32 {
+	var crc checksum.CRC32C
+	crc.Write(data)
+	sum := crc.Sum32()
+	return (sum>>15 | sum<<17) + 0xa282ead8
+}
+
+// Decode decodes a stream in the framing format from input and writes the
+// uncompressed data to output. Concatenated streams are decoded as a single
+// stream.
+func Decode(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	dst := bufio.NewWriter(output)
+	chunk := make([]byte, 4+MaxEncodedBlockSize(maxChunkDataLength))
+	data := make([]byte, maxChunkDataLength)
+	for chunks := 0; ; chunks++ {
+		var header [4]byte
+		n, err := src.Read(header[:])
+		if err != nil {
+			if err == io.EOF && n == 0 && chunks > 0 {
+				return dst.Flush()
+			}
+			return unexpectedEOF(err)
+		}
+		chunkType := header[0]
+		length := int(header[1]) | int(header[2])<<8 | int(header[3])<<16
+		if chunks == 0 && chunkType != chunkStreamIdentifier {
+			return ErrCorrupt
+		}
+		if chunkType >= chunkMinSkippable && chunkType <= chunkPadding {
+			if _, err := src.Discard(length); err != nil {
+				return unexpectedEOF(err)
+			}
+			continue
+		}
+		if chunkType > chunkUncompressed && chunkType != chunkStreamIdentifier {
+			return ErrUnsupported
+		}
+		if length > len(chunk) {
+			return ErrCorrupt
+		}
+		if _, err := src.Read(chunk[:length]); err != nil {
+			return unexpectedEOF(err)
+		}
+		body := chunk[:length]
+		if chunkType == chunkStreamIdentifier {
+			if string(body) != streamIdentifier {
+				return ErrCorrupt
+			}
+			continue
+		}
+		if len(body) < 4 {
+			return ErrCorrupt
+		}
+		sum := binary.GetUint32LE(body)
+		body = body[4:]
+		var decoded []byte
+		if chunkType == chunkUncompressed {
+			if len(body) > maxChunkDataLength {
+				return ErrCorrupt
+			}
+			decoded = body
+		} else {
+			length, n := uvarint(body)
+			if n <= 0 || length > maxChunkDataLength {
+				return ErrCorrupt
+			}
+			decoded = data[:length]
+			if err := decodeElements(body[n:], decoded); err != nil {
+				return err
+			}
+		}
+		if maskedChecksum(decoded) != sum {
+			return ErrChecksum
+		}
+		if _, err := dst.Write(decoded); err != nil {
+			return err
+		}
+	}
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
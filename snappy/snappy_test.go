@@ -0,0 +1,192 @@
+package snappy
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testFiles    = "../test/files/"
+	testKalevala = testFiles + "kalevala.txt"
+)
+
+func TestBlockEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 2*maxOffset)
+	rand.New(rand.NewSource(1)).Read(random)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		// The length of a short literal fits in its tag byte.
+		{desc: "Short", data: []byte("abc")},
+		// Longer literals store their lengths in 1 or 3 extra bytes.
+		{desc: "Literal1", data: random[:1000]},
+		{desc: "Literal3", data: random[:maxOffset+1000]},
+		// A match longer than 64 bytes is split into several copies.
+		{desc: "LongMatches", data: bytes.Repeat([]byte{'x'}, 100000)},
+		// Offsets of 2048 or more need copies with 2-byte offsets.
+		{desc: "FarMatches", data: bytes.Repeat(random[:maxOffset-10], 2)},
+		{desc: "Kalevala", data: tu.ReadFile(testKalevala)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			encoded := EncodeBlock(c.data)
+			if len(encoded) > MaxEncodedBlockSize(len(c.data)) {
+				t.Fatalf("encoded block too large: %d", len(encoded))
+			}
+			length, err := DecodedBlockLength(encoded)
+			tu.ExpectNil(t, err)
+			tu.Check(t, len(c.data), length)
+			decoded, err := DecodeBlock(encoded)
+			tu.ExpectNil(t, err)
+			if !bytes.Equal(c.data, decoded) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestBlockFormat(t *testing.T) {
+	cases := []struct {
+		desc     string
+		data     string
+		expected string
+	}{
+		{
+			// 4 literals followed by two 4-byte copies with 1-byte
+			// offsets.
+			desc:     "Copy1",
+			data:     "abcdabcdabcd",
+			expected: "\x0c\x0cabcd\x01\x04\x01\x04",
+		},
+		{
+			// 16 literals followed by a copy too long for a 1-byte offset
+			// element.
+			desc:     "Copy2",
+			data:     "abcdefghijklmnopabcdefghijklmnop",
+			expected: "\x20\x3cabcdefghijklmnop\x3e\x10\x00",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, string(EncodeBlock([]byte(c.data))))
+		})
+	}
+}
+
+func TestDecodeBlockErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "Empty", data: []byte{}},
+		{desc: "LengthOverflow", data: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{desc: "TooShort", data: []byte{3, 0 << 2, 'a'}},
+		{desc: "TooLong", data: []byte{1, 1 << 2, 'a', 'b'}},
+		{desc: "LiteralPastEnd", data: []byte{3, 2 << 2, 'a'}},
+		{desc: "ZeroOffset", data: []byte{5, 0 << 2, 'a', tagCopy1, 0}},
+		{desc: "OffsetTooFar", data: []byte{5, 0 << 2, 'a', tagCopy1, 2}},
+		{desc: "TruncatedCopy", data: []byte{5, 0 << 2, 'a', tagCopy2, 1}},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := DecodeBlock(c.data)
+			tu.Check(t, ErrCorrupt, err)
+		})
+	}
+}
+
+func TestDecodeReferenceVectors(t *testing.T) {
+	for _, name := range []string{"grammar.lsp", "cp.html", "sum"} {
+		original := tu.ReadFile(testFiles + name)
+		t.Run(name+"/Raw", func(t *testing.T) {
+			decoded, err := DecodeBlock(tu.ReadFile(testFiles + "snappy/" + name + ".rawsnappy"))
+			tu.ExpectNil(t, err)
+			if !bytes.Equal(original, decoded) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+		t.Run(name+"/Framed", func(t *testing.T) {
+			var decoded bytes.Buffer
+			framed := tu.ReadFile(testFiles + "snappy/" + name + ".sz")
+			tu.ExpectNil(t, Decode(bytes.NewReader(framed), &decoded))
+			if !bytes.Equal(original, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestFrameEncodingAndDecoding(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	kalevala := tu.ReadFile(testKalevala)
+	cases := []struct {
+		desc string
+		data []byte
+	}{
+		// Only the stream identifier.
+		{desc: "Empty", data: []byte{}},
+		{desc: "OneChunk", data: kalevala[:maxChunkDataLength]},
+		{desc: "MultipleChunks", data: kalevala},
+		// Data that doesn't compress is stored in uncompressed chunks.
+		{desc: "Uncompressed", data: random},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var encoded, decoded bytes.Buffer
+			tu.ExpectNil(t, Encode(bytes.NewReader(c.data), &encoded))
+			tu.ExpectNil(t, Decode(&encoded, &decoded))
+			if !bytes.Equal(c.data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecodeConcatenatedStreams(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("first ")), &encoded))
+	// A padding chunk and a reserved skippable chunk.
+	encoded.Write([]byte{chunkPadding, 2, 0, 0, 0, 0})
+	encoded.Write([]byte{0x80, 1, 0, 0, 9})
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("second")), &encoded))
+	var decoded bytes.Buffer
+	tu.ExpectNil(t, Decode(&encoded, &decoded))
+	tu.Check(t, "first second", decoded.String())
+}
+
+func TestDecodeFrameErrors(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader([]byte("hello hello hello")), &encoded))
+	valid := encoded.Bytes()
+	corrupt := func(i int) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= 0x01
+		return data
+	}
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: io.ErrUnexpectedEOF},
+		{desc: "MissingIdentifier", data: valid[10:], expected: ErrCorrupt},
+		{desc: "Identifier", data: corrupt(4), expected: ErrCorrupt},
+		{desc: "Checksum", data: corrupt(14), expected: ErrChecksum},
+		{desc: "Reserved", data: append(append([]byte{}, valid...), 0x02, 0, 0, 0), expected: ErrUnsupported},
+		{desc: "Truncated", data: valid[:len(valid)-1], expected: io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, Decode(bytes.NewReader(c.data), ioutil.Discard))
+		})
+	}
+}
@@ -37,13 +37,15 @@ func init() {
 // perfData is used as the context for templates.
 type perfData struct {
 	// Tables as MarkDown formatted strings
-	HuffmanTable, LZ77Table string
+	HuffmanTable, LZ77Table, LZ78Table string
 
 	// Paths to test result files
 	huffmanFile           string
 	lz77File              string
+	lz78File              string
 	huffmanComplexityFile string
 	lz77ComplexityFile    string
+	lz78ComplexityFile    string
 
 	graphDir   string // Directory where generated graphs are stored
 	linkPrefix string // Prefix used for MarkDown links
@@ -56,8 +58,10 @@ func loadPerfData(dataDir, graphDir, linkPrefix string) (*perfData, error) {
 	data := &perfData{
 		huffmanFile:           filepath.Join(dataDir, "huffman-stats.csv"),
 		lz77File:              filepath.Join(dataDir, "lz77-stats.csv"),
+		lz78File:              filepath.Join(dataDir, "lz78-stats.csv"),
 		huffmanComplexityFile: filepath.Join(dataDir, "huffman-complexity-stats.csv"),
 		lz77ComplexityFile:    filepath.Join(dataDir, "lz77-complexity-stats.csv"),
+		lz78ComplexityFile:    filepath.Join(dataDir, "lz78-complexity-stats.csv"),
 		graphDir:              graphDir,
 		linkPrefix:            linkPrefix,
 	}
@@ -69,8 +73,13 @@ func loadPerfData(dataDir, graphDir, linkPrefix string) (*perfData, error) {
 	if err != nil {
 		return nil, err
 	}
+	lz78Data, err := readData(data.lz78File)
+	if err != nil {
+		return nil, err
+	}
 	data.HuffmanTable = formatTable(huffmanData)
 	data.LZ77Table = formatTable(lz77Data)
+	data.LZ78Table = formatTable(lz78Data)
 	return data, nil
 }
 
@@ -93,8 +102,10 @@ set term png size 700,480
 	cmd := exec.Command("gnuplot",
 		"-e", "Huffman='"+d.huffmanFile+"'",
 		"-e", "LZ77='"+d.lz77File+"'",
+		"-e", "LZ78='"+d.lz78File+"'",
 		"-e", "HuffmanComplexity='"+d.huffmanComplexityFile+"'",
 		"-e", "LZ77Complexity='"+d.lz77ComplexityFile+"'",
+		"-e", "LZ78Complexity='"+d.lz78ComplexityFile+"'",
 		"-",
 	)
 	cmd.Stdin = &script
@@ -0,0 +1,263 @@
+// lz77trace shows how the LZ77 encoder parses a file. It renders the parse as
+// an annotated HTML view of the input and prints summary statistics.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/lz77"
+)
+
+// Command line parameters
+var (
+	showHelp bool
+	barWidth int
+)
+
+// init defines command line flags
+func init() {
+	flag.BoolVar(&showHelp, "help", false, "print help message")
+	flag.IntVar(&barWidth, "width", 50, "width of the longest histogram bar")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr,
+			"usage: ", os.Args[0], " [flags] <input file> <output file>\n",
+			"\n",
+			"encode <input file> using LZ77, write an HTML view of the parse\n",
+			"to <output file> and print statistics about the parse\n",
+			"\n",
+		)
+		flag.PrintDefaults()
+	}
+}
+
+// maxMatchLength is the largest length of a reference.
+const maxMatchLength = 15
+
+// unit is a single literal or reference produced by the encoder.
+type unit struct {
+	offset           int64
+	length, distance int // Zero for literals
+}
+
+// trace collects the units produced by the encoder.
+type trace struct {
+	units []unit
+}
+
+func (t *trace) Literal(offset int64, b byte) {
+	t.units = append(t.units, unit{offset: offset})
+}
+
+func (t *trace) Reference(offset int64, length, distance int) {
+	t.units = append(t.units, unit{offset: offset, length: length, distance: distance})
+}
+
+// stats contains summary statistics of a trace.
+type stats struct {
+	inputSize     int
+	literals      int
+	references    int
+	totalDistance int64
+	lengths       [maxMatchLength + 1]int
+}
+
+// computeStats computes statistics of t for an input of inputSize bytes.
+func computeStats(t *trace, inputSize int) *stats {
+	s := &stats{inputSize: inputSize}
+	for _, u := range t.units {
+		if u.length == 0 {
+			s.literals++
+			continue
+		}
+		s.references++
+		s.totalDistance += int64(u.distance)
+		s.lengths[u.length]++
+	}
+	return s
+}
+
+// print writes s to w in human readable form.
+func (s *stats) print(w io.Writer) {
+	literalRatio, averageDistance := 0.0, 0.0
+	if s.inputSize > 0 {
+		literalRatio = 100 * float64(s.literals) / float64(s.inputSize)
+	}
+	if s.references > 0 {
+		averageDistance = float64(s.totalDistance) / float64(s.references)
+	}
+	fmt.Fprintf(w, "Input size:       %d B\n", s.inputSize)
+	fmt.Fprintf(w, "Literals:         %d (%.2f %% of input)\n", s.literals, literalRatio)
+	fmt.Fprintf(w, "References:       %d\n", s.references)
+	fmt.Fprintf(w, "Average distance: %.2f\n", averageDistance)
+	fmt.Fprintln(w, "Match length histogram:")
+	maxCount := 0
+	for _, count := range s.lengths {
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+	for length, count := range s.lengths {
+		if length < 2 {
+			continue
+		}
+		bar := 0
+		if maxCount > 0 {
+			bar = count * barWidth / maxCount
+		}
+		fmt.Fprintf(w, "  %2d %8d %s\n", length, count, strings.Repeat("#", bar))
+	}
+}
+
+// htmlHeader starts the HTML document.
+const htmlHeader = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>LZ77 parse</title>
+<style>
+pre { white-space: pre-wrap; }
+a.ref { color: inherit; text-decoration: none; }
+a.ref:hover { outline: 1px solid black; }
+</style>
+</head>
+<body>
+<p>Literals are shown without highlighting. References are colored by length
+from red (short) to blue (long). Hover over a reference to see its details and
+click it to jump to its source.</p>
+<pre>`
+
+// htmlFooter ends the HTML document.
+const htmlFooter = `</pre>
+</body>
+</html>
+`
+
+// writeHTML writes an HTML view of input annotated with the units in t to w.
+// Unit boundaries and anchors inside a multi-byte character are moved to the
+// start of the character, so that the character is shown whole.
+func writeHTML(w io.Writer, input []byte, t *trace) error {
+	// Anchors are placed at the sources of references.
+	sources := make(map[int64]bool)
+	for _, u := range t.units {
+		if u.length > 0 {
+			sources[runeStart(input, u.offset-int64(u.distance))] = true
+		}
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString(htmlHeader)
+	for i, u := range t.units {
+		start := runeStart(input, u.offset)
+		end := int64(len(input))
+		if i+1 < len(t.units) {
+			end = runeStart(input, t.units[i+1].offset)
+		}
+		if u.length > 0 {
+			hue := 240 * (u.length - 2) / (maxMatchLength - 2)
+			fmt.Fprintf(bw,
+				`<a class="ref" href="#p%d" title="offset %d, length %d, distance %d" style="background: hsl(%d, 80%%, 85%%)">`,
+				runeStart(input, u.offset-int64(u.distance)), u.offset, u.length, u.distance, hue)
+		}
+		writeText(bw, input, start, end, sources)
+		if u.length > 0 {
+			bw.WriteString("</a>")
+		}
+	}
+	bw.WriteString(htmlFooter)
+	return bw.Flush()
+}
+
+// runeStart returns the offset of the first byte of the UTF-8 encoded
+// character containing input[i]. i is returned if input[i] doesn't belong to a
+// valid multi-byte character or i is at the end of input.
+func runeStart(input []byte, i int64) int64 {
+	if i >= int64(len(input)) {
+		return i
+	}
+	for j := i; j >= 0 && j > i-utf8.UTFMax; j-- {
+		if utf8.RuneStart(input[j]) {
+			r, size := utf8.DecodeRune(input[j:])
+			if r != utf8.RuneError && j+int64(size) > i {
+				return j
+			}
+			return i
+		}
+	}
+	return i
+}
+
+// writeText writes input[start:end] as HTML text to w. An anchor is placed at
+// each offset found in anchors.
+func writeText(w *bufio.Writer, input []byte, start, end int64, anchors map[int64]bool) {
+	chunkStart := start
+	for i := start; i <= end; i++ {
+		if i < end && !anchors[i] {
+			continue
+		}
+		w.WriteString(html.EscapeString(printable(input[chunkStart:i])))
+		if i < end {
+			fmt.Fprintf(w, `<span id="p%d"></span>`, i)
+		}
+		chunkStart = i
+	}
+}
+
+// printable converts data to a string replacing invalid UTF-8 and control
+// characters other than whitespace with a middle dot.
+func printable(data []byte) string {
+	var b strings.Builder
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError || (r < 0x20 && r != '\n' && r != '\t') || r == 0x7f {
+			r = '·'
+		}
+		b.WriteRune(r)
+		data = data[size:]
+	}
+	return b.String()
+}
+
+func run() error {
+	if showHelp {
+		flag.Usage()
+		return nil
+	}
+	if flag.NArg() != 2 {
+		return errors.New("expected 2 arguments")
+	}
+	input, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+	t := &trace{}
+	if err := lz77.EncodeTrace(bytes.NewReader(input), ioutil.Discard, t); err != nil {
+		return err
+	}
+	outputFile, err := os.Create(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer outputFile.Close()
+	if err := writeHTML(outputFile, input, t); err != nil {
+		return err
+	}
+	computeStats(t, len(input)).print(os.Stdout)
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLMultiByteCharacters(t *testing.T) {
+	// "ä" is encoded as 0xc3 0xa4. The unit boundary at offset 1 and the
+	// reference source at offset 3 fall inside the characters.
+	input := []byte("ää")
+	tr := &trace{units: []unit{
+		{offset: 0},
+		{offset: 1},
+		{offset: 2, length: 1, distance: 1},
+		{offset: 3, length: 1, distance: 2},
+	}}
+	var output bytes.Buffer
+	if err := writeHTML(&output, input, tr); err != nil {
+		t.Fatal(err)
+	}
+	html := output.String()
+	if strings.Contains(html, "·") {
+		t.Fatalf("a character was split: %s", html)
+	}
+	for _, s := range []string{`ä<a class="ref" href="#p0"`, `<span id="p0"></span>ä`, `>ä</a>`} {
+		if !strings.Contains(html, s) {
+			t.Fatalf("expected %q in %s", s, html)
+		}
+	}
+}
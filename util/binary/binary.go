@@ -0,0 +1,73 @@
+// Package binary implements storing and loading fixed-size unsigned integers
+// in little-endian and big-endian byte order.
+package binary
+
+// PutUint16LE stores n in p in little-endian byte order.
+func PutUint16LE(p []byte, n uint16) {
+	p[0] = byte(n)
+	p[1] = byte(n >> 8)
+}
+
+// GetUint16LE returns the little-endian uint16 value stored in p.
+func GetUint16LE(p []byte) uint16 {
+	return uint16(p[0]) | uint16(p[1])<<8
+}
+
+// PutUint32LE stores n in p in little-endian byte order.
+func PutUint32LE(p []byte, n uint32) {
+	p[0] = byte(n)
+	p[1] = byte(n >> 8)
+	p[2] = byte(n >> 16)
+	p[3] = byte(n >> 24)
+}
+
+// GetUint32LE returns the little-endian uint32 value stored in p.
+func GetUint32LE(p []byte) uint32 {
+	return uint32(p[0]) | uint32(p[1])<<8 | uint32(p[2])<<16 | uint32(p[3])<<24
+}
+
+// PutUint64LE stores n in p in little-endian byte order.
+func PutUint64LE(p []byte, n uint64) {
+	PutUint32LE(p, uint32(n))
+	PutUint32LE(p[4:], uint32(n>>32))
+}
+
+// GetUint64LE returns the little-endian uint64 value stored in p.
+func GetUint64LE(p []byte) uint64 {
+	return uint64(GetUint32LE(p)) | uint64(GetUint32LE(p[4:]))<<32
+}
+
+// PutUint16BE stores n in p in big-endian byte order.
+func PutUint16BE(p []byte, n uint16) {
+	p[0] = byte(n >> 8)
+	p[1] = byte(n)
+}
+
+// GetUint16BE returns the big-endian uint16 value stored in p.
+func GetUint16BE(p []byte) uint16 {
+	return uint16(p[0])<<8 | uint16(p[1])
+}
+
+// PutUint32BE stores n in p in big-endian byte order.
+func PutUint32BE(p []byte, n uint32) {
+	p[0] = byte(n >> 24)
+	p[1] = byte(n >> 16)
+	p[2] = byte(n >> 8)
+	p[3] = byte(n)
+}
+
+// GetUint32BE returns the big-endian uint32 value stored in p.
+func GetUint32BE(p []byte) uint32 {
+	return uint32(p[0])<<24 | uint32(p[1])<<16 | uint32(p[2])<<8 | uint32(p[3])
+}
+
+// PutUint64BE stores n in p in big-endian byte order.
+func PutUint64BE(p []byte, n uint64) {
+	PutUint32BE(p, uint32(n>>32))
+	PutUint32BE(p[4:], uint32(n))
+}
+
+// GetUint64BE returns the big-endian uint64 value stored in p.
+func GetUint64BE(p []byte) uint64 {
+	return uint64(GetUint32BE(p))<<32 | uint64(GetUint32BE(p[4:]))
+}
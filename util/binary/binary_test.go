@@ -0,0 +1,39 @@
+package binary
+
+import (
+	"encoding/binary"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+func TestMatchesStdlib(t *testing.T) {
+	var p, q [8]byte
+	for _, n := range []uint64{0, 1, 0x0102030405060708, 0xfedcba9876543210, 1<<64 - 1} {
+		PutUint16LE(p[:], uint16(n))
+		binary.LittleEndian.PutUint16(q[:], uint16(n))
+		tu.Check(t, q, p)
+		tu.Check(t, uint16(n), GetUint16LE(p[:]))
+		PutUint32LE(p[:], uint32(n))
+		binary.LittleEndian.PutUint32(q[:], uint32(n))
+		tu.Check(t, q, p)
+		tu.Check(t, uint32(n), GetUint32LE(p[:]))
+		PutUint64LE(p[:], n)
+		binary.LittleEndian.PutUint64(q[:], n)
+		tu.Check(t, q, p)
+		tu.Check(t, n, GetUint64LE(p[:]))
+
+		PutUint16BE(p[:], uint16(n))
+		binary.BigEndian.PutUint16(q[:], uint16(n))
+		tu.Check(t, q, p)
+		tu.Check(t, uint16(n), GetUint16BE(p[:]))
+		PutUint32BE(p[:], uint32(n))
+		binary.BigEndian.PutUint32(q[:], uint32(n))
+		tu.Check(t, q, p)
+		tu.Check(t, uint32(n), GetUint32BE(p[:]))
+		PutUint64BE(p[:], n)
+		binary.BigEndian.PutUint64(q[:], n)
+		tu.Check(t, q, p)
+		tu.Check(t, n, GetUint64BE(p[:]))
+	}
+}
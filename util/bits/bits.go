@@ -2,12 +2,20 @@
 package bits
 
 import (
+	"errors"
 	"io"
 
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
 )
 
+// ErrSyntax is returned by ParseList if the string contains characters other
+// than '0' and '1'.
+var ErrSyntax = errors.New("bits: invalid character in bit string")
+
+// minShrinkCap is the smallest capacity in bytes that Shrink frees.
+const minShrinkCap = 64
+
 // List is a growable packed list of bits. The zero value is an empty list ready
 // for use.
 type List struct {
@@ -25,6 +33,23 @@ func NewList(buf []byte) List {
 	}
 }
 
+// ParseList returns a list containing the bits in s, which consists of the
+// characters '0' and '1'.
+func ParseList(s string) (List, error) {
+	l := List{buf: make([]byte, 0, (len(s)+7)/8)}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '0':
+			l.Append(false)
+		case '1':
+			l.Append(true)
+		default:
+			return List{}, ErrSyntax
+		}
+	}
+	return l, nil
+}
+
 // Append appends bit to l.
 func (l *List) Append(bit bool) {
 	if l.len/8 >= len(l.buf) {
@@ -34,6 +59,21 @@ func (l *List) Append(bit bool) {
 	l.Set(l.len-1, bit)
 }
 
+// AppendUint appends the low n bits of value to l starting from the most
+// significant bit. n must be in range [0, 64].
+func (l *List) AppendUint(value uint64, n uint) {
+	for i := n; i > 0; i-- {
+		l.Append(value>>(i-1)&1 != 0)
+	}
+}
+
+// Concat appends the bits in other to l.
+func (l *List) Concat(other *List) {
+	for i, n := 0, other.len; i < n; i++ {
+		l.Append(other.Get(i))
+	}
+}
+
 // Get returns the i'th bit in l. i must be in range [0, l.Len()).
 func (l *List) Get(i int) bool {
 	return (l.buf[i/8]>>(7-i%8))&1 != 0
@@ -54,9 +94,93 @@ func (l *List) Len() int {
 }
 
 // Shrink shirnks the length of l by n bits. n must be in range [0, l.Len()].
-// Shrink only reduces the length of l. No memory is freed.
+// If l uses less than half of its memory afterwards, the memory is reallocated
+// to fit the remaining bits.
 func (l *List) Shrink(n int) {
 	l.len -= n
+	size := (l.len + 7) / 8
+	l.buf = l.buf[:size]
+	if cap(l.buf) >= minShrinkCap && cap(l.buf) > 2*size {
+		buf := make([]byte, size)
+		slices.CopyBytes(buf, l.buf)
+		l.buf = buf
+	}
+}
+
+// Slice returns a new list containing the bits of l in range [i, j).
+// 0 <= i <= j <= l.Len() must hold.
+func (l *List) Slice(i, j int) List {
+	sliced := List{buf: make([]byte, 0, (j-i+7)/8)}
+	for ; i < j; i++ {
+		sliced.Append(l.Get(i))
+	}
+	return sliced
+}
+
+// Equal reports whether l and other contain the same bits.
+func (l *List) Equal(other *List) bool {
+	return l.Compare(other) == 0
+}
+
+// Compare compares l and other lexicographically bit by bit. A list that is a
+// prefix of another list is less than it. The result is 0 if l == other, -1
+// if l < other and +1 if l > other.
+func (l *List) Compare(other *List) int {
+	n := l.len
+	if other.len < n {
+		n = other.len
+	}
+	for i := 0; i < n; i++ {
+		if a, b := l.Get(i), other.Get(i); a != b {
+			if b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case l.len < other.len:
+		return -1
+	case l.len > other.len:
+		return 1
+	}
+	return 0
+}
+
+// Bytes returns the bits of l packed into bytes starting from the most
+// significant bit. The last byte is padded with zero bits. The returned slice
+// is a copy.
+func (l *List) Bytes() []byte {
+	b := make([]byte, (l.len+7)/8)
+	slices.CopyBytes(b, l.buf)
+	if rest := l.len % 8; rest > 0 {
+		b[len(b)-1] &= 0xff << uint(8-rest)
+	}
+	return b
+}
+
+// Uint64 returns the bits of l as an integer with the first bit as the most
+// significant one. l.Len() must be at most 64.
+func (l *List) Uint64() uint64 {
+	var n uint64
+	for i := 0; i < l.len; i++ {
+		n <<= 1
+		if l.Get(i) {
+			n |= 1
+		}
+	}
+	return n
+}
+
+// Reverse reverses the order of the bits in l. This converts a code stored
+// starting from its most significant bit to one stored starting from its least
+// significant bit and vice versa.
+func (l *List) Reverse() {
+	for i, j := 0, l.len-1; i < j; i, j = i+1, j-1 {
+		a, b := l.Get(i), l.Get(j)
+		l.Set(i, b)
+		l.Set(j, a)
+	}
 }
 
 // Copy returns a deep copy of l.
@@ -82,195 +206,498 @@ func (l *List) String() string {
 	return string(s)
 }
 
+// Order specifies the order in which bits are packed into bytes.
+type Order int
+
+const (
+	// MSB packs bits starting from the most significant bit of each byte.
+	// Multi-bit values are written starting from their most significant bit.
+	MSB Order = iota
+	// LSB packs bits starting from the least significant bit of each byte.
+	// Multi-bit values are written starting from their least significant
+	// bit. This order is used by DEFLATE and GIF.
+	LSB
+)
+
 // Writer is used to write individual bits into an io.Writer.
 //
 // All writes to a Writer are buffered. Calling Flush writes all buffered data
 // to the underlying io.Writer along with possible additional trailing zero bits
 // to round the data to full bytes.
 type Writer struct {
-	w   *bufio.Writer
-	i   byte
-	buf byte
+	w     *bufio.Writer
+	order Order
+	// acc holds the n bits written but not yet passed to w. In MSB order the
+	// latest bit is the least significant one and in LSB order the most
+	// significant one. n is always less than 32 between calls.
+	acc uint64
+	n   uint
+	// written is the number of bits written to the Writer.
+	written int64
 }
 
-// NewWriter returns a bitWriter that writes to w.
+// NewWriter returns a bitWriter that writes to w in MSB order.
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{w: bufio.NewWriter(w)}
+	return NewWriterOrder(w, MSB)
+}
+
+// NewWriterOrder returns a bitWriter that writes to w in order.
+func NewWriterOrder(w io.Writer, order Order) *Writer {
+	return &Writer{w: bufio.NewWriter(w), order: order}
+}
+
+// NewWriterSize returns a bitWriter that writes to w in order using a buffer
+// of size bytes.
+func NewWriterSize(w io.Writer, order Order, size int) *Writer {
+	return &Writer{w: bufio.NewWriterSize(w, size), order: order}
+}
+
+// Reset discards all unflushed data and resets w to write to dst in the same
+// order. The buffer is reused, so Reset allocates nothing.
+func (w *Writer) Reset(dst io.Writer) {
+	w.w.Reset(dst)
+	w.acc = 0
+	w.n = 0
+	w.written = 0
+}
+
+// WriteBits writes the low n bits of value to w. n must be in range [0, 64].
+func (w *Writer) WriteBits(value uint64, n uint) error {
+	if n > 32 {
+		// The accumulator can't fit all bits at once.
+		first, shift := n-32, uint(32)
+		if w.order == LSB {
+			first, shift = 32, 0
+		}
+		if err := w.WriteBits(value>>shift, first); err != nil {
+			return err
+		}
+		value >>= 32 - shift
+		n -= first
+	}
+	value &= 1<<n - 1
+	if w.order == LSB {
+		w.acc |= value << w.n
+	} else {
+		w.acc = w.acc<<n | value
+	}
+	w.n += n
+	w.written += int64(n)
+	if w.n >= 32 {
+		return w.writeFull()
+	}
+	return nil
+}
+
+// writeFull passes all full bytes in the accumulator to the underlying writer.
+func (w *Writer) writeFull() error {
+	var buf [8]byte
+	count := 0
+	for ; w.n >= 8; count++ {
+		w.n -= 8
+		if w.order == LSB {
+			buf[count] = byte(w.acc)
+			w.acc >>= 8
+		} else {
+			buf[count] = byte(w.acc >> w.n)
+		}
+	}
+	w.acc &= 1<<w.n - 1
+	_, err := w.w.Write(buf[:count])
+	return err
 }
 
 // WriteBit writes a single bit to w.
 func (w *Writer) WriteBit(b bool) error {
+	var bit uint64
 	if b {
-		w.buf |= 1 << (7 - w.i)
+		bit = 1
 	}
-	if w.i == 7 {
-		if err := w.w.WriteByte(w.buf); err != nil {
-			return err
-		}
-		w.i = 0
-		w.buf = 0
+	if w.order == LSB {
+		w.acc |= bit << w.n
 	} else {
-		w.i++
+		w.acc = w.acc<<1 | bit
+	}
+	w.written++
+	if w.n++; w.n >= 32 {
+		return w.writeFull()
 	}
 	return nil
 }
 
-// WriteBits writes all bits in bits to w.
-func (w *Writer) WriteBits(bits *List) error {
-	for i := 0; i < bits.Len(); i++ {
-		if err := w.WriteBit(bits.Get(i)); err != nil {
+// WriteList writes all bits in bits to w in the order they are stored in the
+// list.
+func (w *Writer) WriteList(bits *List) error {
+	full := bits.Len() / 8
+	if w.order == LSB {
+		for _, b := range bits.buf[:full] {
+			if err := w.WriteBits(uint64(Reverse8(b)), 8); err != nil {
+				return err
+			}
+		}
+		if rest := uint(bits.Len() % 8); rest > 0 {
+			return w.WriteBits(uint64(Reverse8(bits.buf[full])), rest)
+		}
+		return nil
+	}
+	if w.n%8 == 0 {
+		if err := w.writeAligned(bits.buf[:full]); err != nil {
 			return err
 		}
+	} else {
+		for _, b := range bits.buf[:full] {
+			if err := w.WriteBits(uint64(b), 8); err != nil {
+				return err
+			}
+		}
+	}
+	if rest := uint(bits.Len() % 8); rest > 0 {
+		return w.WriteBits(uint64(bits.buf[full]>>(8-rest)), rest)
 	}
 	return nil
 }
 
 // WriteByte writes n to the writer.
 func (w *Writer) WriteByte(n byte) error {
-	bits := NewList([]byte{n})
-	return w.WriteBits(&bits)
+	return w.WriteBits(uint64(n), 8)
 }
 
 // WriteInt64 writes n to the writer using little-endian byte order.
 func (w *Writer) WriteInt64(n int64) error {
-	bits := NewList([]byte{
-		byte(uint64(n)),
-		byte(uint64(n) >> 8),
-		byte(uint64(n) >> 16),
-		byte(uint64(n) >> 24),
-		byte(uint64(n) >> 32),
-		byte(uint64(n) >> 40),
-		byte(uint64(n) >> 48),
-		byte(uint64(n) >> 56),
-	})
-	return w.WriteBits(&bits)
+	if w.order == LSB {
+		return w.WriteBits(uint64(n), 64)
+	}
+	return w.WriteBits(ReverseBytes64(uint64(n)), 64)
 }
 
 // WriteUint16 writes n to the writer using little-endian byte order.
 func (w *Writer) WriteUint16(n uint16) error {
-	bits := NewList([]byte{
-		byte(n),
-		byte(n >> 8),
-	})
-	return w.WriteBits(&bits)
+	if w.order == LSB {
+		return w.WriteBits(uint64(n), 16)
+	}
+	return w.WriteBits(uint64(ReverseBytes16(n)), 16)
+}
+
+// BitsWritten returns the number of bits written to w including padding.
+func (w *Writer) BitsWritten() int64 {
+	return w.written
+}
+
+// AlignToByte writes zero bits up to the next byte boundary.
+func (w *Writer) AlignToByte() error {
+	return w.WriteBits(0, (8-w.n%8)%8)
+}
+
+// WriteAlignedBytes writes zero bits up to the next byte boundary followed by
+// the bytes in p. The bytes are written as is regardless of the bit order.
+func (w *Writer) WriteAlignedBytes(p []byte) error {
+	if err := w.AlignToByte(); err != nil {
+		return err
+	}
+	return w.writeAligned(p)
+}
+
+// writeAligned writes p to the underlying writer after the bits in the
+// accumulator. The accumulator must hold full bytes.
+func (w *Writer) writeAligned(p []byte) error {
+	if err := w.writeFull(); err != nil {
+		return err
+	}
+	w.written += 8 * int64(len(p))
+	_, err := w.w.Write(p)
+	return err
 }
 
 // Flush writes all buffered data to the underlying writer along with possible
 // trailing zero bits to pad the result to full bytes.
 func (w *Writer) Flush() error {
-	if w.i > 0 {
-		if err := w.w.WriteByte(w.buf); err != nil {
-			return err
-		}
-		w.i = 0
-		w.buf = 0
+	if err := w.AlignToByte(); err != nil {
+		return err
+	}
+	if err := w.writeFull(); err != nil {
+		return err
 	}
 	return w.w.Flush()
 }
 
 // Reader is used to read individual bits from an io.Reader.
+//
+// The read methods return io.EOF only if the input ends at a byte boundary
+// before the first bit of the value being read. If the input ends in the
+// middle of a value, io.ErrUnexpectedEOF is returned instead. Bits after the
+// last value of a stream padded to full bytes are read as any other bits.
 type Reader struct {
-	w   *bufio.Reader
-	i   byte
-	buf byte
+	r     *bufio.Reader
+	order Order
+	// acc holds the n bits read from r but not yet returned. In MSB order the
+	// next bit is the most significant one and in LSB order the least
+	// significant one.
+	acc uint64
+	n   uint
+	// read is the number of bits consumed from the Reader.
+	read int64
+	// shared is set if r belongs to the caller. Then only the bytes holding
+	// the bits read are consumed from it.
+	shared bool
 }
 
-// NewReader returns a bitReader that reads from r.
+// NewReader returns a bitReader that reads from r in MSB order.
 func NewReader(r io.Reader) *Reader {
-	return &Reader{
-		w: bufio.NewReader(r),
-		i: 8,
-	}
+	return NewReaderOrder(r, MSB)
 }
 
-// ReadBit reads a single bit from r.
-func (r *Reader) ReadBit() (bit bool, err error) {
-	if r.i == 8 {
-		r.buf, err = r.w.ReadByte()
+// NewReaderOrder returns a bitReader that reads from r in order.
+func NewReaderOrder(r io.Reader, order Order) *Reader {
+	return &Reader{r: bufio.NewReader(r), order: order}
+}
+
+// NewReaderSize returns a bitReader that reads from r in order using a buffer
+// of size bytes.
+func NewReaderSize(r io.Reader, order Order, size int) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, size), order: order}
+}
+
+// NewReaderBuffered returns a bitReader that reads from r in order using the
+// buffer of r. The Reader consumes only the bytes holding the bits read from
+// it, so data following a bit stream can be read from r after aligning to a
+// byte boundary. Reset resets r.
+func NewReaderBuffered(r *bufio.Reader, order Order) *Reader {
+	return &Reader{r: r, order: order, shared: true}
+}
+
+// Reset discards all buffered data and resets r to read from src in the same
+// order. The buffer is reused, so Reset allocates nothing.
+func (r *Reader) Reset(src io.Reader) {
+	r.r.Reset(src)
+	r.acc = 0
+	r.n = 0
+	r.read = 0
+}
+
+// ReadBits reads n bits from r and returns them as the low bits of the result.
+// n must be in range [0, 64]. If an error occurs, no bits are consumed.
+func (r *Reader) ReadBits(n uint) (uint64, error) {
+	if n > 56 {
+		// The accumulator can't fit all bits at once.
+		first, rest := n-32, uint(32)
+		if r.order == LSB {
+			first, rest = 32, n-32
+		}
+		if err := r.fill(first); err != nil {
+			return 0, r.endError(err)
+		}
+		a, _ := r.ReadBits(first)
+		b, err := r.ReadBits(rest)
 		if err != nil {
-			return false, err
+			r.unread(a, first)
+			return 0, r.endError(err)
 		}
-		r.i = 0
+		if r.order == LSB {
+			return b<<32 | a, nil
+		}
+		return a<<32 | b, nil
 	}
-	bit = (r.buf>>(7-r.i))&1 != 0
-	r.i++
-	return bit, nil
-}
-
-func (r *Reader) readBitPanicing() byte {
-	bit, err := r.ReadBit()
-	if err != nil {
-		panic(readBitErr(err))
+	if err := r.fill(n); err != nil {
+		return 0, r.endError(err)
 	}
-	if bit {
-		return 1
+	r.n -= n
+	r.read += int64(n)
+	var value uint64
+	if r.order == LSB {
+		value = r.acc & (1<<n - 1)
+		r.acc >>= n
+	} else {
+		value = r.acc >> r.n & (1<<n - 1)
+		r.acc &= 1<<r.n - 1
 	}
-	return 0
+	return value, nil
 }
 
-// ReadByte reads a byte from r.
-func (r *Reader) ReadByte() (byt byte, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(readBitErr); ok {
-				err = e
+// fill reads bytes to the accumulator until it holds at least n bits. n must
+// be at most 56.
+func (r *Reader) fill(n uint) error {
+	for r.n < n {
+		want := int(64-r.n) / 8
+		if r.shared {
+			want = int(n-r.n+7) / 8
+		}
+		buf, err := r.r.Peek(want)
+		if len(buf) > want {
+			buf = buf[:want]
+		}
+		for _, b := range buf {
+			if r.order == LSB {
+				r.acc |= uint64(b) << r.n
 			} else {
-				panic(r)
+				r.acc = r.acc<<8 | uint64(b)
 			}
+			r.n += 8
 		}
-	}()
-	return r.readBytePanicking(), nil
+		r.r.Discard(len(buf))
+		if err != nil && r.n < n {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *Reader) readBytePanicking() byte {
-	return r.readBitPanicing()<<7 |
-		r.readBitPanicing()<<6 |
-		r.readBitPanicing()<<5 |
-		r.readBitPanicing()<<4 |
-		r.readBitPanicing()<<3 |
-		r.readBitPanicing()<<2 |
-		r.readBitPanicing()<<1 |
-		r.readBitPanicing()
+// endError converts io.EOF to io.ErrUnexpectedEOF if the bits of a partially
+// read value are left in the accumulator.
+func (r *Reader) endError(err error) error {
+	if err == io.EOF && r.n > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return err
 }
 
-// ReadInt64 reads an int64 value in little-endian byte order.
-func (r *Reader) ReadInt64() (n int64, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(readBitErr); ok {
-				err = e
-			} else {
-				panic(r)
-			}
+// unread returns the n bits of value to the front of the accumulator. The
+// accumulator must have room for them.
+func (r *Reader) unread(value uint64, n uint) {
+	if r.order == LSB {
+		r.acc = r.acc<<n | value
+	} else {
+		r.acc |= value << r.n
+	}
+	r.n += n
+	r.read -= int64(n)
+}
+
+// ReadBit reads a single bit from r.
+func (r *Reader) ReadBit() (bool, error) {
+	if r.n == 0 {
+		if err := r.fill(1); err != nil {
+			return false, err
 		}
-	}()
-	x := uint64(r.readBytePanicking()) |
-		uint64(r.readBytePanicking())<<8 |
-		uint64(r.readBytePanicking())<<16 |
-		uint64(r.readBytePanicking())<<24 |
-		uint64(r.readBytePanicking())<<32 |
-		uint64(r.readBytePanicking())<<40 |
-		uint64(r.readBytePanicking())<<48 |
-		uint64(r.readBytePanicking())<<56
-	return int64(x), nil
+	}
+	r.n--
+	r.read++
+	var bit uint64
+	if r.order == LSB {
+		bit = r.acc & 1
+		r.acc >>= 1
+	} else {
+		bit = r.acc >> r.n & 1
+		r.acc &= 1<<r.n - 1
+	}
+	return bit != 0, nil
+}
+
+// ReadByte reads a byte from r.
+func (r *Reader) ReadByte() (byte, error) {
+	b, err := r.ReadBits(8)
+	return byte(b), err
+}
+
+// ReadInt64 reads an int64 value in little-endian byte order.
+func (r *Reader) ReadInt64() (int64, error) {
+	n, err := r.ReadBits(64)
+	if r.order == LSB {
+		return int64(n), err
+	}
+	return int64(ReverseBytes64(n)), err
 }
 
 // ReadUint16 reads an uint16 value in little-endian byte order.
-func (r *Reader) ReadUint16() (n uint16, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if e, ok := r.(readBitErr); ok {
-				err = e
-			} else {
-				panic(r)
+func (r *Reader) ReadUint16() (uint16, error) {
+	n, err := r.ReadBits(16)
+	if r.order == LSB {
+		return uint16(n), err
+	}
+	return ReverseBytes16(uint16(n)), err
+}
+
+// BitsRead returns the number of bits consumed from r.
+func (r *Reader) BitsRead() int64 {
+	return r.read
+}
+
+// PeekBits returns the next n bits like ReadBits without consuming them. n must
+// be in range [0, 56].
+func (r *Reader) PeekBits(n uint) (uint64, error) {
+	if err := r.fill(n); err != nil {
+		return 0, r.endError(err)
+	}
+	if r.order == LSB {
+		return r.acc & (1<<n - 1), nil
+	}
+	return r.acc >> (r.n - n) & (1<<n - 1), nil
+}
+
+// SkipBits consumes the next n bits. If an error occurs, some of the bits may
+// have been consumed.
+func (r *Reader) SkipBits(n int64) error {
+	for start := r.read; n > 0; {
+		chunk := uint(56)
+		if n < 56 {
+			chunk = uint(n)
+		}
+		if _, err := r.ReadBits(chunk); err != nil {
+			if err == io.EOF && r.read != start {
+				err = io.ErrUnexpectedEOF
 			}
+			return err
 		}
-	}()
-	x := uint16(r.readBytePanicking()) |
-		uint16(r.readBytePanicking())<<8
-	return x, nil
+		n -= int64(chunk)
+	}
+	return nil
+}
+
+// AlignToByte discards the remaining bits of the current byte.
+func (r *Reader) AlignToByte() {
+	// The bits of a partially consumed byte are always in the accumulator.
+	r.ReadBits(uint(r.n % 8))
+}
+
+// ReadAlignedBytes discards the remaining bits of the current byte and reads
+// len(p) bytes into p as is regardless of the bit order. It returns the number
+// of bytes read. If fewer than len(p) bytes are read, an error is returned.
+func (r *Reader) ReadAlignedBytes(p []byte) (int, error) {
+	r.AlignToByte()
+	total := 0
+	for ; total < len(p) && r.n > 0; total++ {
+		b, _ := r.ReadBits(8)
+		p[total] = byte(b)
+	}
+	n, err := r.r.Read(p[total:])
+	total += n
+	r.read += 8 * int64(n)
+	if err == io.EOF && total > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return total, err
 }
 
-// readBitErr is used to differentiate panics caused by panicking variants of
-// read and write methods on bitReader and bitWriter.
-type readBitErr error
+// Len64 returns the minimum number of bits needed to represent n. The result
+// is 0 for n == 0.
+func Len64(n uint64) int {
+	length := 0
+	for ; n >= 1<<8; n >>= 8 {
+		length += 8
+	}
+	for ; n != 0; n >>= 1 {
+		length++
+	}
+	return length
+}
+
+// Reverse8 returns b with its bits in reverse order.
+func Reverse8(b byte) byte {
+	b = b>>4 | b<<4
+	b = (b&0xcc)>>2 | (b&0x33)<<2
+	return (b&0xaa)>>1 | (b&0x55)<<1
+}
+
+// ReverseBytes16 returns n with its bytes in reverse order.
+func ReverseBytes16(n uint16) uint16 {
+	return n>>8 | n<<8
+}
+
+// ReverseBytes64 returns n with its bytes in reverse order.
+func ReverseBytes64(n uint64) uint64 {
+	var reversed uint64
+	for i := 0; i < 8; i++ {
+		reversed = reversed<<8 | n&0xff
+		n >>= 8
+	}
+	return reversed
+}
@@ -3,24 +3,19 @@ package bits
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	mathbits "math/bits"
+	"math/rand"
 	"testing"
 	"unsafe"
 
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
 	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
 )
 
-func (l *List) Equals(other List) bool {
-	if l.len != other.len {
-		return false
-	}
-	for i := 0; i < l.len; i++ {
-		if l.Get(i) != other.Get(i) {
-			return false
-		}
-	}
-	return true
-}
-
 func TestBitReaderReadBit(t *testing.T) {
 	input := []byte{0b00010110, 0b11010010, 0b11010010}
 	output := []byte{
@@ -81,6 +76,295 @@ func TestBitReaderReadUint16(t *testing.T) {
 	tu.ExpectEOF(t, err)
 }
 
+func TestBitReaderReadBits(t *testing.T) {
+	input := []byte{0b00010110, 0b11010010, 0b11010010, 0xff, 0, 0xff, 0, 0xff, 0, 0xff}
+	r := NewReader(bytes.NewBuffer(input))
+	n, err := r.ReadBits(3)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0b000), n)
+	n, err = r.ReadBits(7)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0b1011011), n)
+	n, err = r.ReadBits(0)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0), n)
+	n, err = r.ReadBits(64)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0x4b4bfc03fc03fc03), n)
+	// Failed reads don't consume any bits.
+	_, err = r.ReadBits(7)
+	tu.Check(t, io.ErrUnexpectedEOF, err)
+	n, err = r.ReadBits(6)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0b111111), n)
+}
+
+func TestBitReaderReadBitsFailedLongRead(t *testing.T) {
+	input := []byte{1, 2, 3, 4, 5, 6, 7}
+	r := NewReader(bytes.NewBuffer(input))
+	_, err := r.ReadBits(60)
+	tu.Check(t, io.ErrUnexpectedEOF, err)
+	n, err := r.ReadBits(56)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0x01020304050607), n)
+}
+
+func TestBitWriterWriteBits(t *testing.T) {
+	var output bytes.Buffer
+	w := NewWriter(&output)
+	tu.ExpectNil(t, w.WriteBits(0b000, 3))
+	// Bits above n are ignored.
+	tu.ExpectNil(t, w.WriteBits(0x80|0b1011011, 7))
+	tu.ExpectNil(t, w.WriteBits(0, 0))
+	tu.ExpectNil(t, w.WriteBits(0x4b4bfc03fc03fc03, 64))
+	tu.ExpectNil(t, w.WriteBits(1, 1))
+	tu.ExpectNil(t, w.Flush())
+	tu.Check(t, "16d2d2ff00ff00ff00e0", hex.EncodeToString(output.Bytes()))
+}
+
+func TestBitsRoundTrip(t *testing.T) {
+	for _, order := range []Order{MSB, LSB} {
+		rng := rand.New(rand.NewSource(1))
+		values := make([]uint64, 10000)
+		widths := make([]uint, len(values))
+		var output bytes.Buffer
+		w := NewWriterOrder(&output, order)
+		for i := range values {
+			widths[i] = uint(rng.Intn(65))
+			values[i] = rng.Uint64() & (1<<widths[i] - 1)
+			if widths[i] == 64 {
+				values[i] = rng.Uint64()
+			}
+			tu.ExpectNil(t, w.WriteBits(values[i], widths[i]))
+		}
+		tu.ExpectNil(t, w.Flush())
+		r := NewReaderOrder(&output, order)
+		for i := range values {
+			n, err := r.ReadBits(widths[i])
+			tu.ExpectNil(t, err)
+			if n != values[i] {
+				t.Fatalf("order %d: expected value %d to be %x, found %x", order, i, values[i], n)
+			}
+		}
+	}
+}
+
+func TestLSBWriter(t *testing.T) {
+	var output bytes.Buffer
+	w := NewWriterOrder(&output, LSB)
+	tu.ExpectNil(t, w.WriteBit(true))
+	tu.ExpectNil(t, w.WriteBits(0b10, 2))
+	tu.ExpectNil(t, w.WriteBits(0b10110, 5))
+	tu.ExpectNil(t, w.WriteBits(0x0123456789abcdef, 64))
+	tu.ExpectNil(t, w.WriteUint16(0x1234))
+	list := NewList([]byte{0b11000000})
+	list.Shrink(5)
+	tu.ExpectNil(t, w.WriteList(&list))
+	tu.ExpectNil(t, w.Flush())
+	tu.Check(t, "b5efcdab8967452301341203", hex.EncodeToString(output.Bytes()))
+}
+
+func TestLSBReader(t *testing.T) {
+	input, _ := hex.DecodeString("b5efcdab8967452301341203")
+	r := NewReaderOrder(bytes.NewReader(input), LSB)
+	bit, err := r.ReadBit()
+	tu.ExpectNil(t, err)
+	tu.Check(t, true, bit)
+	n, err := r.ReadBits(2)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0b10), n)
+	n, err = r.ReadBits(5)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0b10110), n)
+	i, err := r.ReadInt64()
+	tu.ExpectNil(t, err)
+	tu.Check(t, int64(0x0123456789abcdef), i)
+	u, err := r.ReadUint16()
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint16(0x1234), u)
+	// Failed reads don't consume any bits.
+	_, err = r.ReadBits(60)
+	tu.Check(t, io.ErrUnexpectedEOF, err)
+	n, err = r.ReadBits(3)
+	tu.ExpectNil(t, err)
+	tu.Check(t, uint64(0b011), n)
+}
+
+func TestBitsPositionAndAlignment(t *testing.T) {
+	for _, order := range []Order{MSB, LSB} {
+		var output bytes.Buffer
+		w := NewWriterOrder(&output, order)
+		tu.ExpectNil(t, w.WriteBits(0b101, 3))
+		tu.Check(t, int64(3), w.BitsWritten())
+		tu.ExpectNil(t, w.WriteAlignedBytes([]byte("abc")))
+		tu.Check(t, int64(32), w.BitsWritten())
+		tu.ExpectNil(t, w.WriteBit(true))
+		tu.ExpectNil(t, w.AlignToByte())
+		tu.Check(t, int64(40), w.BitsWritten())
+		tu.ExpectNil(t, w.WriteBits(0x1234, 16))
+		tu.ExpectNil(t, w.WriteBits(0b11, 2))
+		tu.ExpectNil(t, w.Flush())
+		tu.Check(t, int64(64), w.BitsWritten())
+		tu.Check(t, 8, output.Len())
+		tu.Check(t, "abc", output.String()[1:4])
+
+		r := NewReaderOrder(&output, order)
+		n, err := r.PeekBits(3)
+		tu.ExpectNil(t, err)
+		tu.Check(t, uint64(0b101), n)
+		tu.Check(t, int64(0), r.BitsRead())
+		tu.ExpectNil(t, r.SkipBits(3))
+		tu.Check(t, int64(3), r.BitsRead())
+		buf := make([]byte, 3)
+		read, err := r.ReadAlignedBytes(buf)
+		tu.ExpectNil(t, err)
+		tu.Check(t, 3, read)
+		tu.Check(t, "abc", string(buf))
+		tu.Check(t, int64(32), r.BitsRead())
+		bit, err := r.ReadBit()
+		tu.ExpectNil(t, err)
+		tu.Check(t, true, bit)
+		r.AlignToByte()
+		tu.Check(t, int64(40), r.BitsRead())
+		n, err = r.PeekBits(16)
+		tu.ExpectNil(t, err)
+		tu.Check(t, uint64(0x1234), n)
+		tu.ExpectNil(t, r.SkipBits(18))
+		tu.Check(t, int64(58), r.BitsRead())
+		_, err = r.PeekBits(7)
+		tu.Check(t, io.ErrUnexpectedEOF, err)
+		_, err = r.ReadAlignedBytes(buf)
+		tu.ExpectEOF(t, err)
+		tu.Check(t, int64(64), r.BitsRead())
+	}
+}
+
+func TestReadAlignedBytesLong(t *testing.T) {
+	input := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(input)
+	r := NewReader(bytes.NewReader(input))
+	b, err := r.ReadByte()
+	tu.ExpectNil(t, err)
+	tu.Check(t, input[0], b)
+	// The reader may have buffered some bytes in the accumulator.
+	buf := make([]byte, len(input)-1)
+	n, err := r.ReadAlignedBytes(buf)
+	tu.ExpectNil(t, err)
+	tu.Check(t, len(buf), n)
+	if !bytes.Equal(input[1:], buf) {
+		t.Fatal("read bytes differ from the input")
+	}
+}
+
+// errReader returns err after the data in r.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		err = r.err
+	}
+	return n, err
+}
+
+func TestBitReaderEndOfInput(t *testing.T) {
+	input := []byte{0x12, 0x34}
+	readErr := errors.New("read error")
+	cases := []struct {
+		desc     string
+		read     func(r *Reader) error
+		expected error
+	}{
+		{
+			desc: "Boundary",
+			read: func(r *Reader) error {
+				r.ReadBits(16)
+				_, err := r.ReadBit()
+				return err
+			},
+			expected: io.EOF,
+		},
+		{
+			desc: "BoundaryLong",
+			read: func(r *Reader) error {
+				r.ReadUint16()
+				_, err := r.ReadInt64()
+				return err
+			},
+			expected: io.EOF,
+		},
+		{
+			desc: "MiddleOfValue",
+			read: func(r *Reader) error {
+				r.ReadBits(12)
+				_, err := r.ReadByte()
+				return err
+			},
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			desc: "MiddleOfLongValue",
+			read: func(r *Reader) error {
+				_, err := r.ReadInt64()
+				return err
+			},
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			desc: "MiddleOfSplitValue",
+			read: func(r *Reader) error {
+				_, err := r.ReadBits(60)
+				return err
+			},
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			desc:     "Skip",
+			read:     func(r *Reader) error { return r.SkipBits(17) },
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			desc: "AlignedBytes",
+			read: func(r *Reader) error {
+				_, err := r.ReadAlignedBytes(make([]byte, 3))
+				return err
+			},
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			desc: "Error",
+			read: func(r *Reader) error {
+				r.ReadBits(8)
+				_, err := r.ReadBits(9)
+				return err
+			},
+			expected: readErr,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			var src io.Reader = bytes.NewReader(input)
+			if c.expected == readErr {
+				src = &errReader{src, readErr}
+			}
+			tu.Check(t, c.expected, c.read(NewReader(src)))
+		})
+	}
+}
+
+func TestBitListReverse(t *testing.T) {
+	bits := NewList([]byte{0b00010110, 0b11000000})
+	bits.Shrink(5)
+	bits.Reverse()
+	tu.Check(t, "01101101000", bits.String())
+	bits.Shrink(10)
+	bits.Reverse()
+	tu.Check(t, "0", bits.String())
+}
+
 func TestBitWriterWriteBit(t *testing.T) {
 	input := []byte{
 		0, 0, 0, 1, 0, 1, 1, 0,
@@ -104,12 +388,12 @@ func TestBitWriterWriteBit(t *testing.T) {
 	}
 }
 
-func TestBitWriterWriteBits(t *testing.T) {
+func TestBitWriterWriteList(t *testing.T) {
 	correctOutput := []byte{0b00010110, 0b11010010, 0b11010010}
 	input := NewList(correctOutput)
 	var output bytes.Buffer
 	w := NewWriter(&output)
-	tu.ExpectNil(t, w.WriteBits(&input))
+	tu.ExpectNil(t, w.WriteList(&input))
 	tu.ExpectNil(t, w.Flush())
 	t.Log(output.Bytes())
 	tu.Check(t, len(correctOutput), output.Len())
@@ -153,3 +437,228 @@ func TestBitListSet(t *testing.T) {
 	bits.Set(11, true)
 	tu.Check(t, "0000100000010000", bits.String())
 }
+
+func BenchmarkWriteBits(b *testing.B) {
+	w := NewWriter(ioutil.Discard)
+	b.SetBytes(1 << 10)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1<<10; j++ {
+			w.WriteBits(uint64(j), 5)
+			w.WriteBits(uint64(j), 3)
+		}
+	}
+}
+
+func BenchmarkWriteBit(b *testing.B) {
+	w := NewWriter(ioutil.Discard)
+	b.SetBytes(1 << 10)
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 8<<10; j++ {
+			w.WriteBit(j&1 != 0)
+		}
+	}
+}
+
+func BenchmarkReadBits(b *testing.B) {
+	input := make([]byte, 1<<10)
+	rand.New(rand.NewSource(1)).Read(input)
+	src := bytes.NewReader(input)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		src.Reset(input)
+		r := NewReader(src)
+		for {
+			// The input doesn't divide into 5-bit values, so it ends
+			// with io.ErrUnexpectedEOF.
+			if _, err := r.ReadBits(5); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkReadBit(b *testing.B) {
+	input := make([]byte, 1<<10)
+	rand.New(rand.NewSource(1)).Read(input)
+	src := bytes.NewReader(input)
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		src.Reset(input)
+		r := NewReader(src)
+		for {
+			if _, err := r.ReadBit(); err == io.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestParseList(t *testing.T) {
+	for _, s := range []string{"", "0", "1", "0110", "101100111", "0000000011111111"} {
+		bits, err := ParseList(s)
+		tu.ExpectNil(t, err)
+		tu.Check(t, s, bits.String())
+	}
+	_, err := ParseList("0120")
+	tu.Check(t, ErrSyntax, err)
+}
+
+func TestBitListAppendUintAndConcat(t *testing.T) {
+	var bits List
+	bits.AppendUint(0b101, 3)
+	bits.AppendUint(0xff, 0)
+	bits.AppendUint(0x1ff00, 9)
+	tu.Check(t, "101100000000", bits.String())
+	tu.Check(t, uint64(0b101100000000), bits.Uint64())
+	other, _ := ParseList("0111")
+	bits.Concat(&other)
+	bits.Concat(&List{})
+	tu.Check(t, "1011000000000111", bits.String())
+	bits.AppendUint(1<<63|1, 64)
+	tu.Check(t, 80, bits.Len())
+	sliced := bits.Slice(16, 80)
+	tu.Check(t, uint64(1<<63|1), sliced.Uint64())
+}
+
+func TestBitListSlice(t *testing.T) {
+	bits, _ := ParseList("0110100111")
+	cases := []struct {
+		i, j     int
+		expected string
+	}{
+		{i: 0, j: 0, expected: ""},
+		{i: 0, j: 10, expected: "0110100111"},
+		{i: 3, j: 7, expected: "0100"},
+		{i: 9, j: 10, expected: "1"},
+	}
+	for _, c := range cases {
+		sliced := bits.Slice(c.i, c.j)
+		tu.Check(t, c.expected, sliced.String())
+	}
+}
+
+func TestBitListCompare(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "0101", b: "0101", expected: 0},
+		{a: "", b: "0", expected: -1},
+		{a: "01", b: "010", expected: -1},
+		{a: "0110", b: "01", expected: 1},
+		{a: "0100", b: "0101", expected: -1},
+		{a: "1", b: "0111", expected: 1},
+	}
+	for _, c := range cases {
+		a, _ := ParseList(c.a)
+		b, _ := ParseList(c.b)
+		tu.Check(t, c.expected, a.Compare(&b))
+		tu.Check(t, -c.expected, b.Compare(&a))
+		tu.Check(t, c.expected == 0, a.Equal(&b))
+	}
+	// Bits past the length don't affect the comparison.
+	a := NewList([]byte{0xff})
+	a.Shrink(4)
+	b, _ := ParseList("1111")
+	tu.Check(t, true, a.Equal(&b))
+}
+
+func TestBitListBytes(t *testing.T) {
+	bits := NewList([]byte{0xff, 0xff})
+	bits.Shrink(5)
+	tu.Check(t, "ffe0", hex.EncodeToString(bits.Bytes()))
+	bits.Shrink(3)
+	tu.Check(t, "ff", hex.EncodeToString(bits.Bytes()))
+	var empty List
+	tu.Check(t, 0, len(empty.Bytes()))
+}
+
+func TestBitListShrinkFreesMemory(t *testing.T) {
+	bits := NewList(make([]byte, 1000))
+	bits.Shrink(8 * 990)
+	tu.Check(t, 80, bits.Len())
+	if cap(bits.buf) > 2*10 {
+		t.Fatalf("expected capacity of at most 20 bytes, found %d", cap(bits.buf))
+	}
+	// Small lists aren't reallocated when shrinking repeatedly.
+	var small List
+	small.AppendUint(0, 16)
+	buf := small.buf
+	small.Shrink(15)
+	small.Append(true)
+	tu.Check(t, "01", small.String())
+	if &small.buf[0] != &buf[0] {
+		t.Fatal("expected small list not to be reallocated")
+	}
+}
+
+func TestBitWriterReaderReset(t *testing.T) {
+	for _, order := range []Order{MSB, LSB} {
+		var first, second bytes.Buffer
+		w := NewWriterSize(&first, order, 16)
+		tu.ExpectNil(t, w.WriteBits(0x5, 3))
+		// The unflushed bits are discarded.
+		w.Reset(&second)
+		tu.Check(t, int64(0), w.BitsWritten())
+		for i := 0; i < 100; i++ {
+			tu.ExpectNil(t, w.WriteBits(uint64(i), 7))
+		}
+		tu.ExpectNil(t, w.Flush())
+		tu.Check(t, 0, first.Len())
+		tu.Check(t, (700+7)/8, second.Len())
+
+		r := NewReaderSize(bytes.NewReader([]byte{0xff, 0xff}), order, 16)
+		_, err := r.ReadBits(5)
+		tu.ExpectNil(t, err)
+		r.Reset(&second)
+		tu.Check(t, int64(0), r.BitsRead())
+		for i := 0; i < 100; i++ {
+			n, err := r.ReadBits(7)
+			tu.ExpectNil(t, err)
+			tu.Check(t, uint64(i), n)
+		}
+
+		allocs := testing.AllocsPerRun(10, func() {
+			second.Reset()
+			w.Reset(&second)
+			tu.ExpectNil(t, w.WriteBits(0x1234, 16))
+			tu.ExpectNil(t, w.Flush())
+			r.Reset(&second)
+			_, err := r.ReadBits(16)
+			tu.ExpectNil(t, err)
+		})
+		tu.Check(t, 0.0, allocs)
+	}
+}
+
+func TestBitReaderBuffered(t *testing.T) {
+	for _, order := range []Order{MSB, LSB} {
+		src := bufio.NewReader(bytes.NewReader([]byte{0xa5, 0x5a, 0x12, 't', 'a', 'i', 'l'}))
+		r := NewReaderBuffered(src, order)
+		_, err := r.ReadBits(3)
+		tu.ExpectNil(t, err)
+		_, err = r.ReadBits(12)
+		tu.ExpectNil(t, err)
+		r.AlignToByte()
+		// Only the two bytes holding the bits read were consumed.
+		tu.Check(t, 5, src.Buffered())
+		b, err := src.ReadByte()
+		tu.ExpectNil(t, err)
+		tu.Check(t, byte(0x12), b)
+	}
+}
+
+func TestBitHelpers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := []uint64{0, 1, 2, 255, 256, 1<<63 - 1, 1 << 63, 1<<64 - 1}
+	for i := 0; i < 1000; i++ {
+		values = append(values, rng.Uint64()>>uint(rng.Intn(64)))
+	}
+	for _, n := range values {
+		tu.Check(t, mathbits.Len64(n), Len64(n))
+		tu.Check(t, mathbits.Reverse8(byte(n)), Reverse8(byte(n)))
+		tu.Check(t, mathbits.ReverseBytes16(uint16(n)), ReverseBytes16(uint16(n)))
+		tu.Check(t, mathbits.ReverseBytes64(n), ReverseBytes64(n))
+	}
+}
@@ -0,0 +1,195 @@
+package bufio
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
+)
+
+// Errors returned by BytesReader.Seek.
+var (
+	errInvalidWhence    = errors.New("bufio: invalid whence")
+	errNegativePosition = errors.New("bufio: negative position")
+)
+
+// minRead is the minimum number of bytes Buffer.ReadFrom reads at once.
+const minRead = 512
+
+// Buffer is a variable-sized buffer of bytes similar to bytes.Buffer. Writes
+// append to the end of the buffer and reads consume bytes from the beginning.
+// The zero value is an empty buffer ready to use.
+type Buffer struct {
+	buf []byte
+	// Bytes in range [off, len(buf)) are considered as not having been read
+	// yet.
+	off int
+}
+
+// Len returns the number of unread bytes in b.
+func (b *Buffer) Len() int {
+	return len(b.buf) - b.off
+}
+
+// Bytes returns the unread bytes of b. The slice is valid until the next
+// modification of b.
+func (b *Buffer) Bytes() []byte {
+	return b.buf[b.off:]
+}
+
+// Reset empties b. The storage is kept for future writes.
+func (b *Buffer) Reset() {
+	b.buf = b.buf[:0]
+	b.off = 0
+}
+
+// grow makes room for at least n more bytes at the end of b.
+func (b *Buffer) grow(n int) {
+	if len(b.buf)+n <= cap(b.buf) {
+		return
+	}
+	unread := b.Len()
+	if unread+n <= cap(b.buf)/2 {
+		// Sliding the unread bytes to the beginning makes enough room.
+		slices.CopyBytes(b.buf, b.buf[b.off:])
+	} else {
+		buf := make([]byte, unread, 2*cap(b.buf)+n)
+		slices.CopyBytes(buf, b.buf[b.off:])
+		b.buf = buf
+	}
+	b.buf = b.buf[:unread]
+	b.off = 0
+}
+
+// Write appends p to b. It always returns len(p) and a nil error.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.grow(len(p))
+	n := len(b.buf)
+	b.buf = b.buf[:n+len(p)]
+	return slices.CopyBytes(b.buf[n:], p), nil
+}
+
+// WriteByte appends c to b. It always returns nil.
+func (b *Buffer) WriteByte(c byte) error {
+	b.grow(1)
+	b.buf = b.buf[:len(b.buf)+1]
+	b.buf[len(b.buf)-1] = c
+	return nil
+}
+
+// ReadFrom reads data from r until io.EOF and appends it to b. The buffer
+// grows only as data arrives, so reading a bounded reader allocates memory in
+// proportion to the data actually read. n is the number of bytes read.
+func (b *Buffer) ReadFrom(r io.Reader) (n int64, err error) {
+	for empty := 0; empty < maxConsecutiveEmptyReads; {
+		b.grow(minRead)
+		m, err := r.Read(b.buf[len(b.buf):cap(b.buf)])
+		b.buf = b.buf[:len(b.buf)+m]
+		n += int64(m)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			empty++
+		} else {
+			empty = 0
+		}
+	}
+	return n, io.ErrNoProgress
+}
+
+// Read reads the next len(p) bytes from b or until b is empty. io.EOF is
+// returned if b is empty and len(p) > 0.
+func (b *Buffer) Read(p []byte) (int, error) {
+	if b.Len() == 0 {
+		b.Reset()
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := slices.CopyBytes(p, b.buf[b.off:])
+	b.off += n
+	return n, nil
+}
+
+// ReadByte reads and returns the next byte from b. io.EOF is returned if b is
+// empty.
+func (b *Buffer) ReadByte() (byte, error) {
+	if b.Len() == 0 {
+		b.Reset()
+		return 0, io.EOF
+	}
+	c := b.buf[b.off]
+	b.off++
+	return c, nil
+}
+
+// BytesReader implements io.Reader, io.ByteReader and io.Seeker by reading
+// from a byte slice, similar to bytes.Reader.
+type BytesReader struct {
+	s   []byte
+	pos int64
+}
+
+// NewBytesReader returns a BytesReader reading from s.
+func NewBytesReader(s []byte) *BytesReader {
+	return &BytesReader{s: s}
+}
+
+// Reset resets r to read from s.
+func (r *BytesReader) Reset(s []byte) {
+	r.s = s
+	r.pos = 0
+}
+
+// Len returns the number of unread bytes.
+func (r *BytesReader) Len() int {
+	if r.pos >= int64(len(r.s)) {
+		return 0
+	}
+	return len(r.s) - int(r.pos)
+}
+
+// Read reads the next len(p) bytes or until the end of the slice. io.EOF is
+// returned at the end of the slice.
+func (r *BytesReader) Read(p []byte) (int, error) {
+	if r.pos >= int64(len(r.s)) {
+		return 0, io.EOF
+	}
+	n := slices.CopyBytes(p, r.s[r.pos:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// ReadByte reads and returns the next byte. io.EOF is returned at the end of
+// the slice.
+func (r *BytesReader) ReadByte() (byte, error) {
+	if r.pos >= int64(len(r.s)) {
+		return 0, io.EOF
+	}
+	c := r.s[r.pos]
+	r.pos++
+	return c, nil
+}
+
+// Seek sets the offset for the next Read. It implements io.Seeker.
+func (r *BytesReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += int64(len(r.s))
+	default:
+		return 0, errInvalidWhence
+	}
+	if offset < 0 {
+		return 0, errNegativePosition
+	}
+	r.pos = offset
+	return offset, nil
+}
@@ -1,7 +1,16 @@
 // Package bufio implements parts of the standard library package "bufio".
+//
+// The semantics follow the standard library with a few exceptions.
+// Reader.Read keeps reading until p is full or an error occurs, Reader.Peek
+// grows the buffer instead of returning ErrBufferFull when asked for more bytes
+// than the buffer holds and Writer.Write never bypasses the buffer.
+//
+// Buffer and BytesReader provide in-memory reading and writing like the
+// corresponding types of the standard library package "bytes".
 package bufio
 
 import (
+	"errors"
 	"io"
 
 	"github.com/lassilaiho/compression-algorithms-tiralabra/util/slices"
@@ -11,6 +20,22 @@ import (
 // Writer.
 const defaultBufSize = 4096
 
+// maxConsecutiveEmptyReads is the number of times an io.Reader may return no
+// data and no error in a row before io.ErrNoProgress is reported.
+const maxConsecutiveEmptyReads = 100
+
+var (
+	// ErrBufferFull is returned by Reader.ReadSlice if the buffer fills
+	// before the delimiter is found.
+	ErrBufferFull = errors.New("bufio: buffer full")
+	// ErrNegativeCount is returned when a negative count is passed to
+	// Reader.Peek or Reader.Discard.
+	ErrNegativeCount = errors.New("bufio: negative count")
+	// ErrInvalidUnreadByte is returned by Reader.UnreadByte if the previous
+	// operation wasn't a read.
+	ErrInvalidUnreadByte = errors.New("bufio: invalid use of UnreadByte")
+)
+
 // Reader buffers reads from an io.Reader.
 type Reader struct {
 	rd  io.Reader // The underlying io.Reader
@@ -23,6 +48,10 @@ type Reader struct {
 
 	// The most recent error encountered when reading from rd.
 	err error
+
+	// The last byte read for UnreadByte or -1 if the previous operation wasn't
+	// a read.
+	lastByte int
 }
 
 // NewReader returns a Reader that reads from rd.
@@ -31,30 +60,48 @@ func NewReader(rd io.Reader) *Reader {
 }
 
 // NewReaderSize returns a Reader with the specified buffer size that reads from
-// rd.
+// rd. If size isn't positive, the default size is used.
 func NewReaderSize(rd io.Reader, size int) *Reader {
+	if size <= 0 {
+		size = defaultBufSize
+	}
 	return &Reader{
-		rd:  rd,
-		buf: make([]byte, size),
+		rd:       rd,
+		buf:      make([]byte, size),
+		lastByte: -1,
 	}
 }
 
+// Size returns the size of the buffer in bytes.
+func (r *Reader) Size() int {
+	return len(r.buf)
+}
+
+// Buffered returns the number of bytes that can be read from the buffer
+// without reading from the underlying io.Reader.
+func (r *Reader) Buffered() int {
+	return r.end - r.next
+}
+
 // Read reads data into p. total is the amount of bytes read. If it is less than
 // len(p), a non-nil error is also returned to explain why the read failed.
 func (r *Reader) Read(p []byte) (total int, err error) {
 	goal := len(p)
+	dst := p
 	for {
-		n := slices.CopyBytes(p, r.buf[r.next:r.end])
+		n := slices.CopyBytes(dst, r.buf[r.next:r.end])
 		total += n
 		r.next += n
+		if total > 0 {
+			r.lastByte = int(p[total-1])
+		}
 		if total == goal {
 			return total, nil
 		}
 		if r.err != nil {
-			err, r.err = r.err, nil
-			return total, err
+			return total, r.readErr()
 		}
-		p = p[n:]
+		dst = dst[n:]
 		r.end, r.err = r.rd.Read(r.buf)
 		r.next = 0
 	}
@@ -68,30 +115,93 @@ func (r *Reader) ReadByte() (byte, error) {
 	return buf[0], err
 }
 
-// Reset discards all buffered data and resets r to read from rd.
+// UnreadByte unreads the last byte. Only the most recently read byte can be
+// unread, and only if no other operation has been performed since reading it.
+func (r *Reader) UnreadByte() error {
+	if r.lastByte < 0 || r.next == 0 && r.end > 0 {
+		return ErrInvalidUnreadByte
+	}
+	if r.next > 0 {
+		r.next--
+	} else {
+		r.end = 1
+	}
+	r.buf[r.next] = byte(r.lastByte)
+	r.lastByte = -1
+	return nil
+}
+
+// ReadSlice reads until the first occurrence of delim and returns a slice of
+// the buffer holding the data up to and including the delimiter. The bytes are
+// valid until the next read. If ReadSlice encounters an error before finding
+// the delimiter, it returns all buffered data and the error. ErrBufferFull is
+// returned if the buffer fills without a delimiter.
+func (r *Reader) ReadSlice(delim byte) (line []byte, err error) {
+	searched := 0
+	for {
+		found := false
+		for i := r.next + searched; i < r.end; i++ {
+			if r.buf[i] == delim {
+				line = r.buf[r.next : i+1]
+				r.next = i + 1
+				found = true
+				break
+			}
+		}
+		if found {
+			break
+		}
+		if r.err != nil {
+			line = r.buf[r.next:r.end]
+			r.next = r.end
+			err = r.readErr()
+			break
+		}
+		if r.Buffered() >= len(r.buf) {
+			line = r.buf[r.next:r.end]
+			r.next = r.end
+			err = ErrBufferFull
+			break
+		}
+		searched = r.Buffered()
+		r.fill()
+	}
+	if len(line) > 0 {
+		r.lastByte = int(line[len(line)-1])
+	}
+	return line, err
+}
+
+// Reset discards all buffered data and resets r to read from rd. The buffer is
+// reused, so Reset allocates nothing.
 func (r *Reader) Reset(rd io.Reader) {
 	r.rd = rd
-	for i := 0; i < len(r.buf); i++ {
-		r.buf[i] = 0
-	}
 	r.next = 0
 	r.end = 0
 	r.err = nil
+	r.lastByte = -1
 }
 
 // Discard discards the next n bytes. total is the number of bytes discarded. If
 // total < n, a non-nil error is returned.
 func (r *Reader) Discard(n int) (total int, err error) {
+	if n < 0 {
+		return 0, ErrNegativeCount
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	r.lastByte = -1
 	for {
 		if n <= r.end-r.next {
 			r.next += n
 			return total + n, nil
 		}
 		if r.err != nil {
-			err, r.err = r.err, nil
-			return total, err
+			return total, r.readErr()
 		}
 		n -= r.end - r.next
+		total += r.end - r.next
 		r.end, r.err = r.rd.Read(r.buf)
 		r.next = 0
 	}
@@ -99,28 +209,98 @@ func (r *Reader) Discard(n int) (total int, err error) {
 
 // Peek returns the next n bytes without advancing the Reader. The bytes are
 // valid until the next read. If Peek returns fewer than n bytes, a non-nil
-// error is returned.
+// error is returned. If n is larger than the buffer, the buffer is grown to n
+// bytes.
 func (r *Reader) Peek(n int) (buf []byte, err error) {
-	if n <= r.end-r.next {
-		return r.buf[r.next:r.end], nil
+	if n < 0 {
+		return nil, ErrNegativeCount
+	}
+	r.lastByte = -1
+	if n > len(r.buf) {
+		r.grow(n)
+	}
+	for r.end-r.next < n && r.err == nil {
+		r.fill()
+	}
+	if r.end-r.next < n {
+		return r.buf[r.next:r.end], r.readErr()
+	}
+	return r.buf[r.next : r.next+n], nil
+}
+
+// WriteTo writes all remaining data to w until the underlying io.Reader
+// returns io.EOF. n is the number of bytes written. The io.WriterTo of the
+// underlying reader or the io.ReaderFrom of w is used if available.
+func (r *Reader) WriteTo(w io.Writer) (n int64, err error) {
+	r.lastByte = -1
+	n, err = r.writeBuf(w)
+	if err != nil {
+		return n, err
 	}
-	if r.err != nil {
-		err, r.err = r.err, nil
-		return r.buf[r.next:r.end], err
+	if wt, ok := r.rd.(io.WriterTo); ok {
+		m, err := wt.WriteTo(w)
+		return n + m, err
 	}
-	if r.next != 0 {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		m, err := rf.ReadFrom(r.rd)
+		return n + m, err
+	}
+	for r.fill(); r.next < r.end; r.fill() {
+		m, err := r.writeBuf(w)
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	if r.err == io.EOF {
+		r.err = nil
+	}
+	return n, r.readErr()
+}
+
+// writeBuf writes the buffered data to w.
+func (r *Reader) writeBuf(w io.Writer) (int64, error) {
+	n, err := w.Write(r.buf[r.next:r.end])
+	r.next += n
+	return int64(n), err
+}
+
+// fill moves the buffered data to the beginning of the buffer and reads more
+// data after it.
+func (r *Reader) fill() {
+	if r.next > 0 {
 		slices.CopyBytes(r.buf, r.buf[r.next:r.end])
 		r.end -= r.next
 		r.next = 0
 	}
-	var x int
-	x, r.err = r.rd.Read(r.buf[r.end:])
-	r.end += x
-	if n <= r.end {
-		return r.buf[:r.end], nil
+	for i := 0; i < maxConsecutiveEmptyReads; i++ {
+		n, err := r.rd.Read(r.buf[r.end:])
+		r.end += n
+		if err != nil {
+			r.err = err
+			return
+		}
+		if n > 0 {
+			return
+		}
 	}
-	err, r.err = r.err, nil
-	return r.buf[:r.end], err
+	r.err = io.ErrNoProgress
+}
+
+// grow replaces the buffer with a buffer of size bytes holding the buffered
+// data.
+func (r *Reader) grow(size int) {
+	buf := make([]byte, size)
+	r.end = slices.CopyBytes(buf, r.buf[r.next:r.end])
+	r.next = 0
+	r.buf = buf
+}
+
+// readErr returns and clears the stored read error.
+func (r *Reader) readErr() error {
+	err := r.err
+	r.err = nil
+	return err
 }
 
 // Writer implements buffering for an io.Writer.
@@ -136,32 +316,64 @@ type Writer struct {
 
 // NewWriter returns a Writer that writes to wr.
 func NewWriter(wr io.Writer) *Writer {
+	return NewWriterSize(wr, defaultBufSize)
+}
+
+// NewWriterSize returns a Writer with the specified buffer size that writes to
+// wr. If size isn't positive, the default size is used.
+func NewWriterSize(wr io.Writer, size int) *Writer {
+	if size <= 0 {
+		size = defaultBufSize
+	}
 	return &Writer{
 		wr:  wr,
-		buf: make([]byte, defaultBufSize),
+		buf: make([]byte, size),
 	}
 }
 
+// Reset discards all unflushed data and resets w to write to wr. The buffer is
+// reused, so Reset allocates nothing.
+func (w *Writer) Reset(wr io.Writer) {
+	w.wr = wr
+	w.next = 0
+	w.end = 0
+}
+
+// Size returns the size of the buffer in bytes.
+func (w *Writer) Size() int {
+	return len(w.buf)
+}
+
+// Buffered returns the number of bytes written to the buffer but not yet
+// flushed.
+func (w *Writer) Buffered() int {
+	return w.end - w.next
+}
+
+// Available returns the number of bytes that can be written before the buffer
+// has to be flushed.
+func (w *Writer) Available() int {
+	return len(w.buf) - w.end
+}
+
 // Write writes data from p. total is the number of bytes written. If total <
 // len(p), a non-nil error is returned.
 func (w *Writer) Write(p []byte) (total int, err error) {
-	goal := len(p)
-	for {
+	// Unlike in the standard library, data always goes through the buffer,
+	// so p never escapes and callers can write from arrays on the stack
+	// without allocating.
+	for len(p) > w.Available() {
 		n := slices.CopyBytes(w.buf[w.end:], p)
 		w.end += n
 		total += n
-		if total == goal {
-			return total, nil
-		}
 		p = p[n:]
-		n, err := w.wr.Write(w.buf[w.next:w.end])
-		w.next += n
-		if err != nil {
+		if err := w.Flush(); err != nil {
 			return total, err
 		}
-		w.next = 0
-		w.end = 0
 	}
+	n := slices.CopyBytes(w.buf[w.end:], p)
+	w.end += n
+	return total + n, nil
 }
 
 // Flush writes all buffered data to the underlying io.Writer. A non-nil error
@@ -169,6 +381,9 @@ func (w *Writer) Write(p []byte) (total int, err error) {
 func (w *Writer) Flush() error {
 	n, err := w.wr.Write(w.buf[w.next:w.end])
 	w.next += n
+	if err == nil && w.next < w.end {
+		err = io.ErrShortWrite
+	}
 	if err != nil {
 		return err
 	}
@@ -179,7 +394,82 @@ func (w *Writer) Flush() error {
 
 // WriteByte writes a single byte to w.
 func (w *Writer) WriteByte(b byte) error {
-	buf := [1]byte{b}
-	_, err := w.Write(buf[:])
-	return err
+	if w.Available() == 0 {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	w.buf[w.end] = b
+	w.end++
+	return nil
+}
+
+// ReadFrom reads data from rd until io.EOF and writes it to w. n is the number
+// of bytes read. If the buffer is empty and the underlying io.Writer
+// implements io.ReaderFrom, its ReadFrom is used.
+func (w *Writer) ReadFrom(rd io.Reader) (n int64, err error) {
+	readerFrom, readerFromOK := w.wr.(io.ReaderFrom)
+	for {
+		if w.Available() == 0 {
+			if err := w.Flush(); err != nil {
+				return n, err
+			}
+		}
+		if readerFromOK && w.Buffered() == 0 {
+			m, err := readerFrom.ReadFrom(rd)
+			return n + m, err
+		}
+		var m int
+		empty := 0
+		for ; empty < maxConsecutiveEmptyReads; empty++ {
+			m, err = rd.Read(w.buf[w.end:])
+			if m != 0 || err != nil {
+				break
+			}
+		}
+		if empty == maxConsecutiveEmptyReads {
+			return n, io.ErrNoProgress
+		}
+		w.end += m
+		n += int64(m)
+		if err != nil {
+			break
+		}
+	}
+	if err == io.EOF {
+		// A full buffer is flushed right away like in the standard library.
+		if w.Available() == 0 {
+			return n, w.Flush()
+		}
+		return n, nil
+	}
+	return n, err
+}
+
+// ReadFull reads exactly len(p) bytes from r into p. Unlike io.ReadFull,
+// io.ErrUnexpectedEOF is returned whenever r ends before p is filled, even if
+// no bytes were read. n is the number of bytes read.
+func ReadFull(r io.Reader, p []byte) (n int, err error) {
+	for empty := 0; n < len(p); {
+		var m int
+		m, err = r.Read(p[n:])
+		n += m
+		if n == len(p) {
+			return n, nil
+		}
+		if err == io.EOF {
+			return n, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			if empty++; empty == maxConsecutiveEmptyReads {
+				return n, io.ErrNoProgress
+			}
+		} else {
+			empty = 0
+		}
+	}
+	return n, nil
 }
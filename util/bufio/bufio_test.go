@@ -0,0 +1,384 @@
+package bufio
+
+import (
+	stdbufio "bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+// chunkReader returns the data of r in chunks of random size.
+type chunkReader struct {
+	r   io.Reader
+	rng *rand.Rand
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1+r.rng.Intn(len(p))]
+	}
+	return r.r.Read(p)
+}
+
+// onlyWriter hides all methods but Write of the wrapped io.Writer.
+type onlyWriter struct {
+	io.Writer
+}
+
+// randomData returns n random bytes with frequent newlines.
+func randomData(rng *rand.Rand, n int) []byte {
+	data := make([]byte, n)
+	rng.Read(data)
+	for i := range data {
+		if data[i] < 16 {
+			data[i] = '\n'
+		}
+	}
+	return data
+}
+
+// errString returns the message of err or "<nil>" if err is nil.
+func errString(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+func TestReaderMatchesStdlib(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 200; round++ {
+		size := 16 + rng.Intn(64)
+		data := randomData(rng, rng.Intn(2000))
+		ours := NewReaderSize(&chunkReader{bytes.NewReader(data), rand.New(rand.NewSource(int64(round)))}, size)
+		std := stdbufio.NewReaderSize(bytes.NewReader(data), size)
+		for step := 0; step < 200; step++ {
+			switch op := rng.Intn(6); op {
+			case 0:
+				b1, err1 := ours.ReadByte()
+				b2, err2 := std.ReadByte()
+				tu.Check(t, b2, b1)
+				tu.Check(t, errString(err2), errString(err1))
+			case 1:
+				tu.Check(t, errString(std.UnreadByte()), errString(ours.UnreadByte()))
+			case 2:
+				n := rng.Intn(2 * size)
+				p1, p2 := make([]byte, n), make([]byte, n)
+				n1, err1 := ours.Read(p1)
+				n2, err2 := io.ReadFull(std, p2)
+				tu.Check(t, string(p2[:n2]), string(p1[:n1]))
+				tu.Check(t, err2 == nil, err1 == nil)
+			case 3:
+				n := rng.Intn(size + 1)
+				p1, err1 := ours.Peek(n)
+				p2, err2 := std.Peek(n)
+				tu.Check(t, string(p2), string(p1))
+				tu.Check(t, errString(err2), errString(err1))
+			case 4:
+				n := rng.Intn(2 * size)
+				n1, err1 := ours.Discard(n)
+				n2, err2 := std.Discard(n)
+				tu.Check(t, n2, n1)
+				tu.Check(t, errString(err2), errString(err1))
+			case 5:
+				line1, err1 := ours.ReadSlice('\n')
+				line2, err2 := std.ReadSlice('\n')
+				tu.Check(t, string(line2), string(line1))
+				tu.Check(t, errString(err2), errString(err1))
+			}
+		}
+	}
+}
+
+func TestReaderIntrospection(t *testing.T) {
+	r := NewReaderSize(bytes.NewReader([]byte("abcdefgh")), 4)
+	tu.Check(t, 4, r.Size())
+	tu.Check(t, 0, r.Buffered())
+	b, err := r.ReadByte()
+	tu.ExpectNil(t, err)
+	tu.Check(t, byte('a'), b)
+	tu.Check(t, 3, r.Buffered())
+	tu.ExpectNil(t, r.UnreadByte())
+	tu.Check(t, 4, r.Buffered())
+	tu.Check(t, ErrInvalidUnreadByte, r.UnreadByte())
+
+	_, err = r.Peek(-1)
+	tu.Check(t, ErrNegativeCount, err)
+	_, err = r.Discard(-1)
+	tu.Check(t, ErrNegativeCount, err)
+}
+
+func TestReaderPeekGrows(t *testing.T) {
+	data := randomData(rand.New(rand.NewSource(2)), 100)
+	r := NewReaderSize(bytes.NewReader(data), 16)
+	_, err := r.Discard(10)
+	tu.ExpectNil(t, err)
+	p, err := r.Peek(50)
+	tu.ExpectNil(t, err)
+	tu.Check(t, string(data[10:60]), string(p))
+	tu.Check(t, 50, r.Size())
+	p, err = r.Peek(200)
+	tu.ExpectEOF(t, err)
+	tu.Check(t, string(data[10:]), string(p))
+	rest := make([]byte, 90)
+	n, err := r.Read(rest)
+	tu.ExpectNil(t, err)
+	tu.Check(t, string(data[10:]), string(rest[:n]))
+}
+
+func TestReaderReadSliceBufferFull(t *testing.T) {
+	r := NewReaderSize(bytes.NewReader([]byte("0123456789abcdefghij\nxyz")), 16)
+	line, err := r.ReadSlice('\n')
+	tu.Check(t, ErrBufferFull, err)
+	tu.Check(t, "0123456789abcdef", string(line))
+	line, err = r.ReadSlice('\n')
+	tu.ExpectNil(t, err)
+	tu.Check(t, "ghij\n", string(line))
+	line, err = r.ReadSlice('\n')
+	tu.ExpectEOF(t, err)
+	tu.Check(t, "xyz", string(line))
+}
+
+func TestReaderWriteTo(t *testing.T) {
+	data := randomData(rand.New(rand.NewSource(3)), 10000)
+	sources := map[string]func() io.Reader{
+		// bytes.Reader implements io.WriterTo.
+		"WriterTo": func() io.Reader { return bytes.NewReader(data) },
+		"Plain":    func() io.Reader { return &chunkReader{bytes.NewReader(data), rand.New(rand.NewSource(4))} },
+	}
+	for name, source := range sources {
+		for _, dstReaderFrom := range []bool{false, true} {
+			r := NewReaderSize(source(), 64)
+			_, err := r.Peek(10)
+			tu.ExpectNil(t, err)
+			var out bytes.Buffer
+			var dst io.Writer = &out
+			if !dstReaderFrom {
+				dst = onlyWriter{&out}
+			}
+			n, err := r.WriteTo(dst)
+			tu.ExpectNil(t, err)
+			tu.Check(t, int64(len(data)), n)
+			if !bytes.Equal(data, out.Bytes()) {
+				t.Fatalf("%s: WriteTo produced different data", name)
+			}
+		}
+	}
+}
+
+func TestWriterMatchesStdlib(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for round := 0; round < 200; round++ {
+		size := 16 + rng.Intn(64)
+		var out1, out2 bytes.Buffer
+		ours := NewWriterSize(onlyWriter{&out1}, size)
+		std := stdbufio.NewWriterSize(onlyWriter{&out2}, size)
+		tu.Check(t, std.Size(), ours.Size())
+		for step := 0; step < 100; step++ {
+			switch op := rng.Intn(4); op {
+			case 0:
+				data := randomData(rng, rng.Intn(2*size))
+				n1, err1 := ours.Write(data)
+				n2, err2 := std.Write(data)
+				tu.Check(t, n2, n1)
+				tu.Check(t, errString(err2), errString(err1))
+			case 1:
+				b := byte(rng.Intn(256))
+				tu.Check(t, errString(std.WriteByte(b)), errString(ours.WriteByte(b)))
+			case 2:
+				data := randomData(rng, rng.Intn(3*size))
+				src := &chunkReader{bytes.NewReader(data), rng}
+				n1, err1 := ours.ReadFrom(src)
+				n2, err2 := std.ReadFrom(bytes.NewReader(data))
+				tu.Check(t, n2, n1)
+				tu.Check(t, errString(err2), errString(err1))
+			case 3:
+				tu.Check(t, errString(std.Flush()), errString(ours.Flush()))
+				tu.Check(t, 0, ours.Buffered())
+				tu.Check(t, size, ours.Available())
+			}
+			tu.Check(t, out2.Len()+std.Buffered(), out1.Len()+ours.Buffered())
+			tu.Check(t, ours.Size()-ours.Buffered(), ours.Available())
+		}
+		tu.ExpectNil(t, ours.Flush())
+		tu.ExpectNil(t, std.Flush())
+		if !bytes.Equal(out2.Bytes(), out1.Bytes()) {
+			t.Fatal("written data differs from the standard library")
+		}
+	}
+}
+
+func TestWriterReadFromDelegates(t *testing.T) {
+	data := randomData(rand.New(rand.NewSource(6)), 10000)
+	var out bytes.Buffer
+	w := NewWriterSize(&out, 16)
+	tu.ExpectNil(t, w.WriteByte('x'))
+	n, err := w.ReadFrom(bytes.NewReader(data))
+	tu.ExpectNil(t, err)
+	tu.Check(t, int64(len(data)), n)
+	tu.ExpectNil(t, w.Flush())
+	tu.Check(t, "x"+string(data), out.String())
+}
+
+// shortWriter accepts at most n bytes per call.
+type shortWriter struct {
+	n   int
+	err error
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, w.err
+	}
+	return len(p), nil
+}
+
+func TestWriterErrors(t *testing.T) {
+	w := NewWriterSize(&shortWriter{n: 2}, 16)
+	_, err := w.Write([]byte("abcd"))
+	tu.ExpectNil(t, err)
+	tu.Check(t, io.ErrShortWrite, w.Flush())
+	tu.Check(t, 2, w.Buffered())
+
+	errWrite := errors.New("write failed")
+	w = NewWriterSize(&shortWriter{n: 4, err: errWrite}, 8)
+	n, err := w.Write([]byte("0123456789"))
+	tu.Check(t, errWrite, err)
+	tu.Check(t, 8, n)
+}
+
+func TestReset(t *testing.T) {
+	r := NewReaderSize(bytes.NewReader([]byte("abc")), 16)
+	_, err := r.Peek(2)
+	tu.ExpectNil(t, err)
+	r.Reset(bytes.NewReader([]byte("xyz")))
+	tu.Check(t, 0, r.Buffered())
+	tu.Check(t, ErrInvalidUnreadByte, r.UnreadByte())
+	b, err := r.ReadByte()
+	tu.ExpectNil(t, err)
+	tu.Check(t, byte('x'), b)
+
+	var first, second bytes.Buffer
+	w := NewWriterSize(&first, 16)
+	tu.ExpectNil(t, w.WriteByte('a'))
+	w.Reset(&second)
+	tu.Check(t, 0, w.Buffered())
+	tu.ExpectNil(t, w.WriteByte('b'))
+	tu.ExpectNil(t, w.Flush())
+	tu.Check(t, "", first.String())
+	tu.Check(t, "b", second.String())
+
+	allocs := testing.AllocsPerRun(10, func() {
+		second.Reset()
+		r.Reset(&second)
+		w.Reset(&second)
+		tu.ExpectNil(t, w.WriteByte('c'))
+		tu.ExpectNil(t, w.Flush())
+		_, err := r.ReadByte()
+		tu.ExpectNil(t, err)
+	})
+	tu.Check(t, 0.0, allocs)
+}
+
+func TestReadFull(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := randomData(rng, 1000)
+	p := make([]byte, len(data))
+	n, err := ReadFull(&chunkReader{bytes.NewReader(data), rng}, p)
+	tu.ExpectNil(t, err)
+	tu.Check(t, len(data), n)
+	tu.Check(t, string(data), string(p))
+
+	n, err = ReadFull(&chunkReader{bytes.NewReader(data[:10]), rng}, p)
+	tu.Check(t, io.ErrUnexpectedEOF, err)
+	tu.Check(t, 10, n)
+	n, err = ReadFull(bytes.NewReader(nil), p)
+	tu.Check(t, io.ErrUnexpectedEOF, err)
+	tu.Check(t, 0, n)
+	n, err = ReadFull(bytes.NewReader(nil), nil)
+	tu.ExpectNil(t, err)
+	tu.Check(t, 0, n)
+}
+
+func TestBufferMatchesStdlib(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	var b Buffer
+	var expected bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		switch rng.Intn(4) {
+		case 0:
+			data := randomData(rng, rng.Intn(100))
+			b.Write(data)
+			expected.Write(data)
+		case 1:
+			c := byte(rng.Intn(256))
+			b.WriteByte(c)
+			expected.WriteByte(c)
+		case 2:
+			p := make([]byte, rng.Intn(150))
+			q := make([]byte, len(p))
+			n, err := b.Read(p)
+			m, expectedErr := expected.Read(q)
+			tu.Check(t, m, n)
+			tu.Check(t, expectedErr, err)
+			tu.Check(t, string(q[:m]), string(p[:n]))
+		case 3:
+			c, err := b.ReadByte()
+			d, expectedErr := expected.ReadByte()
+			tu.Check(t, expectedErr, err)
+			tu.Check(t, d, c)
+		}
+		tu.Check(t, expected.Len(), b.Len())
+		tu.Check(t, expected.String(), string(b.Bytes()))
+	}
+}
+
+func TestBufferReadFrom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	data := randomData(rng, 10000)
+	var b Buffer
+	b.WriteByte('x')
+	n, err := b.ReadFrom(&chunkReader{r: bytes.NewReader(data), rng: rng})
+	tu.ExpectNil(t, err)
+	tu.Check(t, int64(len(data)), n)
+	tu.Check(t, "x"+string(data), string(b.Bytes()))
+	// The buffer grows only as data arrives.
+	var limited Buffer
+	_, err = limited.ReadFrom(io.LimitReader(bytes.NewReader([]byte("abc")), 1<<40))
+	tu.ExpectNil(t, err)
+	tu.Check(t, "abc", string(limited.Bytes()))
+	tu.Check(t, true, cap(limited.buf) <= 4*minRead)
+}
+
+func TestBytesReader(t *testing.T) {
+	r := NewBytesReader([]byte("abcdef"))
+	p := make([]byte, 4)
+	n, err := r.Read(p)
+	tu.ExpectNil(t, err)
+	tu.Check(t, "abcd", string(p[:n]))
+	tu.Check(t, 2, r.Len())
+	pos, err := r.Seek(-5, io.SeekEnd)
+	tu.ExpectNil(t, err)
+	tu.Check(t, int64(1), pos)
+	c, err := r.ReadByte()
+	tu.ExpectNil(t, err)
+	tu.Check(t, byte('b'), c)
+	_, err = r.Seek(10, io.SeekCurrent)
+	tu.ExpectNil(t, err)
+	_, err = r.Read(p)
+	tu.Check(t, io.EOF, err)
+	_, err = r.ReadByte()
+	tu.Check(t, io.EOF, err)
+	_, err = r.Seek(-1, io.SeekStart)
+	tu.Check(t, errNegativePosition, err)
+	_, err = r.Seek(0, 3)
+	tu.Check(t, errInvalidWhence, err)
+	r.Reset([]byte("x"))
+	n, err = r.Read(p)
+	tu.ExpectNil(t, err)
+	tu.Check(t, "x", string(p[:n]))
+}
@@ -0,0 +1,289 @@
+// Package checksum implements checksum algorithms used by compressed data
+// formats.
+package checksum
+
+import (
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+)
+
+// These constants are the reversed representations of CRC-32 polynomials.
+const (
+	// ieeePolynomial is the polynomial used by gzip, zip and PNG among others.
+	ieeePolynomial = 0xedb88320
+	// castagnoliPolynomial is the polynomial used by iSCSI and Snappy among
+	// others.
+	castagnoliPolynomial = 0x82f63b78
+)
+
+// crcTable is a lookup table for computing a CRC-32 checksum a byte at a time.
+type crcTable [256]uint32
+
+// newCRCTable returns the lookup table for polynomial.
+func newCRCTable(polynomial uint32) *crcTable {
+	t := &crcTable{}
+	for i := 0; i < len(t); i++ {
+		crc := uint32(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ polynomial
+			} else {
+				crc >>= 1
+			}
+		}
+		t[i] = crc
+	}
+	return t
+}
+
+// update returns crc updated with the bytes in p.
+func (t *crcTable) update(crc uint32, p []byte) uint32 {
+	crc = ^crc
+	for i := 0; i < len(p); i++ {
+		crc = t[byte(crc)^p[i]] ^ crc>>8
+	}
+	return ^crc
+}
+
+var (
+	ieeeTable       = newCRCTable(ieeePolynomial)
+	castagnoliTable = newCRCTable(castagnoliPolynomial)
+)
+
+// CRC32 computes the CRC-32 checksum of data written to it using the IEEE
+// polynomial. The zero value is the checksum of empty data and is ready for
+// use.
+type CRC32 struct {
+	crc uint32
+}
+
+// Write updates the checksum with the bytes in p. It never returns an error.
+func (c *CRC32) Write(p []byte) (int, error) {
+	c.crc = ieeeTable.update(c.crc, p)
+	return len(p), nil
+}
+
+// Sum32 returns the checksum of the data written so far.
+func (c *CRC32) Sum32() uint32 {
+	return c.crc
+}
+
+// Reset resets c to the checksum of empty data.
+func (c *CRC32) Reset() {
+	c.crc = 0
+}
+
+// CRC32C computes the CRC-32C checksum of data written to it using the
+// Castagnoli polynomial. The zero value is the checksum of empty data and is
+// ready for use.
+type CRC32C struct {
+	crc uint32
+}
+
+// Write updates the checksum with the bytes in p. It never returns an error.
+func (c *CRC32C) Write(p []byte) (int, error) {
+	c.crc = castagnoliTable.update(c.crc, p)
+	return len(p), nil
+}
+
+// Sum32 returns the checksum of the data written so far.
+func (c *CRC32C) Sum32() uint32 {
+	return c.crc
+}
+
+// Reset resets c to the checksum of empty data.
+func (c *CRC32C) Reset() {
+	c.crc = 0
+}
+
+// adlerModulus is the modulus used by the Adler-32 algorithm.
+const adlerModulus = 65521
+
+// adlerMaxRun is the largest number of bytes that can be added to the sums of
+// an Adler-32 checksum before they have to be reduced to avoid overflow.
+const adlerMaxRun = 5552
+
+// Adler32 computes the Adler-32 checksum of data written to it. The zero value
+// is the checksum of empty data and is ready for use.
+type Adler32 struct {
+	// a is stored as one less than the actual value so that the zero value
+	// of Adler32 is the initial state.
+	a, b uint32
+}
+
+// Write updates the checksum with the bytes in p. It never returns an error.
+func (c *Adler32) Write(p []byte) (int, error) {
+	a, b := c.a+1, c.b
+	for i := 0; i < len(p); {
+		end := i + adlerMaxRun
+		if end > len(p) {
+			end = len(p)
+		}
+		for ; i < end; i++ {
+			a += uint32(p[i])
+			b += a
+		}
+		a %= adlerModulus
+		b %= adlerModulus
+	}
+	c.a, c.b = a-1, b
+	return len(p), nil
+}
+
+// Sum32 returns the checksum of the data written so far.
+func (c *Adler32) Sum32() uint32 {
+	return c.b<<16 | (c.a + 1)
+}
+
+// Reset resets c to the checksum of empty data.
+func (c *Adler32) Reset() {
+	c.a, c.b = 0, 0
+}
+
+// These constants are the primes used by the xxHash32 algorithm.
+const (
+	xxhPrime1 uint32 = 2654435761
+	xxhPrime2 uint32 = 2246822519
+	xxhPrime3 uint32 = 3266489917
+	xxhPrime4 uint32 = 668265263
+	xxhPrime5 uint32 = 374761393
+)
+
+// XXH32 computes the 32-bit xxHash of data written to it.
+type XXH32 struct {
+	seed uint32
+	// v contains the four accumulators for full 16-byte stripes.
+	v [4]uint32
+	// buf contains the bytes not yet processed as a part of a full stripe.
+	buf [16]byte
+	n   int
+	// total is the total number of bytes written.
+	total uint64
+}
+
+// NewXXH32 returns an XXH32 using seed.
+func NewXXH32(seed uint32) *XXH32 {
+	x := &XXH32{seed: seed}
+	x.Reset()
+	return x
+}
+
+// Reset resets x to the hash of empty data.
+func (x *XXH32) Reset() {
+	x.v[0] = x.seed + xxhPrime1 + xxhPrime2
+	x.v[1] = x.seed + xxhPrime2
+	x.v[2] = x.seed
+	x.v[3] = x.seed - xxhPrime1
+	x.n = 0
+	x.total = 0
+}
+
+// Write updates the hash with the bytes in p. It never returns an error.
+func (x *XXH32) Write(p []byte) (int, error) {
+	written := len(p)
+	x.total += uint64(len(p))
+	if x.n > 0 {
+		for x.n < len(x.buf) && len(p) > 0 {
+			x.buf[x.n] = p[0]
+			x.n++
+			p = p[1:]
+		}
+		if x.n < len(x.buf) {
+			return written, nil
+		}
+		x.processStripe(x.buf[:])
+		x.n = 0
+	}
+	for len(p) >= len(x.buf) {
+		x.processStripe(p[:len(x.buf)])
+		p = p[len(x.buf):]
+	}
+	for i := 0; i < len(p); i++ {
+		x.buf[i] = p[i]
+	}
+	x.n = len(p)
+	return written, nil
+}
+
+// processStripe updates the accumulators with a 16-byte stripe.
+func (x *XXH32) processStripe(p []byte) {
+	for i := 0; i < len(x.v); i++ {
+		x.v[i] = xxhRound(x.v[i], binary.GetUint32LE(p[4*i:]))
+	}
+}
+
+// Sum32 returns the hash of the data written so far.
+func (x *XXH32) Sum32() uint32 {
+	var h uint32
+	if x.total >= uint64(len(x.buf)) {
+		h = rotl32(x.v[0], 1) + rotl32(x.v[1], 7) +
+			rotl32(x.v[2], 12) + rotl32(x.v[3], 18)
+	} else {
+		h = x.seed + xxhPrime5
+	}
+	h += uint32(x.total)
+	i := 0
+	for ; i+4 <= x.n; i += 4 {
+		h += binary.GetUint32LE(x.buf[i:]) * xxhPrime3
+		h = rotl32(h, 17) * xxhPrime4
+	}
+	for ; i < x.n; i++ {
+		h += uint32(x.buf[i]) * xxhPrime5
+		h = rotl32(h, 11) * xxhPrime1
+	}
+	h ^= h >> 15
+	h *= xxhPrime2
+	h ^= h >> 13
+	h *= xxhPrime3
+	h ^= h >> 16
+	return h
+}
+
+// xxhRound updates accumulator acc with input.
+func xxhRound(acc, input uint32) uint32 {
+	acc += input * xxhPrime2
+	acc = rotl32(acc, 13)
+	return acc * xxhPrime1
+}
+
+// rotl32 rotates x left by n bits.
+func rotl32(x uint32, n uint) uint32 {
+	return x<<n | x>>(32-n)
+}
+
+// Hash32 is implemented by the checksums of this package.
+type Hash32 interface {
+	Write(p []byte) (int, error)
+	Sum32() uint32
+}
+
+// Reader reads data from R and updates Hash with it. N is the number of bytes
+// read.
+type Reader struct {
+	R    io.Reader
+	Hash Hash32
+	N    int64
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.R.Read(p)
+	r.Hash.Write(p[:n])
+	r.N += int64(n)
+	return n, err
+}
+
+// Writer writes data to W and updates Hash with it. N is the number of bytes
+// written.
+type Writer struct {
+	W    io.Writer
+	Hash Hash32
+	N    int64
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.W.Write(p)
+	w.Hash.Write(p[:n])
+	w.N += int64(n)
+	return n, err
+}
@@ -0,0 +1,120 @@
+package checksum
+
+import (
+	"bytes"
+	"hash/adler32"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../../test/files/kalevala.txt"
+)
+
+func testInputs() [][]byte {
+	return [][]byte{
+		{},
+		[]byte("a"),
+		[]byte("The quick brown fox jumps over the lazy dog"),
+		tu.ReadFile(testKalevala),
+	}
+}
+
+func TestCRC32(t *testing.T) {
+	for _, input := range testInputs() {
+		var c CRC32
+		c.Write(input)
+		tu.Check(t, crc32.ChecksumIEEE(input), c.Sum32())
+	}
+	t.Run("MultipleWrites", func(t *testing.T) {
+		input := tu.ReadFile(testKalevala)
+		var c CRC32
+		c.Write(input[:1000])
+		c.Write(input[1000:])
+		tu.Check(t, crc32.ChecksumIEEE(input), c.Sum32())
+		c.Reset()
+		tu.Check(t, uint32(0), c.Sum32())
+	})
+}
+
+func TestCRC32C(t *testing.T) {
+	table := crc32.MakeTable(crc32.Castagnoli)
+	for _, input := range testInputs() {
+		var c CRC32C
+		c.Write(input)
+		tu.Check(t, crc32.Checksum(input, table), c.Sum32())
+	}
+	var c CRC32C
+	c.Write([]byte("123456789"))
+	tu.Check(t, uint32(0xe3069283), c.Sum32())
+	c.Reset()
+	tu.Check(t, uint32(0), c.Sum32())
+}
+
+func TestAdler32(t *testing.T) {
+	for _, input := range testInputs() {
+		var c Adler32
+		c.Write(input)
+		tu.Check(t, adler32.Checksum(input), c.Sum32())
+	}
+	t.Run("MultipleWrites", func(t *testing.T) {
+		input := tu.ReadFile(testKalevala)
+		var c Adler32
+		c.Write(input[:1000])
+		c.Write(input[1000:])
+		tu.Check(t, adler32.Checksum(input), c.Sum32())
+		c.Reset()
+		tu.Check(t, uint32(1), c.Sum32())
+	})
+}
+
+func TestXXH32(t *testing.T) {
+	cases := []struct {
+		input    string
+		seed     uint32
+		expected uint32
+	}{
+		{input: "", seed: 0, expected: 0x02cc5d05},
+		{input: "", seed: 1, expected: 0x0b2cb792},
+		{input: "a", seed: 0, expected: 0x550d7456},
+		{input: "abc", seed: 0, expected: 0x32d153ff},
+		{input: "Nobody inspects the spammish repetition", seed: 0, expected: 0xe2293b2f},
+	}
+	for _, c := range cases {
+		x := NewXXH32(c.seed)
+		x.Write([]byte(c.input))
+		tu.Check(t, c.expected, x.Sum32())
+	}
+	t.Run("MultipleWrites", func(t *testing.T) {
+		input := tu.ReadFile(testKalevala)
+		whole := NewXXH32(0)
+		whole.Write(input)
+		parts := NewXXH32(0)
+		for i := 0; i < len(input); i += 7 {
+			end := i + 7
+			if end > len(input) {
+				end = len(input)
+			}
+			parts.Write(input[i:end])
+		}
+		tu.Check(t, whole.Sum32(), parts.Sum32())
+		parts.Reset()
+		tu.Check(t, uint32(0x02cc5d05), parts.Sum32())
+	})
+}
+
+func TestReaderAndWriter(t *testing.T) {
+	input := tu.ReadFile(testKalevala)
+	r := &Reader{R: bytes.NewReader(input), Hash: &CRC32{}}
+	var output bytes.Buffer
+	w := &Writer{W: &output, Hash: &Adler32{}}
+	_, err := io.Copy(w, r)
+	tu.ExpectNil(t, err)
+	tu.Check(t, crc32.ChecksumIEEE(input), r.Hash.Sum32())
+	tu.Check(t, adler32.Checksum(input), w.Hash.Sum32())
+	tu.Check(t, int64(len(input)), r.N)
+	tu.Check(t, int64(len(input)), w.N)
+}
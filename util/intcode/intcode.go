@@ -0,0 +1,370 @@
+// Package intcode implements universal codes and other variable-length codes
+// for integers on top of the bit streams of package bits.
+//
+// Elias gamma, delta and omega codes, exponential-Golomb codes and Golomb-Rice
+// codes consist of unary parts and binary fields. The binary fields are
+// written using bits.Writer.WriteBits, so in MSB order the codes are the
+// standard ones and in LSB order the binary fields start from their least
+// significant bit. The codes can be decoded in either order.
+//
+// Decoding functions return io.EOF if the input ends before the first bit of a
+// code and io.ErrUnexpectedEOF if it ends in the middle of a code.
+package intcode
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+)
+
+var (
+	// ErrRange is returned when encoding a value that the code can't
+	// represent.
+	ErrRange = errors.New("intcode: value out of range")
+	// ErrOverflow is returned when decoding a code whose value doesn't fit in
+	// 64 bits.
+	ErrOverflow = errors.New("intcode: value overflows 64 bits")
+)
+
+// MaxRiceQuotient is the largest quotient accepted by WriteRice and ReadRice.
+// The quotient is coded in unary, so larger quotients would take
+// impractically many bits.
+const MaxRiceQuotient = 1 << 16
+
+// maxUvarintLen is the maximum length of a 64-bit value coded as LEB128.
+const maxUvarintLen = 10
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// writeUnary writes n zero bits followed by a one bit.
+func writeUnary(w *bits.Writer, n uint64) error {
+	for ; n > 64; n -= 64 {
+		if err := w.WriteBits(0, 64); err != nil {
+			return err
+		}
+	}
+	if err := w.WriteBits(0, uint(n)); err != nil {
+		return err
+	}
+	return w.WriteBit(true)
+}
+
+// readUnary reads zero bits up to and including a one bit and returns the
+// number of zero bits. ErrOverflow is returned if there are more than max zero
+// bits.
+func readUnary(r *bits.Reader, max uint64) (uint64, error) {
+	n := uint64(0)
+	for {
+		bit, err := r.ReadBit()
+		if err != nil {
+			if n == 0 {
+				return 0, err
+			}
+			return 0, unexpected(err)
+		}
+		if bit {
+			return n, nil
+		}
+		if n == max {
+			return 0, ErrOverflow
+		}
+		n++
+	}
+}
+
+// WriteGamma writes n to w using the Elias gamma code. n must be positive.
+func WriteGamma(w *bits.Writer, n uint64) error {
+	if n == 0 {
+		return ErrRange
+	}
+	length := uint(bits.Len64(n))
+	if err := writeUnary(w, uint64(length-1)); err != nil {
+		return err
+	}
+	return w.WriteBits(n, length-1)
+}
+
+// ReadGamma reads an integer coded using the Elias gamma code from r.
+func ReadGamma(r *bits.Reader) (uint64, error) {
+	zeros, err := readUnary(r, 63)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.ReadBits(uint(zeros))
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	return 1<<zeros | n, nil
+}
+
+// GammaLen returns the length of the Elias gamma code of n in bits. n must be
+// positive.
+func GammaLen(n uint64) int {
+	return 2*bits.Len64(n) - 1
+}
+
+// WriteDelta writes n to w using the Elias delta code. n must be positive.
+func WriteDelta(w *bits.Writer, n uint64) error {
+	if n == 0 {
+		return ErrRange
+	}
+	length := uint(bits.Len64(n))
+	if err := WriteGamma(w, uint64(length)); err != nil {
+		return err
+	}
+	return w.WriteBits(n, length-1)
+}
+
+// ReadDelta reads an integer coded using the Elias delta code from r.
+func ReadDelta(r *bits.Reader) (uint64, error) {
+	length, err := ReadGamma(r)
+	if err != nil {
+		return 0, err
+	}
+	if length > 64 {
+		return 0, ErrOverflow
+	}
+	n, err := r.ReadBits(uint(length - 1))
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	return 1<<(length-1) | n, nil
+}
+
+// DeltaLen returns the length of the Elias delta code of n in bits. n must be
+// positive.
+func DeltaLen(n uint64) int {
+	length := bits.Len64(n)
+	return GammaLen(uint64(length)) + length - 1
+}
+
+// WriteOmega writes n to w using the Elias omega code. n must be positive.
+func WriteOmega(w *bits.Writer, n uint64) error {
+	if n == 0 {
+		return ErrRange
+	}
+	// The groups are written in the reverse order of their computation. The
+	// lengths of the groups decrease so fast that there are at most five of
+	// them.
+	var groups [5]uint64
+	count := 0
+	for ; n > 1; count++ {
+		groups[count] = n
+		n = uint64(bits.Len64(n) - 1)
+	}
+	for i := count - 1; i >= 0; i-- {
+		if err := w.WriteBit(true); err != nil {
+			return err
+		}
+		if err := w.WriteBits(groups[i], uint(bits.Len64(groups[i])-1)); err != nil {
+			return err
+		}
+	}
+	return w.WriteBit(false)
+}
+
+// ReadOmega reads an integer coded using the Elias omega code from r.
+func ReadOmega(r *bits.Reader) (uint64, error) {
+	n := uint64(1)
+	for first := true; ; first = false {
+		bit, err := r.ReadBit()
+		if err != nil {
+			if first {
+				return 0, err
+			}
+			return 0, unexpected(err)
+		}
+		if !bit {
+			return n, nil
+		}
+		if n > 63 {
+			return 0, ErrOverflow
+		}
+		group, err := r.ReadBits(uint(n))
+		if err != nil {
+			return 0, unexpected(err)
+		}
+		n = 1<<n | group
+	}
+}
+
+// OmegaLen returns the length of the Elias omega code of n in bits. n must be
+// positive.
+func OmegaLen(n uint64) int {
+	length := 1
+	for ; n > 1; n = uint64(bits.Len64(n) - 1) {
+		length += bits.Len64(n)
+	}
+	return length
+}
+
+// WriteExpGolomb writes n to w using the exponential-Golomb code of order k.
+// The code consists of the Elias gamma code of n>>k + 1 followed by the low k
+// bits of n. k must be in range [0, 63].
+func WriteExpGolomb(w *bits.Writer, n uint64, k uint) error {
+	high := n>>k + 1
+	if k > 63 || high == 0 {
+		return ErrRange
+	}
+	if err := WriteGamma(w, high); err != nil {
+		return err
+	}
+	return w.WriteBits(n, k)
+}
+
+// ReadExpGolomb reads an integer coded using the exponential-Golomb code of
+// order k from r.
+func ReadExpGolomb(r *bits.Reader, k uint) (uint64, error) {
+	if k > 63 {
+		return 0, ErrRange
+	}
+	high, err := ReadGamma(r)
+	if err != nil {
+		return 0, err
+	}
+	if high-1 > ^uint64(0)>>k {
+		return 0, ErrOverflow
+	}
+	low, err := r.ReadBits(k)
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	return (high-1)<<k | low, nil
+}
+
+// ExpGolombLen returns the length of the exponential-Golomb code of order k of
+// n in bits.
+func ExpGolombLen(n uint64, k uint) int {
+	return GammaLen(n>>k+1) + int(k)
+}
+
+// WriteRice writes n to w using the Golomb-Rice code with parameter k. The code
+// consists of the quotient n>>k coded in unary as zero bits terminated by a one
+// bit followed by the low k bits of n. k must be in range [0, 63] and the
+// quotient must be at most MaxRiceQuotient.
+func WriteRice(w *bits.Writer, n uint64, k uint) error {
+	if k > 63 || n>>k > MaxRiceQuotient {
+		return ErrRange
+	}
+	if err := writeUnary(w, n>>k); err != nil {
+		return err
+	}
+	return w.WriteBits(n, k)
+}
+
+// ReadRice reads an integer coded using the Golomb-Rice code with parameter k
+// from r.
+func ReadRice(r *bits.Reader, k uint) (uint64, error) {
+	if k > 63 {
+		return 0, ErrRange
+	}
+	q, err := readUnary(r, MaxRiceQuotient)
+	if err != nil {
+		return 0, err
+	}
+	if q > ^uint64(0)>>k {
+		return 0, ErrOverflow
+	}
+	low, err := r.ReadBits(k)
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	return q<<k | low, nil
+}
+
+// RiceLen returns the length of the Golomb-Rice code with parameter k of n in
+// bits.
+func RiceLen(n uint64, k uint) int {
+	return int(n>>k) + 1 + int(k)
+}
+
+// RiceParameter returns the Golomb-Rice parameter that codes values using the
+// fewest bits in total. Parameters producing quotients larger than
+// MaxRiceQuotient aren't considered.
+func RiceParameter(values []uint64) uint {
+	best, bestLen := uint(63), ^uint64(0)
+	for k := uint(0); k < 64; k++ {
+		total := uint64(0)
+		for _, n := range values {
+			if n>>k > MaxRiceQuotient {
+				total = ^uint64(0)
+				break
+			}
+			total += uint64(RiceLen(n, k))
+		}
+		if total < bestLen {
+			best, bestLen = k, total
+		}
+	}
+	return best
+}
+
+// WriteUvarint writes n to w using the unsigned LEB128 code. Each byte holds
+// seven bits of n starting from the least significant ones and a continuation
+// bit as the most significant bit.
+func WriteUvarint(w *bits.Writer, n uint64) error {
+	for ; n >= 0x80; n >>= 7 {
+		if err := w.WriteByte(byte(n) | 0x80); err != nil {
+			return err
+		}
+	}
+	return w.WriteByte(byte(n))
+}
+
+// ReadUvarint reads an integer coded using the unsigned LEB128 code from r.
+func ReadUvarint(r *bits.Reader) (uint64, error) {
+	var n uint64
+	for i := 0; i < maxUvarintLen; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if i == 0 {
+				return 0, err
+			}
+			return 0, unexpected(err)
+		}
+		if i == maxUvarintLen-1 && b > 1 {
+			return 0, ErrOverflow
+		}
+		n |= uint64(b&0x7f) << (7 * uint(i))
+		if b < 0x80 {
+			return n, nil
+		}
+	}
+	return 0, ErrOverflow
+}
+
+// UvarintLen returns the length of the unsigned LEB128 code of n in bytes.
+func UvarintLen(n uint64) int {
+	return (bits.Len64(n|1) + 6) / 7
+}
+
+// Zigzag maps signed integers to unsigned ones so that values of small
+// magnitude map to small values: 0, -1, 1, -2, 2, ... map to 0, 1, 2, 3, 4,
+// ...
+func Zigzag(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+// Unzigzag is the inverse of Zigzag.
+func Unzigzag(n uint64) int64 {
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+// WriteVarint writes n to w by mapping it using Zigzag and coding the result
+// using the unsigned LEB128 code.
+func WriteVarint(w *bits.Writer, n int64) error {
+	return WriteUvarint(w, Zigzag(n))
+}
+
+// ReadVarint reads an integer written using WriteVarint from r.
+func ReadVarint(r *bits.Reader) (int64, error) {
+	n, err := ReadUvarint(r)
+	return Unzigzag(n), err
+}
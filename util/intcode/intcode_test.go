@@ -0,0 +1,280 @@
+package intcode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bits"
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+// code describes an integer code for testing.
+type code struct {
+	name  string
+	write func(*bits.Writer, uint64) error
+	read  func(*bits.Reader) (uint64, error)
+	len   func(uint64) int
+	// valid reports whether the code can represent n.
+	valid func(n uint64) bool
+}
+
+func positive(n uint64) bool {
+	return n > 0
+}
+
+func codes() []code {
+	cs := []code{
+		{name: "Gamma", write: WriteGamma, read: ReadGamma, len: GammaLen, valid: positive},
+		{name: "Delta", write: WriteDelta, read: ReadDelta, len: DeltaLen, valid: positive},
+		{name: "Omega", write: WriteOmega, read: ReadOmega, len: OmegaLen, valid: positive},
+		{
+			name:  "Uvarint",
+			write: WriteUvarint, read: ReadUvarint,
+			len:   func(n uint64) int { return 8 * UvarintLen(n) },
+			valid: func(n uint64) bool { return true },
+		},
+	}
+	for _, k := range []uint{0, 3, 63} {
+		k := k
+		cs = append(cs, code{
+			name:  fmt.Sprint("ExpGolomb", k),
+			write: func(w *bits.Writer, n uint64) error { return WriteExpGolomb(w, n, k) },
+			read:  func(r *bits.Reader) (uint64, error) { return ReadExpGolomb(r, k) },
+			len:   func(n uint64) int { return ExpGolombLen(n, k) },
+			valid: func(n uint64) bool { return n>>k+1 != 0 },
+		}, code{
+			name:  fmt.Sprint("Rice", k),
+			write: func(w *bits.Writer, n uint64) error { return WriteRice(w, n, k) },
+			read:  func(r *bits.Reader) (uint64, error) { return ReadRice(r, k) },
+			len:   func(n uint64) int { return RiceLen(n, k) },
+			valid: func(n uint64) bool { return n>>k <= MaxRiceQuotient },
+		})
+	}
+	return cs
+}
+
+// randomValue returns a random value with a random number of significant
+// bits.
+func randomValue(rng *rand.Rand) uint64 {
+	return rng.Uint64() >> uint(rng.Intn(64))
+}
+
+// encode returns n encoded using write in MSB order as a string of bits.
+func encode(t *testing.T, write func(*bits.Writer, uint64) error, n uint64) string {
+	t.Helper()
+	var encoded bytes.Buffer
+	w := bits.NewWriter(&encoded)
+	tu.ExpectNil(t, write(w, n))
+	// A 1-bit followed by zeros marks the end of the code.
+	tu.ExpectNil(t, w.WriteBit(true))
+	tu.ExpectNil(t, w.Flush())
+	list := bits.NewList(encoded.Bytes())
+	s := list.String()
+	return s[:strings.LastIndexByte(s, '1')]
+}
+
+func TestKnownCodes(t *testing.T) {
+	expGolomb0 := func(w *bits.Writer, n uint64) error { return WriteExpGolomb(w, n, 0) }
+	expGolomb2 := func(w *bits.Writer, n uint64) error { return WriteExpGolomb(w, n, 2) }
+	rice2 := func(w *bits.Writer, n uint64) error { return WriteRice(w, n, 2) }
+	cases := []struct {
+		write    func(*bits.Writer, uint64) error
+		n        uint64
+		expected string
+	}{
+		{write: WriteGamma, n: 1, expected: "1"},
+		{write: WriteGamma, n: 2, expected: "010"},
+		{write: WriteGamma, n: 5, expected: "00101"},
+		{write: WriteGamma, n: 17, expected: "000010001"},
+		{write: WriteDelta, n: 1, expected: "1"},
+		{write: WriteDelta, n: 2, expected: "0100"},
+		{write: WriteDelta, n: 5, expected: "01101"},
+		{write: WriteDelta, n: 17, expected: "001010001"},
+		{write: WriteOmega, n: 1, expected: "0"},
+		{write: WriteOmega, n: 2, expected: "100"},
+		{write: WriteOmega, n: 4, expected: "101000"},
+		{write: WriteOmega, n: 17, expected: "10100100010"},
+		{write: expGolomb0, n: 0, expected: "1"},
+		{write: expGolomb0, n: 3, expected: "00100"},
+		{write: expGolomb2, n: 9, expected: "01101"},
+		{write: rice2, n: 9, expected: "00101"},
+		{write: WriteUvarint, n: 300, expected: "1010110000000010"},
+	}
+	for _, c := range cases {
+		tu.Check(t, c.expected, encode(t, c.write, c.n))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, order := range []bits.Order{bits.MSB, bits.LSB} {
+		for _, c := range codes() {
+			rng := rand.New(rand.NewSource(1))
+			values := []uint64{0, 1, 2, math.MaxUint64, math.MaxUint64 - 1}
+			for i := 0; i < 1000; i++ {
+				values = append(values, randomValue(rng))
+			}
+			var encoded bytes.Buffer
+			w := bits.NewWriterOrder(&encoded, order)
+			var written []uint64
+			for _, n := range values {
+				if !c.valid(n) {
+					tu.Check(t, ErrRange, c.write(w, n))
+					continue
+				}
+				var single bytes.Buffer
+				sw := bits.NewWriterOrder(&single, order)
+				tu.ExpectNil(t, sw.WriteBits(0, 7))
+				tu.ExpectNil(t, c.write(sw, n))
+				tu.ExpectNil(t, sw.Flush())
+				if (c.len(n)+7+7)/8 != single.Len() {
+					t.Fatalf("%s: expected %d bits for %d, found %d bytes", c.name, c.len(n), n, single.Len())
+				}
+				tu.ExpectNil(t, c.write(w, n))
+				written = append(written, n)
+			}
+			tu.ExpectNil(t, w.Flush())
+			r := bits.NewReaderOrder(&encoded, order)
+			for _, n := range written {
+				found, err := c.read(r)
+				tu.ExpectNil(t, err)
+				if found != n {
+					t.Fatalf("%s: expected %d, found %d", c.name, n, found)
+				}
+			}
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, c := range codes() {
+		for i := 0; i < 100; i++ {
+			n := randomValue(rng)
+			// Long Rice codes would make the test slow.
+			if !c.valid(n) || c.len(n) > 256 {
+				continue
+			}
+			var encoded bytes.Buffer
+			w := bits.NewWriter(&encoded)
+			tu.ExpectNil(t, c.write(w, n))
+			tu.ExpectNil(t, w.Flush())
+			for end := 0; end < encoded.Len(); end++ {
+				_, err := c.read(bits.NewReader(bytes.NewReader(encoded.Bytes()[:end])))
+				if end == 0 {
+					tu.ExpectEOF(t, err)
+				} else if err != io.ErrUnexpectedEOF {
+					t.Fatalf("%s: expected io.ErrUnexpectedEOF for %d cut to %d bytes, found %v", c.name, n, end, err)
+				}
+			}
+		}
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	zeros := func(n int) []byte {
+		return append(make([]byte, n), 0xff)
+	}
+	cases := []struct {
+		desc  string
+		read  func(*bits.Reader) (uint64, error)
+		input []byte
+	}{
+		{desc: "Gamma", read: ReadGamma, input: zeros(8)},
+		// The gamma code of 65 as the length.
+		{desc: "Delta", read: ReadDelta, input: []byte{0x02, 0x08, 0xff}},
+		// Groups 2, 4, 31 and 1<<31 make the next group too long.
+		{desc: "Omega", read: ReadOmega, input: []byte{0xa7, 0xe0, 0x00, 0x00, 0x00, 0x3f}},
+		{desc: "ExpGolomb", read: func(r *bits.Reader) (uint64, error) { return ReadExpGolomb(r, 1) }, input: []byte{0, 0, 0, 0, 0, 0, 0, 1, 0x80, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{desc: "RiceQuotient", read: func(r *bits.Reader) (uint64, error) { return ReadRice(r, 0) }, input: zeros(MaxRiceQuotient/8 + 1)},
+		{desc: "RiceValue", read: func(r *bits.Reader) (uint64, error) { return ReadRice(r, 63) }, input: []byte{0x20, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{desc: "Uvarint", read: ReadUvarint, input: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}},
+		{desc: "UvarintLong", read: ReadUvarint, input: bytes.Repeat([]byte{0x80}, 11)},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			_, err := c.read(bits.NewReader(bytes.NewReader(c.input)))
+			tu.Check(t, ErrOverflow, err)
+		})
+	}
+}
+
+func TestUvarintCompatibility(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	buf := make([]byte, binary.MaxVarintLen64)
+	for i := 0; i < 1000; i++ {
+		n := randomValue(rng)
+		var encoded bytes.Buffer
+		w := bits.NewWriter(&encoded)
+		tu.ExpectNil(t, WriteUvarint(w, n))
+		tu.ExpectNil(t, w.Flush())
+		length := binary.PutUvarint(buf, n)
+		tu.Check(t, string(buf[:length]), encoded.String())
+		tu.Check(t, length, UvarintLen(n))
+
+		s := int64(n)
+		encoded.Reset()
+		tu.ExpectNil(t, WriteVarint(w, s))
+		tu.ExpectNil(t, w.Flush())
+		length = binary.PutVarint(buf, s)
+		tu.Check(t, string(buf[:length]), encoded.String())
+		found, err := ReadVarint(bits.NewReader(&encoded))
+		tu.ExpectNil(t, err)
+		tu.Check(t, s, found)
+	}
+}
+
+func TestZigzag(t *testing.T) {
+	cases := []struct {
+		n        int64
+		expected uint64
+	}{
+		{n: 0, expected: 0},
+		{n: -1, expected: 1},
+		{n: 1, expected: 2},
+		{n: -2, expected: 3},
+		{n: math.MaxInt64, expected: math.MaxUint64 - 1},
+		{n: math.MinInt64, expected: math.MaxUint64},
+	}
+	for _, c := range cases {
+		tu.Check(t, c.expected, Zigzag(c.n))
+		tu.Check(t, c.n, Unzigzag(c.expected))
+	}
+}
+
+func TestRiceParameter(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for _, mean := range []float64{0.5, 10, 1000, 1e9} {
+		values := make([]uint64, 1000)
+		for i := range values {
+			values[i] = uint64(rng.ExpFloat64() * mean)
+		}
+		k := RiceParameter(values)
+		total := func(k uint) int {
+			sum := 0
+			for _, n := range values {
+				sum += RiceLen(n, k)
+			}
+			return sum
+		}
+		for other := uint(0); other < 64; other++ {
+			if total(other) < total(k) {
+				maxQuotient := uint64(0)
+				for _, n := range values {
+					if n>>other > maxQuotient {
+						maxQuotient = n >> other
+					}
+				}
+				if maxQuotient <= MaxRiceQuotient {
+					t.Fatalf("mean %v: parameter %d is better than %d", mean, other, k)
+				}
+			}
+		}
+	}
+	tu.Check(t, uint(0), RiceParameter(nil))
+}
@@ -0,0 +1,58 @@
+// Package trie implements the string dictionary used by the encoders of the
+// LZ78 family of algorithms.
+//
+// Each string of the dictionary is identified by a code chosen by the caller.
+// A string is added by appending a byte to an existing string, so the strings
+// form a trie whose root codes are the strings that have no parent, such as
+// the empty string in LZ78 or the single bytes in LZW.
+package trie
+
+// Trie is a dictionary of strings identified by codes. The children of a code
+// are kept in a singly linked list stored in the firstChild and nextSibling
+// arrays, so the trie needs no allocations after it is created.
+type Trie struct {
+	// firstChild holds the most recently added child of each code. Code 0 is
+	// always a root and can't be a child, so 0 marks a missing child.
+	firstChild []uint16
+	// nextSibling holds the next child of the parent of each code.
+	nextSibling []uint16
+	// suffix holds the last byte of the string of each code.
+	suffix []byte
+}
+
+// New returns an empty trie that can hold codes less than size. size must be
+// at most 1<<16.
+func New(size int) *Trie {
+	return &Trie{
+		firstChild:  make([]uint16, size),
+		nextSibling: make([]uint16, size),
+		suffix:      make([]byte, size),
+	}
+}
+
+// Find returns the code of the string formed by appending b to the string of
+// code prefix. false is returned if the string isn't in the trie.
+func (t *Trie) Find(prefix uint32, b byte) (uint32, bool) {
+	for child := t.firstChild[prefix]; child != 0; child = t.nextSibling[child] {
+		if t.suffix[child] == b {
+			return uint32(child), true
+		}
+	}
+	return 0, false
+}
+
+// Add adds code as the string formed by appending b to the string of code
+// prefix. code must not be 0.
+func (t *Trie) Add(prefix uint32, b byte, code uint32) {
+	t.suffix[code] = b
+	t.firstChild[code] = 0
+	t.nextSibling[code] = t.firstChild[prefix]
+	t.firstChild[prefix] = uint16(code)
+}
+
+// Reset removes all codes except the root codes less than roots from the trie.
+func (t *Trie) Reset(roots int) {
+	for i := 0; i < roots; i++ {
+		t.firstChild[i] = 0
+	}
+}
@@ -0,0 +1,48 @@
+package trie
+
+import (
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+func TestTrie(t *testing.T) {
+	// Codes 0 and 1 are roots for the strings "" and "x".
+	trie := New(8)
+	trie.Add(0, 'a', 2)
+	trie.Add(0, 'b', 3)
+	trie.Add(2, 'b', 4)
+	trie.Add(1, 'a', 5)
+	cases := []struct {
+		prefix uint32
+		b      byte
+		code   uint32
+		found  bool
+	}{
+		{prefix: 0, b: 'a', code: 2, found: true},
+		{prefix: 0, b: 'b', code: 3, found: true},
+		{prefix: 2, b: 'b', code: 4, found: true},
+		{prefix: 1, b: 'a', code: 5, found: true},
+		{prefix: 0, b: 'c', found: false},
+		{prefix: 3, b: 'a', found: false},
+		{prefix: 4, b: 'b', found: false},
+	}
+	for _, c := range cases {
+		code, found := trie.Find(c.prefix, c.b)
+		tu.Check(t, c.found, found)
+		tu.Check(t, c.code, code)
+	}
+
+	trie.Reset(2)
+	_, found := trie.Find(0, 'a')
+	tu.Check(t, false, found)
+	_, found = trie.Find(1, 'a')
+	tu.Check(t, false, found)
+	// Reusing a code drops the children it had before the reset.
+	trie.Add(0, 'c', 2)
+	_, found = trie.Find(2, 'b')
+	tu.Check(t, false, found)
+	code, found := trie.Find(0, 'c')
+	tu.Check(t, true, found)
+	tu.Check(t, uint32(2), code)
+}
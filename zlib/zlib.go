@@ -0,0 +1,88 @@
+/*
+Package zlib implements the zlib data format described in RFC 1950.
+
+zlib data is formatted as follows:
+
+	2-byte header specifying the compression method and window size
+	DEFLATE compressed data
+	Adler-32 checksum of the uncompressed data as a big-endian uint32 value
+
+Preset dictionaries are not supported.
+*/
+package zlib
+
+import (
+	"errors"
+	"io"
+
+	"github.com/lassilaiho/compression-algorithms-tiralabra/deflate"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/binary"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/bufio"
+	"github.com/lassilaiho/compression-algorithms-tiralabra/util/checksum"
+)
+
+// Errors returned by Decode.
+var (
+	ErrHeader     = errors.New("zlib: invalid header")
+	ErrChecksum   = errors.New("zlib: invalid checksum")
+	ErrDictionary = errors.New("zlib: preset dictionaries are not supported")
+)
+
+// These constants specify the contents of the header.
+const (
+	methodDeflate = 8
+	// maxWindowBits is the base-2 logarithm of the largest window size.
+	maxWindowBits = 15
+	flagDict      = 1 << 5
+	// levelDefault is the value of the FLEVEL field meaning the default
+	// compression level.
+	levelDefault = 2
+)
+
+// Encode compresses data from input and writes it to output in zlib format.
+func Encode(input io.Reader, output io.Writer) error {
+	var header [2]byte
+	header[0] = (maxWindowBits-8)<<4 | methodDeflate
+	header[1] = levelDefault << 6
+	header[1] += byte((31 - (uint(header[0])<<8|uint(header[1]))%31) % 31)
+	if _, err := output.Write(header[:]); err != nil {
+		return err
+	}
+	src := &checksum.Reader{R: input, Hash: &checksum.Adler32{}}
+	if err := deflate.Encode(src, output); err != nil {
+		return err
+	}
+	var trailer [4]byte
+	binary.PutUint32BE(trailer[:], src.Hash.Sum32())
+	_, err := output.Write(trailer[:])
+	return err
+}
+
+// Decode decodes zlib data from input and writes the uncompressed data to
+// output.
+func Decode(input io.Reader, output io.Writer) error {
+	src := bufio.NewReader(input)
+	var header [2]byte
+	if _, err := bufio.ReadFull(src, header[:]); err != nil {
+		return err
+	}
+	if header[0]&0x0f != methodDeflate || header[0]>>4 > maxWindowBits-8 ||
+		(uint(header[0])<<8|uint(header[1]))%31 != 0 {
+		return ErrHeader
+	}
+	if header[1]&flagDict != 0 {
+		return ErrDictionary
+	}
+	dst := &checksum.Writer{W: output, Hash: &checksum.Adler32{}}
+	if err := deflate.Decode(src, dst); err != nil {
+		return err
+	}
+	var trailer [4]byte
+	if _, err := bufio.ReadFull(src, trailer[:]); err != nil {
+		return err
+	}
+	if binary.GetUint32BE(trailer[:]) != dst.Hash.Sum32() {
+		return ErrChecksum
+	}
+	return nil
+}
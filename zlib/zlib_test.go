@@ -0,0 +1,92 @@
+package zlib
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	tu "github.com/lassilaiho/compression-algorithms-tiralabra/util/testutil"
+)
+
+const (
+	testKalevala = "../test/files/kalevala.txt"
+)
+
+var testData = [][]byte{
+	{},
+	[]byte("hello, hello, hello"),
+	tu.ReadFile(testKalevala),
+}
+
+func TestEncodeDecodedByStdlib(t *testing.T) {
+	for _, data := range testData {
+		var encoded bytes.Buffer
+		tu.ExpectNil(t, Encode(bytes.NewReader(data), &encoded))
+		r, err := zlib.NewReader(&encoded)
+		tu.ExpectNil(t, err)
+		decoded, err := ioutil.ReadAll(r)
+		tu.ExpectNil(t, err)
+		if !bytes.Equal(data, decoded) {
+			t.Fatal("decoded data differs from the original")
+		}
+	}
+}
+
+func TestDecodeStdlibOutput(t *testing.T) {
+	for _, data := range testData {
+		for _, level := range []int{zlib.NoCompression, zlib.BestSpeed, zlib.BestCompression} {
+			var encoded bytes.Buffer
+			w, err := zlib.NewWriterLevel(&encoded, level)
+			tu.ExpectNil(t, err)
+			_, err = w.Write(data)
+			tu.ExpectNil(t, err)
+			tu.ExpectNil(t, w.Close())
+			var decoded bytes.Buffer
+			tu.ExpectNil(t, Decode(&encoded, &decoded))
+			if !bytes.Equal(data, decoded.Bytes()) {
+				t.Fatal("decoded data differs from the original")
+			}
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var encoded bytes.Buffer
+	tu.ExpectNil(t, Encode(bytes.NewReader(testData[1]), &encoded))
+	valid := encoded.Bytes()
+
+	corrupt := func(i int, mask byte) []byte {
+		data := make([]byte, len(valid))
+		copy(data, valid)
+		data[i] ^= mask
+		return data
+	}
+	var withDict bytes.Buffer
+	w, _ := zlib.NewWriterLevelDict(&withDict, zlib.DefaultCompression, []byte("dict"))
+	w.Write([]byte("data"))
+	w.Close()
+
+	cases := []struct {
+		desc     string
+		data     []byte
+		expected error
+	}{
+		{desc: "Empty", data: []byte{}, expected: io.ErrUnexpectedEOF},
+		{desc: "Method", data: corrupt(0, 0x01), expected: ErrHeader},
+		{desc: "Check", data: corrupt(1, 0x01), expected: ErrHeader},
+		{desc: "Dictionary", data: withDict.Bytes(), expected: ErrDictionary},
+		{desc: "Checksum", data: corrupt(len(valid)-1, 0x01), expected: ErrChecksum},
+		{
+			desc:     "Truncated",
+			data:     valid[:len(valid)-1],
+			expected: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			tu.Check(t, c.expected, Decode(bytes.NewReader(c.data), ioutil.Discard))
+		})
+	}
+}